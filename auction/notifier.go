@@ -0,0 +1,69 @@
+package auction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventType names what happened when a job's bidding closed.
+type EventType string
+
+const (
+	// EventBidWon is emitted when the scheduler accepts a bid. The job's
+	// client and the winning service provider are told.
+	EventBidWon EventType = "bid_won"
+	// EventAwaitingChoice is emitted when bidding closes under the manual
+	// rule. The job's client picks the winner.
+	EventAwaitingChoice EventType = "awaiting_choice"
+	// EventExpired is emitted when bidding closes without bids and the job
+	// is cancelled.
+	EventExpired EventType = "expired"
+)
+
+// Event reports the outcome of closing the bidding on one job.
+type Event struct {
+	Type              EventType          `json:"type"`
+	JobID             primitive.ObjectID `json:"jobId"`
+	ClientID          primitive.ObjectID `json:"clientId,omitempty"`
+	BidID             primitive.ObjectID `json:"bidId,omitempty"`             // the winning bid
+	ServiceProviderID primitive.ObjectID `json:"serviceProviderId,omitempty"` // who made the winning bid
+	Rule              Rule               `json:"rule"`
+	At                time.Time          `json:"at"`
+}
+
+// Notifier delivers the events of the Scheduler, e.g. to the users they
+// concern.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Notifiers delivers each event to every notifier in turn.
+type Notifiers []Notifier
+
+func (n Notifiers) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogNotifier writes events to the standard logger instead of delivering
+// them. It is meant for local development.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, event Event) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("Auction event: %s", encoded)
+	return nil
+}
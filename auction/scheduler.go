@@ -0,0 +1,236 @@
+// Package auction closes the bidding on jobs once their bidding deadline has
+// passed and picks the winning bid by a configurable rule.
+package auction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// leaseName is the lease a Scheduler holds while it closes jobs, so that only
+// one replica closes them at a time.
+const leaseName = "auction"
+
+// Rule decides which bid wins when a job's bidding closes.
+type Rule string
+
+const (
+	// RuleLowestBid accepts the bid with the lowest BidAmount.
+	RuleLowestBid Rule = "lowest_bid"
+	// RuleBestRating accepts the bid of the service provider with the best
+	// average review rating.
+	RuleBestRating Rule = "best_rating"
+	// RuleManual accepts no bid; the job's client picks the winner.
+	RuleManual Rule = "manual"
+)
+
+// Options configures the Scheduler.
+type Options struct {
+	Rule      Rule
+	Interval  time.Duration // time between looks for expired jobs
+	LeaseTTL  time.Duration // how long one replica may close jobs before another may take over
+	BatchSize int64         // jobs closed per look
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		Rule:      RuleLowestBid,
+		Interval:  time.Minute,
+		LeaseTTL:  5 * time.Minute,
+		BatchSize: 100,
+	}
+}
+
+// Scheduler closes the bidding on open jobs whose bidding deadline has
+// passed. Replicas share the work through a lease in the database.
+type Scheduler struct {
+	db       database.Database
+	notifier Notifier
+	opts     Options
+	holder   string // identifies this Scheduler in the lease
+	now      func() time.Time
+}
+
+// NewScheduler returns a Scheduler that closes jobs in db and reports each
+// closed job to notifier.
+func NewScheduler(db database.Database, notifier Notifier, opts Options) *Scheduler {
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d-%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+	return &Scheduler{db: db, notifier: notifier, opts: opts, holder: holder, now: time.Now}
+}
+
+// Run closes expired jobs every Interval until ctx is done, then gives up
+// the lease so another replica can take over at once.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.CloseExpired(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error closing expired jobs: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.db.ReleaseLease(releaseCtx, leaseName, s.holder); err != nil {
+				log.Printf("Error releasing auction lease: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CloseExpired closes the bidding on up to BatchSize open jobs whose
+// deadline has passed, if no other replica holds the lease. It returns the
+// number of jobs closed.
+func (s *Scheduler) CloseExpired(ctx context.Context) (int, error) {
+	now := s.now()
+	held, err := s.db.AcquireLease(ctx, leaseName, s.holder, now, s.opts.LeaseTTL)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	var jobs []structure.Job
+	filter := bson.M{
+		"jobstatus":       structure.JobStatusJobPosted,
+		"biddingdeadline": bson.M{"$lte": now},
+		"biddingclosed":   bson.M{"$ne": true},
+	}
+	opts := database.FindOptions{Limit: s.opts.BatchSize, Sort: bson.D{{Key: "biddingdeadline", Value: 1}}}
+	if _, err := s.db.FindPage(ctx, "job", filter, opts, &jobs); err != nil {
+		return 0, fmt.Errorf("failed to find expired jobs: %w", err)
+	}
+
+	closed := 0
+	var errs []error
+	for _, job := range jobs {
+		if err := s.close(ctx, job, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close job %s: %w", job.ID.Hex(), err))
+			continue
+		}
+		closed++
+	}
+	return closed, errors.Join(errs...)
+}
+
+// close closes the bidding on job. It cancels a job without bids, leaves the
+// choice to the client under the manual rule, and otherwise accepts the
+// winning bid.
+func (s *Scheduler) close(ctx context.Context, job structure.Job, now time.Time) error {
+	var bids []structure.Bid
+	filter := bson.M{"jobid": job.ID, "status": bson.M{"$ne": structure.StatusRejected}}
+	if err := s.db.Find(ctx, "bid", filter, &bids); err != nil {
+		return err
+	}
+
+	id := job.ID.Hex()
+	event := Event{JobID: job.ID, ClientID: job.ClientID, Rule: s.opts.Rule, At: now.UTC()}
+	switch {
+	case len(bids) == 0:
+		transition, err := job.Transition(structure.JobStatusCancelled, primitive.NilObjectID, now, "No bids before the bidding deadline")
+		if err != nil {
+			return err
+		}
+		update := bson.M{"jobstatus": structure.JobStatusCancelled, "history": append(job.History, transition), "biddingclosed": true}
+		if err := s.db.MoveJob(ctx, id, transition, update); err != nil {
+			return err
+		}
+		event.Type = EventExpired
+
+	case s.opts.Rule == RuleManual:
+		if err := s.db.Update(ctx, "job", id, bson.M{"biddingclosed": true}); err != nil {
+			return err
+		}
+		event.Type = EventAwaitingChoice
+
+	default:
+		winner, err := s.pick(ctx, bids)
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("Bidding deadline passed; won by %s", s.opts.Rule)
+		err = s.db.AcceptBid(ctx, id, winner.ID.Hex(), primitive.NilObjectID, reason)
+		if errors.Is(err, database.ErrJobNotOpen) {
+			// The client accepted a bid in the meantime
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.db.Update(ctx, "job", id, bson.M{"biddingclosed": true}); err != nil {
+			return err
+		}
+		event.Type = EventBidWon
+		event.BidID = winner.ID
+		event.ServiceProviderID = winner.SPID
+	}
+
+	if err := s.notifier.Notify(ctx, event); err != nil {
+		log.Printf("Error notifying %s for job %s: %v", event.Type, id, err)
+	}
+	return nil
+}
+
+// pick returns the winning bid under the scheduler's rule. Ties go to the
+// lower bid, then to the earlier one.
+func (s *Scheduler) pick(ctx context.Context, bids []structure.Bid) (structure.Bid, error) {
+	var ratings map[primitive.ObjectID]float64
+	if s.opts.Rule == RuleBestRating {
+		var err error
+		if ratings, err = s.ratings(ctx, bids); err != nil {
+			return structure.Bid{}, err
+		}
+	}
+
+	sort.SliceStable(bids, func(i, j int) bool {
+		a, b := bids[i], bids[j]
+		if ratings[a.SPID] != ratings[b.SPID] {
+			return ratings[a.SPID] > ratings[b.SPID]
+		}
+		if a.BidAmount != b.BidAmount {
+			return a.BidAmount < b.BidAmount
+		}
+		return a.PostedTime.Before(b.PostedTime)
+	})
+	return bids[0], nil
+}
+
+// ratings returns the average review rating of each bidder. Bidders without
+// reviews rate 0.
+func (s *Scheduler) ratings(ctx context.Context, bids []structure.Bid) (map[primitive.ObjectID]float64, error) {
+	ratings := make(map[primitive.ObjectID]float64)
+	for _, bid := range bids {
+		if _, done := ratings[bid.SPID]; done {
+			continue
+		}
+
+		var reviews []structure.Review
+		if err := s.db.Find(ctx, "review", bson.M{"revieweeid": bid.SPID}, &reviews); err != nil {
+			return nil, fmt.Errorf("failed to find reviews: %w", err)
+		}
+		total := 0.0
+		for _, review := range reviews {
+			total += (review.Timelines + review.Quality + review.Communication + review.Behavior) / 4
+		}
+		if len(reviews) > 0 {
+			total /= float64(len(reviews))
+		}
+		ratings[bid.SPID] = total
+	}
+	return ratings, nil
+}
@@ -0,0 +1,199 @@
+package auction
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingNotifier keeps the events it is asked to deliver.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+// auctionTest is a Scheduler on a fresh memory store with a fake clock.
+type auctionTest struct {
+	t         *testing.T
+	store     *database.MemoryStore
+	scheduler *Scheduler
+	notifier  *recordingNotifier
+	now       *time.Time
+}
+
+func newAuctionTest(t *testing.T, rule Rule) *auctionTest {
+	t.Helper()
+	store := database.NewMemoryStore()
+	notifier := &recordingNotifier{}
+	opts := DefaultOptions()
+	opts.Rule = rule
+
+	// Start at the real time: placing a bid checks its deadline against it
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	scheduler := NewScheduler(store, notifier, opts)
+	scheduler.now = func() time.Time { return now }
+	return &auctionTest{t: t, store: store, scheduler: scheduler, notifier: notifier, now: &now}
+}
+
+// job inserts an open job whose bidding closes after the given time.
+func (a *auctionTest) job(closesIn time.Duration) structure.Job {
+	a.t.Helper()
+	job := structure.Job{Title: "Fix the roof", ClientID: primitive.NewObjectID(), JobStatus: structure.JobStatusJobPosted, BiddingDeadline: a.now.Add(closesIn)}
+	if err := a.store.Create(context.Background(), "job", &job); err != nil {
+		a.t.Fatalf("Failed to insert job document: %v", err)
+	}
+	return job
+}
+
+// bid places a bid of amount on job by the service provider.
+func (a *auctionTest) bid(job structure.Job, serviceProvider primitive.ObjectID, amount structure.Money) structure.Bid {
+	a.t.Helper()
+	bid := structure.Bid{Description: "I can do it", BidAmount: amount, SPID: serviceProvider}
+	if err := a.store.PlaceBid(context.Background(), job.ID.Hex(), &bid); err != nil {
+		a.t.Fatalf("Failed to place bid: %v", err)
+	}
+	return bid
+}
+
+// closeExpired runs the scheduler once and checks how many jobs it closed.
+func (a *auctionTest) closeExpired(want int) {
+	a.t.Helper()
+	closed, err := a.scheduler.CloseExpired(context.Background())
+	if err != nil {
+		a.t.Fatalf("Failed to close expired jobs: %v", err)
+	}
+	if closed != want {
+		a.t.Errorf("Expected %d jobs to be closed, got %d", want, closed)
+	}
+}
+
+func (a *auctionTest) get(job structure.Job) structure.Job {
+	a.t.Helper()
+	if err := a.store.Get(context.Background(), "job", job.ID.Hex(), &job); err != nil {
+		a.t.Fatalf("Failed to get job document: %v", err)
+	}
+	return job
+}
+
+func TestCloseExpiredAcceptsLowestBid(t *testing.T) {
+	// Arrange
+	a := newAuctionTest(t, RuleLowestBid)
+	expiring := a.job(time.Hour)
+	open := a.job(2 * time.Hour)
+	a.bid(expiring, primitive.NewObjectID(), structure.Taka(300))
+	lowest := a.bid(expiring, primitive.NewObjectID(), structure.Taka(200))
+	a.bid(open, primitive.NewObjectID(), structure.Taka(100))
+
+	// Act
+	a.closeExpired(0)
+	*a.now = a.now.Add(time.Hour)
+	a.closeExpired(1)
+
+	// Assert
+	job := a.get(expiring)
+	if job.JobStatus != structure.JobStatusBidAccepted || job.AcceptedBid != lowest.ID || !job.BiddingClosed {
+		t.Errorf("Expected the lowest bid to win, got %+v", job)
+	}
+	if last := job.History[len(job.History)-1]; last.To != structure.JobStatusBidAccepted || !last.Actor.IsZero() || last.Reason == "" {
+		t.Errorf("Expected the history to record the scheduler's acceptance, got %+v", last)
+	}
+	if a.get(open).JobStatus != structure.JobStatusJobPosted {
+		t.Error("Expected the job still taking bids to stay open")
+	}
+	want := Event{Type: EventBidWon, JobID: expiring.ID, ClientID: expiring.ClientID, BidID: lowest.ID, ServiceProviderID: lowest.SPID, Rule: RuleLowestBid, At: *a.now}
+	if len(a.notifier.events) != 1 || a.notifier.events[0] != want {
+		t.Errorf("Expected event %+v, got %+v", want, a.notifier.events)
+	}
+}
+
+func TestCloseExpiredAcceptsBestRatedBidder(t *testing.T) {
+	// Arrange
+	a := newAuctionTest(t, RuleBestRating)
+	job := a.job(time.Minute)
+	rated, unrated := primitive.NewObjectID(), primitive.NewObjectID()
+	review := structure.Review{RevieweeID: rated, Timelines: 4, Quality: 5, Communication: 4, Behavior: 5}
+	if err := a.store.Create(context.Background(), "review", &review); err != nil {
+		t.Fatalf("Failed to insert review document: %v", err)
+	}
+	a.bid(job, unrated, structure.Taka(100))
+	best := a.bid(job, rated, structure.Taka(400))
+
+	// Act
+	*a.now = a.now.Add(time.Minute)
+	a.closeExpired(1)
+
+	// Assert
+	if accepted := a.get(job).AcceptedBid; accepted != best.ID {
+		t.Errorf("Expected the best rated bidder's bid %s to win, got %s", best.ID.Hex(), accepted.Hex())
+	}
+}
+
+func TestCloseExpiredManualLeavesChoiceToClient(t *testing.T) {
+	a := newAuctionTest(t, RuleManual)
+	job := a.job(time.Minute)
+	a.bid(job, primitive.NewObjectID(), structure.Taka(100))
+	*a.now = a.now.Add(time.Minute)
+
+	a.closeExpired(1)
+	a.closeExpired(0)
+
+	closed := a.get(job)
+	if closed.JobStatus != structure.JobStatusJobPosted || !closed.BiddingClosed {
+		t.Errorf("Expected the job to stay open for the client's choice, got %+v", closed)
+	}
+	if len(a.notifier.events) != 1 || a.notifier.events[0].Type != EventAwaitingChoice {
+		t.Errorf("Expected one %s event, got %+v", EventAwaitingChoice, a.notifier.events)
+	}
+	bid := structure.Bid{Description: "Too late", BidAmount: structure.Taka(50)}
+	if err := a.store.PlaceBid(context.Background(), job.ID.Hex(), &bid); !errors.Is(err, database.ErrBiddingClosed) {
+		t.Errorf("Expected ErrBiddingClosed bidding after the deadline, got %v", err)
+	}
+}
+
+func TestCloseExpiredCancelsJobWithoutBids(t *testing.T) {
+	a := newAuctionTest(t, RuleLowestBid)
+	job := a.job(time.Minute)
+	*a.now = a.now.Add(time.Minute)
+
+	a.closeExpired(1)
+
+	if status := a.get(job).JobStatus; status != structure.JobStatusCancelled {
+		t.Errorf("Expected the job to be cancelled, got %s", status)
+	}
+	if len(a.notifier.events) != 1 || a.notifier.events[0].Type != EventExpired {
+		t.Errorf("Expected one %s event, got %+v", EventExpired, a.notifier.events)
+	}
+}
+
+func TestCloseExpiredOneReplicaAtATime(t *testing.T) {
+	// Arrange: a second replica sharing the store and clock
+	a := newAuctionTest(t, RuleLowestBid)
+	other := NewScheduler(a.store, a.notifier, a.scheduler.opts)
+	other.now = a.scheduler.now
+	a.closeExpired(0)
+
+	// Act & Assert: the other replica waits for the lease to expire
+	a.job(0)
+	if closed, err := other.CloseExpired(context.Background()); err != nil || closed != 0 {
+		t.Errorf("Expected the other replica not to close jobs while the lease is held, got %d, %v", closed, err)
+	}
+	*a.now = a.now.Add(a.scheduler.opts.LeaseTTL)
+	if closed, err := other.CloseExpired(context.Background()); err != nil || closed != 1 {
+		t.Errorf("Expected the other replica to take over the expired lease, got %d, %v", closed, err)
+	}
+	a.closeExpired(0)
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+
+	"Go-sumon/structure"
+)
+
+type contextKey struct{}
+
+// identity is what RequireUser attaches to a request context.
+type identity struct {
+	user   *structure.User
+	claims Claims
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user and the
+// claims of the token that authenticated them.
+func WithUser(ctx context.Context, user *structure.User, claims Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity{user: user, claims: claims})
+}
+
+// UserFromContext returns the authenticated user of the request context.
+func UserFromContext(ctx context.Context) (*structure.User, bool) {
+	id, ok := ctx.Value(contextKey{}).(identity)
+	return id.user, ok
+}
+
+// ClaimsFromContext returns the token claims of the request context.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	id, ok := ctx.Value(contextKey{}).(identity)
+	return id.claims, ok
+}
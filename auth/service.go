@@ -0,0 +1,388 @@
+// Package auth implements phone number login with one-time codes sent by
+// SMS, and the JWT access and refresh tokens issued for a login session.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collections used by the Service.
+const (
+	codesCollection    = "otp"
+	sessionsCollection = "session"
+)
+
+var (
+	// ErrInvalidCode is returned when a code is wrong, expired, already used
+	// or has had too many wrong attempts.
+	ErrInvalidCode = errors.New("invalid or expired code")
+	// ErrRateLimited is returned when too many codes were requested for a
+	// phone number within the rate limit window.
+	ErrRateLimited = errors.New("too many codes requested")
+	// ErrUnknownPhoneNumber is returned when no user has the phone number.
+	ErrUnknownPhoneNumber = errors.New("no user has this phone number")
+)
+
+// Options configures the Service.
+type Options struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration // lifetime of a session
+	CodeTTL         time.Duration
+	CodeLength      int
+	MaxAttempts     int // wrong guesses allowed per code
+	MaxCodes        int // codes a phone number may request per RateWindow
+	RateWindow      time.Duration
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		CodeTTL:         5 * time.Minute,
+		CodeLength:      6,
+		MaxAttempts:     5,
+		MaxCodes:        3,
+		RateWindow:      15 * time.Minute,
+	}
+}
+
+// Tokens is the pair of tokens issued for a session.
+type Tokens struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"` // seconds until the access token expires
+}
+
+// code is a one-time code stored in the otp collection. Only a keyed hash of
+// the code is stored.
+type code struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	PhoneNumber string             `bson:"phonenumber"`
+	CodeHash    string             `bson:"codehash"`
+	Attempts    int                `bson:"attempts"`
+	Used        bool               `bson:"used"`
+	CreatedAt   time.Time          `bson:"createdat"`
+	ExpiresAt   time.Time          `bson:"expiresat"`
+}
+
+// session is a login session stored in the session collection. Deleting it
+// revokes its access and refresh tokens.
+type session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	UserID         primitive.ObjectID `bson:"user"`
+	RefreshTokenID string             `bson:"refreshtokenid"` // jti of the only valid refresh token
+	CreatedAt      time.Time          `bson:"createdat"`
+	ExpiresAt      time.Time          `bson:"expiresat"`
+}
+
+// Service issues one-time codes and session tokens.
+type Service struct {
+	db     database.Database
+	sender SMSSender
+	signer *Signer
+	secret []byte
+	opts   Options
+	now    func() time.Time
+}
+
+// NewService returns a Service that stores codes and sessions in db, sends
+// codes with sender and signs tokens with secret.
+func NewService(db database.Database, sender SMSSender, secret []byte, opts Options) *Service {
+	return &Service{db: db, sender: sender, signer: NewSigner(secret), secret: secret, opts: opts, now: time.Now}
+}
+
+// Options returns the options of the Service.
+func (s *Service) Options() Options {
+	return s.opts
+}
+
+// RequestCode sends a new one-time code to the user with phoneNumber.
+func (s *Service) RequestCode(ctx context.Context, phoneNumber string) error {
+	if _, err := s.userByPhoneNumber(ctx, phoneNumber); err != nil {
+		return err
+	}
+
+	now := s.now()
+	recent, err := s.db.Count(ctx, codesCollection, bson.M{
+		"phonenumber": phoneNumber,
+		"createdat":   bson.M{"$gt": now.Add(-s.opts.RateWindow)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count recent codes: %w", err)
+	}
+	if recent >= int64(s.opts.MaxCodes) {
+		return ErrRateLimited
+	}
+
+	value, err := randomDigits(s.opts.CodeLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+	record := code{
+		PhoneNumber: phoneNumber,
+		CodeHash:    s.hashCode(phoneNumber, value),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.opts.CodeTTL),
+	}
+	if err := s.db.Create(ctx, codesCollection, &record); err != nil {
+		return fmt.Errorf("failed to store code: %w", err)
+	}
+
+	message := fmt.Sprintf("Your login code is %s. It expires in %d minutes.", value, int(s.opts.CodeTTL.Minutes()))
+	if err := s.sender.Send(ctx, phoneNumber, message); err != nil {
+		return fmt.Errorf("failed to send code: %w", err)
+	}
+	return nil
+}
+
+// VerifyCode checks the latest code sent to phoneNumber and, if it matches,
+// starts a session for the user and returns its tokens. Every guess is
+// counted before it is compared, and the code is marked used only if no
+// concurrent guess has used it first, so one code starts at most one session.
+func (s *Service) VerifyCode(ctx context.Context, phoneNumber string, value string) (Tokens, error) {
+	now := s.now()
+	var codes []code
+	opts := database.FindOptions{Limit: 1, Sort: bson.D{{Key: "createdat", Value: -1}}}
+	filter := bson.M{"phonenumber": phoneNumber, "used": false, "expiresat": bson.M{"$gt": now}}
+	if _, err := s.db.FindPage(ctx, codesCollection, filter, opts, &codes); err != nil {
+		return Tokens{}, fmt.Errorf("failed to find code: %w", err)
+	}
+	if len(codes) == 0 {
+		return Tokens{}, ErrInvalidCode
+	}
+
+	var latest code
+	unlocked := bson.M{"_id": codes[0].ID, "used": false, "attempts": bson.M{"$lt": s.opts.MaxAttempts}}
+	err := s.db.UpdateWhere(ctx, codesCollection, unlocked, bson.M{"$inc": bson.M{"attempts": 1}}, &latest)
+	if errors.Is(err, database.ErrNotFound) {
+		return Tokens{}, ErrInvalidCode
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("failed to record attempt: %w", err)
+	}
+	if !hmac.Equal([]byte(latest.CodeHash), []byte(s.hashCode(phoneNumber, value))) {
+		return Tokens{}, ErrInvalidCode
+	}
+
+	unused := bson.M{"_id": latest.ID, "used": false}
+	err = s.db.UpdateWhere(ctx, codesCollection, unused, bson.M{"$set": bson.M{"used": true}}, nil)
+	if errors.Is(err, database.ErrNotFound) {
+		return Tokens{}, ErrInvalidCode
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("failed to mark code used: %w", err)
+	}
+
+	user, err := s.userByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return s.startSession(ctx, user.ID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// can be used once; presenting a used one revokes the whole session.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	claims, err := s.signer.Verify(refreshToken, s.now())
+	if err != nil || claims.Type != TokenTypeRefresh {
+		return Tokens{}, ErrInvalidToken
+	}
+	current, err := s.session(ctx, claims.SessionID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if current.RefreshTokenID != claims.TokenID {
+		// A stolen refresh token was replayed; end the session for everyone
+		s.Revoke(ctx, claims.SessionID)
+		return Tokens{}, ErrInvalidToken
+	}
+
+	tokenID := primitive.NewObjectID().Hex()
+	if err := s.db.Update(ctx, sessionsCollection, current.ID.Hex(), bson.M{"refreshtokenid": tokenID}); err != nil {
+		return Tokens{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	current.RefreshTokenID = tokenID
+	return s.issueTokens(current)
+}
+
+// Revoke ends a session, invalidating its access and refresh tokens.
+func (s *Service) Revoke(ctx context.Context, sessionID string) error {
+	if err := s.db.Delete(ctx, sessionsCollection, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// Authenticate verifies an access token and returns its user and claims.
+func (s *Service) Authenticate(ctx context.Context, accessToken string) (*structure.User, Claims, error) {
+	claims, err := s.signer.Verify(accessToken, s.now())
+	if err != nil || claims.Type != TokenTypeAccess {
+		return nil, Claims{}, ErrInvalidToken
+	}
+	if _, err := s.session(ctx, claims.SessionID); err != nil {
+		return nil, Claims{}, err
+	}
+
+	var user structure.User
+	if err := s.db.Get(ctx, "user", claims.Subject, &user); err != nil {
+		return nil, Claims{}, ErrInvalidToken
+	}
+	return &user, claims, nil
+}
+
+// RequireUser authenticates the request's bearer token and attaches the
+// user and claims to its context, responding 401 if that fails.
+func (s *Service) RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			unauthorized(w, "Missing bearer token")
+			return
+		}
+		user, claims, err := s.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				unauthorized(w, "Invalid or expired token")
+			} else {
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			}
+			return
+		}
+		next(w, r.WithContext(WithUser(r.Context(), user, claims)))
+	}
+}
+
+// IdentifyUser attaches the user and claims of the request's bearer token,
+// if it has a valid one, to its context. Requests without one are served
+// anonymously.
+func (s *Service) IdentifyUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && token != "" {
+			if user, claims, err := s.Authenticate(r.Context(), token); err == nil {
+				r = r.WithContext(WithUser(r.Context(), user, claims))
+			}
+		}
+		next(w, r)
+	}
+}
+
+// unauthorized responds with 401 and a bearer challenge.
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="sumon"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// startSession creates a session for the user and issues its tokens.
+func (s *Service) startSession(ctx context.Context, userID primitive.ObjectID) (Tokens, error) {
+	now := s.now()
+	record := session{
+		UserID:         userID,
+		RefreshTokenID: primitive.NewObjectID().Hex(),
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.opts.RefreshTokenTTL),
+	}
+	if err := s.db.Create(ctx, sessionsCollection, &record); err != nil {
+		return Tokens{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s.issueTokens(record)
+}
+
+// issueTokens signs an access token and the current refresh token of the session.
+func (s *Service) issueTokens(current session) (Tokens, error) {
+	now := s.now()
+	access := Claims{
+		Subject:   current.UserID.Hex(),
+		SessionID: current.ID.Hex(),
+		TokenID:   primitive.NewObjectID().Hex(),
+		Type:      TokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.opts.AccessTokenTTL).Unix(),
+	}
+	refresh := Claims{
+		Subject:   current.UserID.Hex(),
+		SessionID: current.ID.Hex(),
+		TokenID:   current.RefreshTokenID,
+		Type:      TokenTypeRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: current.ExpiresAt.Unix(),
+	}
+
+	accessToken, err := s.signer.Sign(access)
+	if err != nil {
+		return Tokens{}, err
+	}
+	refreshToken, err := s.signer.Sign(refresh)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.opts.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// session returns the unexpired session with the given ID, or
+// ErrInvalidToken if it was revoked or has expired.
+func (s *Service) session(ctx context.Context, sessionID string) (session, error) {
+	var current session
+	if err := s.db.Get(ctx, sessionsCollection, sessionID, &current); err != nil {
+		return session{}, ErrInvalidToken
+	}
+	if !s.now().Before(current.ExpiresAt) {
+		return session{}, ErrInvalidToken
+	}
+	return current, nil
+}
+
+// userByPhoneNumber returns the user registered with phoneNumber.
+func (s *Service) userByPhoneNumber(ctx context.Context, phoneNumber string) (*structure.User, error) {
+	var users []structure.User
+	if err := s.db.Find(ctx, "user", bson.M{"phonenumber": phoneNumber}, &users); err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if len(users) == 0 || phoneNumber == "" {
+		return nil, ErrUnknownPhoneNumber
+	}
+	return &users[0], nil
+}
+
+// hashCode returns the keyed hash stored in place of a code.
+func (s *Service) hashCode(phoneNumber string, value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(phoneNumber + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomDigits returns n cryptographically random decimal digits.
+func randomDigits(n int) (string, error) {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits), nil
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+// recordingSender keeps the messages it is asked to send.
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *recordingSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// lastCode returns the code in the last message sent.
+func (s *recordingSender) lastCode(t *testing.T) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		t.Fatal("Expected a code to have been sent")
+	}
+	return regexp.MustCompile(`\d{6}`).FindString(s.messages[len(s.messages)-1])
+}
+
+const testPhoneNumber = "01700000000"
+
+// newTestService returns a Service backed by a fresh memory store holding
+// one user with testPhoneNumber.
+func newTestService(t *testing.T) (*Service, *recordingSender, *time.Time) {
+	t.Helper()
+	store := database.NewMemoryStore()
+	user := structure.User{Name: "Rahim", PhoneNumber: testPhoneNumber, NID: "1234567890123"}
+	if err := store.Create(context.Background(), "user", &user); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+
+	sender := &recordingSender{}
+	service := NewService(store, sender, []byte("test-secret-test-secret-test-secret"), DefaultOptions())
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, sender, &now
+}
+
+// login requests and verifies a code for testPhoneNumber.
+func login(t *testing.T, service *Service, sender *recordingSender) Tokens {
+	t.Helper()
+	ctx := context.Background()
+	if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+		t.Fatalf("Failed to request code: %v", err)
+	}
+	tokens, err := service.VerifyCode(ctx, testPhoneNumber, sender.lastCode(t))
+	if err != nil {
+		t.Fatalf("Failed to verify code: %v", err)
+	}
+	return tokens
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	// Arrange
+	service, sender, _ := newTestService(t)
+
+	// Act
+	tokens := login(t, service, sender)
+	user, claims, err := service.Authenticate(context.Background(), tokens.AccessToken)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	if user.PhoneNumber != testPhoneNumber || claims.Subject != user.ID.Hex() {
+		t.Errorf("Authenticated the wrong user: %+v", user)
+	}
+	if _, _, err := service.Authenticate(context.Background(), tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a refresh token to be rejected as an access token, got %v", err)
+	}
+}
+
+func TestIdentifyUser(t *testing.T) {
+	service, sender, _ := newTestService(t)
+	tokens := login(t, service, sender)
+	var identified *structure.User
+	handler := service.IdentifyUser(func(w http.ResponseWriter, r *http.Request) {
+		identified, _ = UserFromContext(r.Context())
+	})
+
+	for _, token := range []string{tokens.AccessToken, "", "not-a-token"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		identified = nil
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected the request to be served with token %q, got %v", token, rr.Code)
+		}
+		if want := token == tokens.AccessToken; (identified != nil) != want {
+			t.Errorf("Expected user identified %v with token %q, got %+v", want, token, identified)
+		}
+	}
+}
+
+func TestVerifyCodeRejectsReuseAndGuessing(t *testing.T) {
+	// Arrange
+	service, sender, _ := newTestService(t)
+	ctx := context.Background()
+	login(t, service, sender)
+
+	// Act & Assert: a used code cannot be verified again
+	if _, err := service.VerifyCode(ctx, testPhoneNumber, sender.lastCode(t)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected a used code to be rejected, got %v", err)
+	}
+
+	// Act & Assert: after too many wrong guesses even the right code fails
+	if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+		t.Fatalf("Failed to request code: %v", err)
+	}
+	for i := 0; i < service.opts.MaxAttempts; i++ {
+		if _, err := service.VerifyCode(ctx, testPhoneNumber, "not-a-code"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("Expected a wrong code to be rejected, got %v", err)
+		}
+	}
+	if _, err := service.VerifyCode(ctx, testPhoneNumber, sender.lastCode(t)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected the code to be locked after %d wrong attempts, got %v", service.opts.MaxAttempts, err)
+	}
+}
+
+func TestVerifyCodeStartsOneSessionPerCode(t *testing.T) {
+	// Arrange
+	service, sender, _ := newTestService(t)
+	ctx := context.Background()
+	if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+		t.Fatalf("Failed to request code: %v", err)
+	}
+	value := sender.lastCode(t)
+
+	// Act: the same code is verified many times at once
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sessions := 0
+	for i := 0; i < service.opts.MaxAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.VerifyCode(ctx, testPhoneNumber, value); err == nil {
+				mu.Lock()
+				sessions++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	if sessions != 1 {
+		t.Errorf("Expected the code to start exactly one session, started %d", sessions)
+	}
+}
+
+func TestVerifyCodeExpires(t *testing.T) {
+	service, sender, now := newTestService(t)
+	ctx := context.Background()
+	if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+		t.Fatalf("Failed to request code: %v", err)
+	}
+
+	*now = now.Add(service.opts.CodeTTL + time.Second)
+
+	if _, err := service.VerifyCode(ctx, testPhoneNumber, sender.lastCode(t)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected an expired code to be rejected, got %v", err)
+	}
+}
+
+func TestRequestCodeRateLimit(t *testing.T) {
+	// Arrange
+	service, _, now := newTestService(t)
+	ctx := context.Background()
+	for i := 0; i < service.opts.MaxCodes; i++ {
+		if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+			t.Fatalf("Failed to request code %d: %v", i+1, err)
+		}
+	}
+
+	// Act & Assert
+	if err := service.RequestCode(ctx, testPhoneNumber); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the code request to be rate limited, got %v", err)
+	}
+	*now = now.Add(service.opts.RateWindow)
+	if err := service.RequestCode(ctx, testPhoneNumber); err != nil {
+		t.Errorf("Expected a code after the rate window, got %v", err)
+	}
+}
+
+func TestRequestCodeUnknownPhoneNumber(t *testing.T) {
+	service, sender, _ := newTestService(t)
+
+	err := service.RequestCode(context.Background(), "01999999999")
+
+	if !errors.Is(err, ErrUnknownPhoneNumber) {
+		t.Errorf("Expected ErrUnknownPhoneNumber, got %v", err)
+	}
+	if len(sender.messages) != 0 {
+		t.Errorf("Expected no message to be sent, got %v", sender.messages)
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	// Arrange
+	service, sender, _ := newTestService(t)
+	ctx := context.Background()
+	first := login(t, service, sender)
+
+	// Act
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+
+	// Assert: replaying the first refresh token revokes the session
+	if _, err := service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a reused refresh token to be rejected, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the session to be revoked after reuse, got %v", err)
+	}
+	if _, _, err := service.Authenticate(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected access tokens of a revoked session to be rejected, got %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	service, sender, _ := newTestService(t)
+	ctx := context.Background()
+	tokens := login(t, service, sender)
+	_, claims, err := service.Authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	if err := service.Revoke(ctx, claims.SessionID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+
+	if _, _, err := service.Authenticate(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the access token to be rejected after logout, got %v", err)
+	}
+}
+
+func TestSignerRejectsTamperedAndExpiredTokens(t *testing.T) {
+	signer := NewSigner([]byte("test-secret-test-secret-test-secret"))
+	now := time.Now()
+	token, err := signer.Sign(Claims{Subject: "user", Type: TokenTypeAccess, ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	if _, err := signer.Verify(token, now); err != nil {
+		t.Errorf("Expected a valid token, got %v", err)
+	}
+	if _, err := NewSigner([]byte("another-secret-another-secret-xx")).Verify(token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token signed with another secret to be rejected, got %v", err)
+	}
+	if _, err := signer.Verify(token, now.Add(2*time.Minute)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
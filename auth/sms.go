@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers a text message to a phone number.
+type SMSSender interface {
+	Send(ctx context.Context, phoneNumber string, message string) error
+}
+
+// LogSender writes messages to the standard logger instead of sending them.
+// It is meant for local development.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	log.Printf("SMS to %s: %s", phoneNumber, message)
+	return nil
+}
+
+// FileSender appends messages to a file instead of sending them. It is meant
+// for local development and tests.
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open SMS file: %v", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), phoneNumber, message)
+	return err
+}
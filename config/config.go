@@ -0,0 +1,513 @@
+// Package config loads the server configuration.
+//
+// Settings are resolved in this order, each source overriding the previous:
+//
+//  1. built-in defaults
+//  2. a JSON file named by -config or CONFIG_FILE
+//  3. environment variables
+//  4. command-line flags
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the effective configuration of the server.
+type Config struct {
+	Server   Server   `json:"server"`
+	Database Database `json:"database"`
+	Upload   Upload   `json:"upload"`
+	Auth     Auth     `json:"auth"`
+	Auction  Auction  `json:"auction"`
+	Feed     Feed     `json:"feed"`
+	Outbox   Outbox   `json:"outbox"`
+	Webhook  Webhook  `json:"webhook"`
+	Notify   Notify   `json:"notify"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Addr              string   `json:"addr"`
+	UploadAddr        string   `json:"uploadAddr,omitempty"` // serve /upload on its own listener when set
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout"`
+	ReadTimeout       Duration `json:"readTimeout"`
+	WriteTimeout      Duration `json:"writeTimeout"`
+	IdleTimeout       Duration `json:"idleTimeout"`
+	ShutdownTimeout   Duration `json:"shutdownTimeout"`
+}
+
+// Database configures the database backend and its MongoDB connection pool.
+type Database struct {
+	Backend                string   `json:"backend"`
+	URI                    string   `json:"uri"`
+	Name                   string   `json:"name"`
+	MaxPoolSize            uint64   `json:"maxPoolSize"`
+	MinPoolSize            uint64   `json:"minPoolSize"`
+	MaxConnIdleTime        Duration `json:"maxConnIdleTime"`
+	ConnectTimeout         Duration `json:"connectTimeout"`
+	ServerSelectionTimeout Duration `json:"serverSelectionTimeout"`
+	OperationTimeout       Duration `json:"operationTimeout"`
+	AutoMigrate            bool     `json:"autoMigrate"`
+}
+
+// Upload configures the file upload endpoint.
+type Upload struct {
+	Dir              string   `json:"dir"`
+	MaxFileSize      int64    `json:"maxFileSize"`
+	AllowedFileTypes []string `json:"allowedFileTypes"`
+}
+
+// Auth configures phone number login and session tokens.
+type Auth struct {
+	JWTSecret       string   `json:"jwtSecret,omitempty"` // a random secret is generated per process when empty
+	AccessTokenTTL  Duration `json:"accessTokenTTL"`
+	RefreshTokenTTL Duration `json:"refreshTokenTTL"`
+	CodeTTL         Duration `json:"codeTTL"`
+	CodeLength      int      `json:"codeLength"`
+	MaxAttempts     int      `json:"maxAttempts"` // wrong guesses allowed per code
+	MaxCodes        int      `json:"maxCodes"`    // codes a phone number may request per rateWindow
+	RateWindow      Duration `json:"rateWindow"`
+	SMSSender       string   `json:"smsSender"` // log or file
+	SMSFile         string   `json:"smsFile,omitempty"`
+}
+
+// Auction configures how bidding on jobs closes at their deadline.
+type Auction struct {
+	Rule     string   `json:"rule"`     // lowest_bid, best_rating or manual
+	Interval Duration `json:"interval"` // time between looks for expired jobs; 0 disables closing
+	LeaseTTL Duration `json:"leaseTTL"` // how long one replica may close jobs before another takes over
+}
+
+// Feed configures the event streams of jobs and users.
+type Feed struct {
+	History   int      `json:"history"`   // events kept to replay to clients that reconnect
+	Buffer    int      `json:"buffer"`    // events queued per client before it is disconnected as too slow
+	Heartbeat Duration `json:"heartbeat"` // time between heartbeats on idle streams
+}
+
+// Outbox configures the delivery of domain events to their subscribers.
+type Outbox struct {
+	Interval    Duration `json:"interval"`    // time between looks for pending events; 0 disables delivery
+	MaxAttempts int      `json:"maxAttempts"` // failed deliveries before an event is dead-lettered
+	Backoff     Duration `json:"backoff"`     // wait after the first failed delivery, doubled after each further one
+	MaxBackoff  Duration `json:"maxBackoff"`  // longest wait between deliveries
+	LeaseTTL    Duration `json:"leaseTTL"`    // how long one replica may deliver events before another takes over
+}
+
+// Notify configures the notifications sent to users. Text messages use the
+// SMS sender of Auth.
+type Notify struct {
+	EmailSender string `json:"emailSender"` // log or file
+	EmailFile   string `json:"emailFile,omitempty"`
+}
+
+// Webhook configures the delivery of domain events to partner endpoints.
+type Webhook struct {
+	Interval     Duration `json:"interval"`     // time between looks for due deliveries; 0 disables webhooks
+	Timeout      Duration `json:"timeout"`      // how long an endpoint may take to answer
+	MaxAttempts  int      `json:"maxAttempts"`  // failed attempts before a delivery is given up on
+	Backoff      Duration `json:"backoff"`      // wait after the first failed attempt, doubled after each further one
+	MaxBackoff   Duration `json:"maxBackoff"`   // longest wait between attempts
+	DisableAfter int      `json:"disableAfter"` // failed attempts in a row after which a webhook is disabled
+	LeaseTTL     Duration `json:"leaseTTL"`     // how long one replica may send deliveries before another takes over
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":5000",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		Database: Database{
+			Backend:                "mongo",
+			URI:                    "mongodb://localhost:27017",
+			Name:                   "sumon",
+			MaxPoolSize:            100,
+			MaxConnIdleTime:        Duration(5 * time.Minute),
+			ConnectTimeout:         Duration(10 * time.Second),
+			ServerSelectionTimeout: Duration(10 * time.Second),
+			OperationTimeout:       Duration(10 * time.Second),
+			AutoMigrate:            true,
+		},
+		Upload: Upload{
+			Dir:              "uploadedfiles",
+			MaxFileSize:      10 << 20, // 10 MB
+			AllowedFileTypes: []string{".jpg", ".jpeg", ".png", ".pdf", ".txt"},
+		},
+		Auth: Auth{
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(30 * 24 * time.Hour),
+			CodeTTL:         Duration(5 * time.Minute),
+			CodeLength:      6,
+			MaxAttempts:     5,
+			MaxCodes:        3,
+			RateWindow:      Duration(15 * time.Minute),
+			SMSSender:       "log",
+		},
+		Auction: Auction{
+			Rule:     "lowest_bid",
+			Interval: Duration(time.Minute),
+			LeaseTTL: Duration(5 * time.Minute),
+		},
+		Feed: Feed{
+			History:   1000,
+			Buffer:    64,
+			Heartbeat: Duration(15 * time.Second),
+		},
+		Outbox: Outbox{
+			Interval:    Duration(time.Second),
+			MaxAttempts: 10,
+			Backoff:     Duration(time.Second),
+			MaxBackoff:  Duration(10 * time.Minute),
+			LeaseTTL:    Duration(time.Minute),
+		},
+		Webhook: Webhook{
+			Interval:     Duration(5 * time.Second),
+			Timeout:      Duration(10 * time.Second),
+			MaxAttempts:  8,
+			Backoff:      Duration(30 * time.Second),
+			MaxBackoff:   Duration(time.Hour),
+			DisableAfter: 20,
+			LeaseTTL:     Duration(5 * time.Minute),
+		},
+		Notify: Notify{
+			EmailSender: "log",
+		},
+	}
+}
+
+// Duration is a time.Duration written in JSON as a string such as "30s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// setting is one configuration value that can be set from the environment
+// or a flag.
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	target func(c *Config) interface{} // pointer to the field
+}
+
+// settings lists every setting that can be overridden by environment
+// variables and flags.
+var settings = []setting{
+	{"http-addr", "HTTP_ADDR", "API listen address", func(c *Config) interface{} { return &c.Server.Addr }},
+	{"upload-addr", "UPLOAD_ADDR", "separate listen address for /upload", func(c *Config) interface{} { return &c.Server.UploadAddr }},
+	{"http-read-header-timeout", "HTTP_READ_HEADER_TIMEOUT", "time allowed to read request headers", func(c *Config) interface{} { return &c.Server.ReadHeaderTimeout }},
+	{"http-read-timeout", "HTTP_READ_TIMEOUT", "time allowed to read a request", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"http-write-timeout", "HTTP_WRITE_TIMEOUT", "time allowed to write a response", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"http-idle-timeout", "HTTP_IDLE_TIMEOUT", "keep-alive idle timeout", func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"http-shutdown-timeout", "HTTP_SHUTDOWN_TIMEOUT", "time allowed for in-flight requests on shutdown", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"db-backend", "DB_BACKEND", "database backend: mongo or memory", func(c *Config) interface{} { return &c.Database.Backend }},
+	{"mongo-uri", "MONGO_URI", "MongoDB connection string", func(c *Config) interface{} { return &c.Database.URI }},
+	{"db-name", "DB_NAME", "MongoDB database name", func(c *Config) interface{} { return &c.Database.Name }},
+	{"db-max-pool-size", "DB_MAX_POOL_SIZE", "maximum MongoDB connections", func(c *Config) interface{} { return &c.Database.MaxPoolSize }},
+	{"db-min-pool-size", "DB_MIN_POOL_SIZE", "minimum MongoDB connections", func(c *Config) interface{} { return &c.Database.MinPoolSize }},
+	{"db-max-conn-idle-time", "DB_MAX_CONN_IDLE_TIME", "how long an idle connection is kept", func(c *Config) interface{} { return &c.Database.MaxConnIdleTime }},
+	{"db-connect-timeout", "DB_CONNECT_TIMEOUT", "MongoDB connect timeout", func(c *Config) interface{} { return &c.Database.ConnectTimeout }},
+	{"db-server-selection-timeout", "DB_SERVER_SELECTION_TIMEOUT", "MongoDB server selection timeout", func(c *Config) interface{} { return &c.Database.ServerSelectionTimeout }},
+	{"db-operation-timeout", "DB_OPERATION_TIMEOUT", "default deadline of each database operation", func(c *Config) interface{} { return &c.Database.OperationTimeout }},
+	{"db-auto-migrate", "DB_AUTO_MIGRATE", "apply pending migrations at startup", func(c *Config) interface{} { return &c.Database.AutoMigrate }},
+	{"upload-dir", "UPLOAD_DIR", "directory uploaded files are stored in", func(c *Config) interface{} { return &c.Upload.Dir }},
+	{"upload-max-file-size", "UPLOAD_MAX_FILE_SIZE", "largest accepted upload in bytes", func(c *Config) interface{} { return &c.Upload.MaxFileSize }},
+	{"upload-allowed-types", "UPLOAD_ALLOWED_TYPES", "comma-separated accepted file extensions", func(c *Config) interface{} { return &c.Upload.AllowedFileTypes }},
+	{"jwt-secret", "JWT_SECRET", "secret that signs session tokens, at least 32 bytes", func(c *Config) interface{} { return &c.Auth.JWTSecret }},
+	{"auth-access-token-ttl", "AUTH_ACCESS_TOKEN_TTL", "lifetime of access tokens", func(c *Config) interface{} { return &c.Auth.AccessTokenTTL }},
+	{"auth-refresh-token-ttl", "AUTH_REFRESH_TOKEN_TTL", "lifetime of sessions and their refresh tokens", func(c *Config) interface{} { return &c.Auth.RefreshTokenTTL }},
+	{"auth-code-ttl", "AUTH_CODE_TTL", "lifetime of login codes", func(c *Config) interface{} { return &c.Auth.CodeTTL }},
+	{"auth-code-length", "AUTH_CODE_LENGTH", "digits in a login code", func(c *Config) interface{} { return &c.Auth.CodeLength }},
+	{"auth-max-attempts", "AUTH_MAX_ATTEMPTS", "wrong guesses allowed per login code", func(c *Config) interface{} { return &c.Auth.MaxAttempts }},
+	{"auth-max-codes", "AUTH_MAX_CODES", "login codes a phone number may request per rate window", func(c *Config) interface{} { return &c.Auth.MaxCodes }},
+	{"auth-rate-window", "AUTH_RATE_WINDOW", "window of the login code rate limit", func(c *Config) interface{} { return &c.Auth.RateWindow }},
+	{"sms-sender", "SMS_SENDER", "how login codes are delivered: log or file", func(c *Config) interface{} { return &c.Auth.SMSSender }},
+	{"sms-file", "SMS_FILE", "file the file SMS sender appends messages to", func(c *Config) interface{} { return &c.Auth.SMSFile }},
+	{"auction-rule", "AUCTION_RULE", "winner of a job whose bidding closes: lowest_bid, best_rating or manual", func(c *Config) interface{} { return &c.Auction.Rule }},
+	{"auction-interval", "AUCTION_INTERVAL", "time between looks for jobs whose bidding has closed, 0 to disable", func(c *Config) interface{} { return &c.Auction.Interval }},
+	{"auction-lease-ttl", "AUCTION_LEASE_TTL", "how long one replica may close jobs before another takes over", func(c *Config) interface{} { return &c.Auction.LeaseTTL }},
+	{"feed-history", "FEED_HISTORY", "events kept to replay to event stream clients that reconnect", func(c *Config) interface{} { return &c.Feed.History }},
+	{"feed-buffer", "FEED_BUFFER", "events queued per event stream client before it is disconnected", func(c *Config) interface{} { return &c.Feed.Buffer }},
+	{"feed-heartbeat", "FEED_HEARTBEAT", "time between heartbeats on idle event streams", func(c *Config) interface{} { return &c.Feed.Heartbeat }},
+	{"outbox-interval", "OUTBOX_INTERVAL", "time between looks for domain events to deliver, 0 to disable", func(c *Config) interface{} { return &c.Outbox.Interval }},
+	{"outbox-max-attempts", "OUTBOX_MAX_ATTEMPTS", "failed deliveries before a domain event is dead-lettered", func(c *Config) interface{} { return &c.Outbox.MaxAttempts }},
+	{"outbox-backoff", "OUTBOX_BACKOFF", "wait after the first failed delivery of a domain event", func(c *Config) interface{} { return &c.Outbox.Backoff }},
+	{"outbox-max-backoff", "OUTBOX_MAX_BACKOFF", "longest wait between deliveries of a domain event", func(c *Config) interface{} { return &c.Outbox.MaxBackoff }},
+	{"outbox-lease-ttl", "OUTBOX_LEASE_TTL", "how long one replica may deliver domain events before another takes over", func(c *Config) interface{} { return &c.Outbox.LeaseTTL }},
+	{"webhook-interval", "WEBHOOK_INTERVAL", "time between looks for webhook deliveries to send, 0 to disable", func(c *Config) interface{} { return &c.Webhook.Interval }},
+	{"webhook-timeout", "WEBHOOK_TIMEOUT", "how long a webhook endpoint may take to answer", func(c *Config) interface{} { return &c.Webhook.Timeout }},
+	{"webhook-max-attempts", "WEBHOOK_MAX_ATTEMPTS", "failed attempts before a webhook delivery is given up on", func(c *Config) interface{} { return &c.Webhook.MaxAttempts }},
+	{"webhook-backoff", "WEBHOOK_BACKOFF", "wait after the first failed attempt of a webhook delivery", func(c *Config) interface{} { return &c.Webhook.Backoff }},
+	{"webhook-max-backoff", "WEBHOOK_MAX_BACKOFF", "longest wait between attempts of a webhook delivery", func(c *Config) interface{} { return &c.Webhook.MaxBackoff }},
+	{"webhook-disable-after", "WEBHOOK_DISABLE_AFTER", "failed attempts in a row after which a webhook is disabled", func(c *Config) interface{} { return &c.Webhook.DisableAfter }},
+	{"webhook-lease-ttl", "WEBHOOK_LEASE_TTL", "how long one replica may send webhook deliveries before another takes over", func(c *Config) interface{} { return &c.Webhook.LeaseTTL }},
+	{"email-sender", "EMAIL_SENDER", "how notification emails are delivered: log or file", func(c *Config) interface{} { return &c.Notify.EmailSender }},
+	{"email-file", "EMAIL_FILE", "file the file email sender appends emails to", func(c *Config) interface{} { return &c.Notify.EmailFile }},
+}
+
+// set parses raw into the field that target points to.
+func set(target interface{}, raw string) error {
+	switch field := target.(type) {
+	case *string:
+		*field = raw
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		*field = v
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*field = v
+	case *uint64:
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field = v
+	case *int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field = v
+	case *Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		*field = Duration(v)
+	case *[]string:
+		*field = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field = append(*field, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// Invocation holds the command-line arguments that are not settings.
+type Invocation struct {
+	PrintConfig bool     // -print-config was given
+	Args        []string // arguments after the flags, e.g. "migrate up"
+}
+
+// Load resolves the configuration from the defaults, the config file, the
+// environment and the command-line arguments, then validates it.
+func Load(args []string, getenv func(string) string) (Config, Invocation, error) {
+	// Collect the flags first; they are applied last
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "JSON configuration file")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		name := s.flag
+		fs.Func(name, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(raw string) error {
+			flagValues[name] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, Invocation{}, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return Config{}, Invocation{}, err
+		}
+	}
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := set(s.target(&cfg), raw); err != nil {
+				return Config{}, Invocation{}, fmt.Errorf("invalid %s: %v", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if raw, ok := flagValues[s.flag]; ok {
+			if err := set(s.target(&cfg), raw); err != nil {
+				return Config{}, Invocation{}, fmt.Errorf("invalid -%s: %v", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, Invocation{}, err
+	}
+	return cfg, Invocation{PrintConfig: *printConfig, Args: fs.Args()}, nil
+}
+
+// loadFile overrides the configuration with the fields set in a JSON file.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must be set")
+	check(c.Server.ReadHeaderTimeout >= 0 && c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0,
+		"server timeouts must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+
+	check(c.Database.Backend == "mongo" || c.Database.Backend == "memory",
+		"database.backend must be mongo or memory, got %q", c.Database.Backend)
+	if c.Database.Backend == "mongo" {
+		check(strings.HasPrefix(c.Database.URI, "mongodb://") || strings.HasPrefix(c.Database.URI, "mongodb+srv://"),
+			"database.uri must start with mongodb:// or mongodb+srv://")
+		check(c.Database.Name != "", "database.name must be set")
+		check(c.Database.MaxPoolSize == 0 || c.Database.MinPoolSize <= c.Database.MaxPoolSize,
+			"database.minPoolSize must not exceed database.maxPoolSize")
+		check(c.Database.OperationTimeout >= 0, "database.operationTimeout must not be negative")
+	}
+
+	check(c.Upload.Dir != "", "upload.dir must be set")
+	check(c.Upload.MaxFileSize > 0, "upload.maxFileSize must be positive")
+	for _, ext := range c.Upload.AllowedFileTypes {
+		check(strings.HasPrefix(ext, "."), "upload.allowedFileTypes entry %q must start with a dot", ext)
+	}
+
+	check(c.Auth.JWTSecret == "" || len(c.Auth.JWTSecret) >= 32, "auth.jwtSecret must be at least 32 bytes")
+	check(c.Auth.AccessTokenTTL > 0 && c.Auth.RefreshTokenTTL > 0 && c.Auth.CodeTTL > 0 && c.Auth.RateWindow > 0,
+		"auth durations must be positive")
+	check(c.Auth.RateWindow <= Duration(24*time.Hour), "auth.rateWindow must not exceed 24h, how long login codes are kept")
+	check(c.Auth.CodeLength >= 4 && c.Auth.CodeLength <= 10, "auth.codeLength must be between 4 and 10")
+	check(c.Auth.MaxAttempts > 0, "auth.maxAttempts must be positive")
+	check(c.Auth.MaxCodes > 0, "auth.maxCodes must be positive")
+	check(c.Auth.SMSSender == "log" || c.Auth.SMSSender == "file",
+		"auth.smsSender must be log or file, got %q", c.Auth.SMSSender)
+	if c.Auth.SMSSender == "file" {
+		check(c.Auth.SMSFile != "", "auth.smsFile must be set when auth.smsSender is file")
+	}
+
+	check(c.Auction.Rule == "lowest_bid" || c.Auction.Rule == "best_rating" || c.Auction.Rule == "manual",
+		"auction.rule must be lowest_bid, best_rating or manual, got %q", c.Auction.Rule)
+	check(c.Auction.Interval >= 0, "auction.interval must not be negative")
+	if c.Auction.Interval > 0 {
+		check(c.Auction.LeaseTTL > c.Auction.Interval, "auction.leaseTTL must be longer than auction.interval")
+	}
+
+	check(c.Feed.History >= 0, "feed.history must not be negative")
+	check(c.Feed.Buffer > 0, "feed.buffer must be positive")
+	check(c.Feed.Heartbeat > 0, "feed.heartbeat must be positive")
+
+	check(c.Outbox.Interval >= 0, "outbox.interval must not be negative")
+	if c.Outbox.Interval > 0 {
+		check(c.Outbox.MaxAttempts > 0, "outbox.maxAttempts must be positive")
+		check(c.Outbox.Backoff > 0 && c.Outbox.MaxBackoff >= c.Outbox.Backoff,
+			"outbox.backoff must be positive and no longer than outbox.maxBackoff")
+		check(c.Outbox.LeaseTTL > c.Outbox.Interval, "outbox.leaseTTL must be longer than outbox.interval")
+	}
+
+	check(c.Webhook.Interval >= 0, "webhook.interval must not be negative")
+	if c.Webhook.Interval > 0 {
+		check(c.Webhook.Timeout > 0, "webhook.timeout must be positive")
+		check(c.Webhook.MaxAttempts > 0, "webhook.maxAttempts must be positive")
+		check(c.Webhook.Backoff > 0 && c.Webhook.MaxBackoff >= c.Webhook.Backoff,
+			"webhook.backoff must be positive and no longer than webhook.maxBackoff")
+		check(c.Webhook.DisableAfter > 0, "webhook.disableAfter must be positive")
+		check(c.Webhook.LeaseTTL > c.Webhook.Interval, "webhook.leaseTTL must be longer than webhook.interval")
+	}
+
+	check(c.Notify.EmailSender == "log" || c.Notify.EmailSender == "file",
+		"notify.emailSender must be log or file, got %q", c.Notify.EmailSender)
+	if c.Notify.EmailSender == "file" {
+		check(c.Notify.EmailFile != "", "notify.emailFile must be set when notify.emailSender is file")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with secrets, such as the
+// password in the MongoDB URI and the JWT secret, replaced.
+func (c Config) Redacted() Config {
+	c.Database.URI = redactURI(c.Database.URI)
+	if c.Auth.JWTSecret != "" {
+		c.Auth.JWTSecret = "REDACTED"
+	}
+	return c
+}
+
+// redactURI replaces the password in a connection string. It does not use
+// url.Parse, which rejects the comma-separated host lists of MongoDB URIs.
+func redactURI(uri string) string {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return uri
+	}
+	authority, path, hasPath := strings.Cut(rest, "/")
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return uri
+	}
+	user, _, hasPassword := strings.Cut(authority[:at], ":")
+	if !hasPassword {
+		return uri
+	}
+
+	redacted := scheme + "://" + user + ":REDACTED" + authority[at:]
+	if hasPath {
+		redacted += "/" + path
+	}
+	return redacted
+}
+
+// Print writes the configuration to w as indented JSON with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.Redacted())
+}
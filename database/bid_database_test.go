@@ -25,7 +25,7 @@ func TestGetAllBid(t *testing.T) {
 				ID:          id, // Assign the ObjectID directly
 				Description: fmt.Sprintf("Bid %d", i+1),
 				Time:        fmt.Sprintf("%d hours", i+1),
-				BidAmount:   float64((i + 1) * 100),
+				BidAmount:   structure.Taka(int64((i + 1) * 100)),
 				PostedTime:  postTime,
 			}
 			bids = append(bids, bid)
@@ -62,7 +62,7 @@ func TestCreateBid(t *testing.T) {
 		bid := structure.Bid{
 			Description: "Bid for project XYZ",
 			Time:        "2 hours",
-			BidAmount:   200,
+			BidAmount:   structure.Taka(200),
 			PostedTime:  postTime,
 		}
 
@@ -103,14 +103,14 @@ func TestGetBid(t *testing.T) {
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project XYZ",
 			Time:        "2 hours",
-			BidAmount:   200,
+			BidAmount:   structure.Taka(200),
 			PostedTime:  postTime,
 		}
 		expectedBid2 := structure.Bid{
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project ABC",
 			Time:        "3 hours",
-			BidAmount:   300,
+			BidAmount:   structure.Taka(300),
 			PostedTime:  postTime,
 		}
 		if err := Create(collectionName, &expectedBid1); err != nil {
@@ -143,7 +143,7 @@ func TestUpdateBid(t *testing.T) {
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project XYZ",
 			Time:        "2 hours",
-			BidAmount:   200,
+			BidAmount:   structure.Taka(200),
 			PostedTime:  time.Now(),
 		}
 		if err := Create(collectionName, &expectedBid); err != nil {
@@ -188,14 +188,14 @@ func TestDeleteBid(t *testing.T) {
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project XYZ",
 			Time:        "2 hours",
-			BidAmount:   200,
+			BidAmount:   structure.Taka(200),
 			PostedTime:  postTime,
 		}
 		expectedBid2 := structure.Bid{
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project ABC",
 			Time:        "3 hours",
-			BidAmount:   300,
+			BidAmount:   structure.Taka(300),
 			PostedTime:  postTime,
 		}
 		if err := Create(collectionName, &expectedBid1); err != nil {
@@ -233,14 +233,14 @@ func TestFindBid(t *testing.T) {
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project XYZ",
 			Time:        "2 hours",
-			BidAmount:   200,
+			BidAmount:   structure.Taka(200),
 			PostedTime:  postTime,
 		}
 		expectedBid2 := structure.Bid{
 			ID:          primitive.NewObjectID(),
 			Description: "Bid for project ABC",
 			Time:        "3 hours",
-			BidAmount:   300,
+			BidAmount:   structure.Taka(300),
 			PostedTime:  postTime,
 		}
 		if err := Create(collectionName, &expectedBid1); err != nil {
@@ -0,0 +1,429 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrJobNotOpen is returned when bidding on a job, or accepting or rejecting
+// one of its bids, after the job has left the job_posted status.
+var ErrJobNotOpen = errors.New("job is not open for bids")
+
+// ErrBiddingClosed is returned when bidding on an open job after its bidding
+// deadline.
+var ErrBiddingClosed = errors.New("bidding on this job has closed")
+
+// ErrBidDecided is returned when changing a bid that has already been
+// accepted or rejected.
+var ErrBidDecided = errors.New("bid has already been decided")
+
+// ErrBidNotForJob is returned when accepting or rejecting a bid that was made
+// on another job.
+var ErrBidNotForJob = errors.New("bid is not for this job")
+
+func PlaceBid(jobID string, bid *structure.Bid) error {
+	return Default().PlaceBid(context.Background(), jobID, bid)
+}
+
+func AcceptBid(jobID string, bidID string, actor primitive.ObjectID, reason string) error {
+	return Default().AcceptBid(context.Background(), jobID, bidID, actor, reason)
+}
+
+func RejectBid(jobID string, bidID string) error {
+	return Default().RejectBid(context.Background(), jobID, bidID)
+}
+
+func UpdateBid(bidID string, update bson.M) error {
+	return Default().UpdateBid(context.Background(), bidID, update)
+}
+
+func DeleteBid(bidID string) error {
+	return Default().DeleteBid(context.Background(), bidID)
+}
+
+// maxBidCopyAttempts bounds the retries of replacing a job's copy of a bid
+// while other bids are being placed on the job.
+const maxBidCopyAttempts = 5
+
+// placeBid validates the Bid and records it as a pending bid on the open job
+// with ID jobID, adding a copy to the job's bids and BidPlaced to the outbox,
+// as a single atomic write. The copy is pushed onto the job only while it is
+// still open, so concurrent bids are all kept.
+// It returns ErrBiddingClosed once the job's bidding deadline has passed.
+func placeBid(ctx context.Context, db Database, jobID string, bid *structure.Bid) error {
+	if err := bid.Validate(); err != nil {
+		return err
+	}
+
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		job, err := openJob(ctx, db, jobID)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if job.BiddingOver(now) {
+			return ErrBiddingClosed
+		}
+
+		// The server owns the bid's ID, job, status and posting time
+		bid.ID = primitive.NilObjectID
+		bid.JobID = job.ID
+		bid.Status = structure.StatusPending
+		bid.PostedTime = now
+		if err := db.Create(ctx, "bid", bid); err != nil {
+			return err
+		}
+		bidID := bid.ID.Hex()
+		c.onFailure(func(ctx context.Context) error {
+			return db.Delete(ctx, "bid", bidID)
+		})
+		placed := structure.BidPlaced{BidID: bid.ID, JobID: job.ID, ServiceProviderID: bid.SPID, BidAmount: bid.BidAmount}
+		if err := recordEvents(ctx, db, c, placed); err != nil {
+			return err
+		}
+
+		// MongoDB cannot $push onto the null stored for a job without bids
+		if job.Bid == nil {
+			err := db.UpdateWhere(ctx, "job", bson.M{"_id": job.ID, "bid": nil}, bson.M{"$set": bson.M{"bid": bson.A{}}}, nil)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		open := bson.M{"_id": job.ID, "jobstatus": structure.JobStatusJobPosted}
+		err = db.UpdateWhere(ctx, "job", open, bson.M{"$push": bson.M{"bid": bid}}, nil)
+		if errors.Is(err, ErrNotFound) {
+			return ErrJobNotOpen
+		}
+		return err
+	})
+}
+
+// acceptBid accepts the bid with ID bidID on the open job with ID jobID and
+// rejects the job's other bids. The job moves to bid_accepted, recording
+// actor as the user who moved it and the reason, and is assigned to the
+// bidder, and the bid's amount is held in the job's escrow. BidAccepted and
+// JobStatusChanged are recorded in the same write. The job is claimed first,
+// only if it is still open and has no accepted bid, so that of two
+// concurrent acceptances one fails with ErrJobNotOpen. Without a
+// transaction, a failed write restores the job and the bids' previous
+// statuses.
+func acceptBid(ctx context.Context, db Database, jobID string, bidID string, actor primitive.ObjectID, reason string) error {
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		job, bid, err := jobBid(ctx, db, jobID, bidID)
+		if err != nil {
+			return err
+		}
+		transition, err := job.Transition(structure.JobStatusBidAccepted, actor, time.Now(), reason)
+		if err != nil {
+			return err
+		}
+		serviceProvider, err := bidder(ctx, db, bid)
+		if err != nil {
+			return err
+		}
+
+		// Claim the job, then decide its bids
+		if err := claimJob(ctx, db, c, &job, bid, serviceProvider, transition); err != nil {
+			return err
+		}
+		var bids []structure.Bid
+		if err := db.Find(ctx, "bid", bson.M{"jobid": job.ID}, &bids); err != nil {
+			return err
+		}
+		decide := func(id primitive.ObjectID) structure.Status {
+			if id == bid.ID {
+				return structure.StatusAccepted
+			}
+			return structure.StatusRejected
+		}
+		for _, other := range bids {
+			if err := setBidStatus(ctx, db, c, other, decide(other.ID)); err != nil {
+				return err
+			}
+		}
+		err = recordEvents(ctx, db, c,
+			structure.BidAccepted{BidID: bid.ID, JobID: job.ID, ServiceProviderID: bid.SPID, Actor: actor},
+			structure.JobStatusChanged{JobID: job.ID, JobTransition: transition},
+		)
+		if err != nil {
+			return err
+		}
+		if err := holdEscrow(ctx, db, c, job, bid); err != nil {
+			return err
+		}
+
+		if !copyBidStatuses(job.Bid, decide) {
+			return nil
+		}
+		return db.Update(ctx, "job", jobID, bson.M{"bid": job.Bid})
+	})
+}
+
+// claimJob moves job to bid_accepted and assigns it to the maker of bid,
+// provided it is still open and has no accepted bid, and reloads job with
+// the claimed document. It returns ErrJobNotOpen when another write got
+// there first, and registers reopening the job as the compensating action.
+func claimJob(ctx context.Context, db Database, c *compensator, job *structure.Job, bid structure.Bid, serviceProvider structure.ServiceProvider, transition structure.JobTransition) error {
+	open := bson.M{"_id": job.ID, "jobstatus": structure.JobStatusJobPosted, "acceptedbid": bson.M{"$exists": false}}
+	claim := bson.M{"$set": bson.M{
+		"jobstatus":         structure.JobStatusBidAccepted,
+		"acceptedbid":       bid.ID,
+		"serviceproviderid": bid.SPID,
+		"serviceproviders":  serviceProvider,
+		"history":           append(job.History, transition),
+	}}
+	previous := *job
+	err := db.UpdateWhere(ctx, "job", open, claim, job)
+	if errors.Is(err, ErrNotFound) {
+		return ErrJobNotOpen
+	}
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{"jobstatus": previous.JobStatus, "serviceproviders": previous.ServiceProviders}
+	unset := bson.M{"acceptedbid": "", "serviceproviderid": ""}
+	if len(previous.History) > 0 {
+		set["history"] = previous.History
+	} else {
+		unset["history"] = ""
+	}
+	c.onFailure(func(ctx context.Context) error {
+		claimed := bson.M{"_id": previous.ID, "acceptedbid": bid.ID}
+		return db.UpdateWhere(ctx, "job", claimed, bson.M{"$set": set, "$unset": unset}, nil)
+	})
+	return nil
+}
+
+// rejectBid rejects the bid with ID bidID on the open job with ID jobID,
+// which stays open for other bids.
+func rejectBid(ctx context.Context, db Database, jobID string, bidID string) error {
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		job, bid, err := jobBid(ctx, db, jobID, bidID)
+		if err != nil {
+			return err
+		}
+		if err := setBidStatus(ctx, db, c, bid, structure.StatusRejected); err != nil {
+			return err
+		}
+
+		changed := copyBidStatuses(job.Bid, func(id primitive.ObjectID) structure.Status {
+			if id == bid.ID {
+				return structure.StatusRejected
+			}
+			return ""
+		})
+		if !changed {
+			return nil
+		}
+		return db.Update(ctx, "job", jobID, bson.M{"bid": job.Bid})
+	})
+}
+
+// updateBid applies update to the undecided bid with ID bidID and to the
+// job's copy of it, while the job is open and its bidding deadline has not
+// passed. Bids on no job are updated on their own. It returns ErrBidDecided
+// once the bid has been accepted or rejected. Without a transaction, a
+// failed write restores the bid.
+func updateBid(ctx context.Context, db Database, bidID string, update bson.M) error {
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		var previous bson.D
+		if err := db.Get(ctx, "bid", bidID, &previous); err != nil {
+			return err
+		}
+		var bid structure.Bid
+		if err := decodeDocument(previous, &bid); err != nil {
+			return err
+		}
+		if bid.Decided() {
+			return ErrBidDecided
+		}
+		if !bid.JobID.IsZero() {
+			job, err := openJob(ctx, db, bid.JobID.Hex())
+			if err != nil {
+				return err
+			}
+			if job.BiddingOver(time.Now().UTC()) {
+				return ErrBiddingClosed
+			}
+		}
+
+		undecided := bson.M{"_id": bid.ID, "status": bson.M{"$nin": bson.A{structure.StatusAccepted, structure.StatusRejected}}}
+		err := db.UpdateWhere(ctx, "bid", undecided, bson.M{"$set": update}, &bid)
+		if errors.Is(err, ErrNotFound) {
+			return ErrBidDecided
+		}
+		if err != nil {
+			return err
+		}
+		restore := restoreUpdate(previous, update)
+		c.onFailure(func(ctx context.Context) error {
+			return db.UpdateWhere(ctx, "bid", bson.M{"_id": bid.ID}, restore, nil)
+		})
+
+		if bid.JobID.IsZero() {
+			return nil
+		}
+		return replaceBidCopy(ctx, db, bid)
+	})
+}
+
+// deleteBid deletes the bid with ID bidID and pulls its copy from its job's
+// bids, as a single atomic write. It returns ErrBidDecided for the job's
+// accepted bid, which the job still refers to. Without a transaction, a
+// failed write restores the bid.
+func deleteBid(ctx context.Context, db Database, bidID string) error {
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		var previous bson.D
+		if err := db.Get(ctx, "bid", bidID, &previous); err != nil {
+			return err
+		}
+		var bid structure.Bid
+		if err := decodeDocument(previous, &bid); err != nil {
+			return err
+		}
+		var job structure.Job
+		if !bid.JobID.IsZero() {
+			err := db.Get(ctx, "job", bid.JobID.Hex(), &job)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			if job.AcceptedBid == bid.ID {
+				return ErrBidDecided
+			}
+		}
+
+		if err := db.Delete(ctx, "bid", bidID); err != nil {
+			return err
+		}
+		c.onFailure(func(ctx context.Context) error {
+			return db.Create(ctx, "bid", previous)
+		})
+
+		found := false
+		for _, copied := range job.Bid {
+			found = found || copied.ID == bid.ID
+		}
+		if !found {
+			return nil
+		}
+		withCopy := bson.M{"_id": job.ID, "bid._id": bid.ID}
+		err := db.UpdateWhere(ctx, "job", withCopy, bson.M{"$pull": bson.M{"bid": bson.M{"_id": bid.ID}}}, nil)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// replaceBidCopy replaces the copy of bid held by its open job. The job's
+// bids are replaced only if they are unchanged since they were read, and
+// read again if bids were placed meanwhile.
+func replaceBidCopy(ctx context.Context, db Database, bid structure.Bid) error {
+	for attempt := 1; ; attempt++ {
+		job, err := openJob(ctx, db, bid.JobID.Hex())
+		if err != nil {
+			return err
+		}
+		bids := append([]structure.Bid(nil), job.Bid...)
+		found := false
+		for i := range bids {
+			if bids[i].ID == bid.ID {
+				bids[i], found = bid, true
+			}
+		}
+		if !found {
+			return nil
+		}
+
+		unchanged := bson.M{"_id": job.ID, "jobstatus": structure.JobStatusJobPosted, "bid": job.Bid}
+		err = db.UpdateWhere(ctx, "job", unchanged, bson.M{"$set": bson.M{"bid": bids}}, nil)
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if attempt == maxBidCopyAttempts {
+			return fmt.Errorf("failed to update the bids of job %s: %w", job.ID.Hex(), err)
+		}
+	}
+}
+
+// openJob gets the job with ID jobID, returning ErrJobNotOpen unless it is in
+// the job_posted status.
+func openJob(ctx context.Context, db Database, jobID string) (structure.Job, error) {
+	var job structure.Job
+	if err := db.Get(ctx, "job", jobID, &job); err != nil {
+		return job, err
+	}
+	if job.JobStatus != structure.JobStatusJobPosted {
+		return job, ErrJobNotOpen
+	}
+	return job, nil
+}
+
+// jobBid gets the open job with ID jobID and its bid with ID bidID.
+func jobBid(ctx context.Context, db Database, jobID string, bidID string) (structure.Job, structure.Bid, error) {
+	var bid structure.Bid
+	job, err := openJob(ctx, db, jobID)
+	if err != nil {
+		return job, bid, err
+	}
+	if err := db.Get(ctx, "bid", bidID, &bid); err != nil {
+		return job, bid, err
+	}
+	if bid.JobID != job.ID {
+		return job, bid, ErrBidNotForJob
+	}
+	return job, bid, nil
+}
+
+// bidder returns the service provider who made bid, falling back to their
+// user when they have no service provider document.
+func bidder(ctx context.Context, db Database, bid structure.Bid) (structure.ServiceProvider, error) {
+	serviceProvider := structure.ServiceProvider{ID: bid.SPID}
+	err := db.Get(ctx, "serviceProvider", bid.SPID.Hex(), &serviceProvider)
+	if !errors.Is(err, ErrNotFound) {
+		return serviceProvider, err
+	}
+	err = db.Get(ctx, "user", bid.SPID.Hex(), &serviceProvider.User)
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	return serviceProvider, err
+}
+
+// setBidStatus sets the status of bid and registers restoring its previous
+// status as the compensating action.
+func setBidStatus(ctx context.Context, db Database, c *compensator, bid structure.Bid, status structure.Status) error {
+	if bid.Status == status {
+		return nil
+	}
+	id := bid.ID.Hex()
+	if err := db.Update(ctx, "bid", id, bson.M{"status": status}); err != nil {
+		return err
+	}
+	previous := bid.Status
+	c.onFailure(func(ctx context.Context) error {
+		return db.Update(ctx, "bid", id, bson.M{"status": previous})
+	})
+	return nil
+}
+
+// copyBidStatuses sets the status of the job's copies of its bids to the
+// status decide returns for their ID, leaving those it returns "" for. It
+// reports whether any copy changed.
+func copyBidStatuses(bids []structure.Bid, decide func(id primitive.ObjectID) structure.Status) bool {
+	changed := false
+	for i := range bids {
+		if status := decide(bids[i].ID); status != "" && bids[i].Status != status {
+			bids[i].Status = status
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// racingStore is a MemoryStore whose Gets from one collection wait for each
+// other, so that concurrent callers all act on the same state.
+type racingStore struct {
+	*MemoryStore
+	mu         sync.Mutex
+	collection string
+	waiting    int           // Gets still to arrive before any returns
+	arrived    chan struct{} // closed once they have
+}
+
+// race makes the next readers Gets from collection wait for each other.
+func (s *racingStore) race(collection string, readers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection, s.waiting, s.arrived = collection, readers, make(chan struct{})
+}
+
+func (s *racingStore) Get(ctx context.Context, collectionName string, id string, result interface{}) error {
+	err := s.MemoryStore.Get(ctx, collectionName, id, result)
+	s.mu.Lock()
+	var arrived chan struct{}
+	if collectionName == s.collection && s.waiting > 0 {
+		arrived = s.arrived
+		if s.waiting--; s.waiting == 0 {
+			close(arrived)
+		}
+	}
+	s.mu.Unlock()
+	if arrived != nil {
+		<-arrived
+	}
+	return err
+}
+
+// pullFailingStore is a MemoryStore whose conditional updates fail.
+type pullFailingStore struct {
+	*MemoryStore
+}
+
+func (s *pullFailingStore) UpdateWhere(ctx context.Context, collectionName string, filter interface{}, update interface{}, result interface{}) error {
+	return errors.New("update failed")
+}
+
+// newBiddingJob inserts an open job and returns it with two bids placed on it.
+func newBiddingJob(t *testing.T, store Database) (structure.Job, structure.Bid, structure.Bid) {
+	t.Helper()
+	ctx := context.Background()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted}
+	if err := store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+
+	place := func(amount structure.Money) structure.Bid {
+		bid := structure.Bid{Description: "I can do it", BidAmount: amount, SPID: primitive.NewObjectID()}
+		if err := placeBid(ctx, store, job.ID.Hex(), &bid); err != nil {
+			t.Fatalf("Failed to place bid: %v", err)
+		}
+		return bid
+	}
+	return job, place(structure.Taka(100)), place(structure.Taka(200))
+}
+
+func TestPlaceBid(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+
+	// Act
+	job, first, second := newBiddingJob(t, store)
+
+	// Assert
+	if first.Status != structure.StatusPending || first.JobID != job.ID || first.PostedTime.IsZero() {
+		t.Errorf("Expected a pending bid on the job, got %+v", first)
+	}
+	if err := store.Get(context.Background(), "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if len(job.Bid) != 2 || job.Bid[0].ID != first.ID || job.Bid[1].ID != second.ID {
+		t.Errorf("Expected the job to hold copies of both bids, got %+v", job.Bid)
+	}
+}
+
+func TestPlaceBidKeepsConcurrentBids(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &racingStore{MemoryStore: NewMemoryStore()}
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted}
+	if err := store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+
+	// Act: every bidder reads the job before any adds their bid
+	const bidders = 10
+	store.race("job", bidders)
+	var wg sync.WaitGroup
+	for i := 0; i < bidders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bid := structure.Bid{Description: "I can do it", BidAmount: structure.Taka(100), SPID: primitive.NewObjectID()}
+			if err := placeBid(ctx, store, job.ID.Hex(), &bid); err != nil {
+				t.Errorf("Failed to place bid: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if len(job.Bid) != bidders {
+		t.Errorf("Expected the job to hold copies of all %d bids, got %d", bidders, len(job.Bid))
+	}
+}
+
+func TestPlaceBidRequiresOpenJob(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusCompleted}
+	if err := store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+
+	bid := structure.Bid{Description: "I can do it", BidAmount: structure.Taka(100)}
+	err := placeBid(ctx, store, job.ID.Hex(), &bid)
+
+	if !errors.Is(err, ErrJobNotOpen) {
+		t.Errorf("Expected ErrJobNotOpen, got %v", err)
+	}
+	if count, _ := store.Count(ctx, "bid", bson.M{}); count != 0 {
+		t.Errorf("Expected no bid to be stored, found %d", count)
+	}
+}
+
+func TestAcceptBid(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, first, second := newBiddingJob(t, store)
+	owner := primitive.NewObjectID()
+
+	// Act
+	if err := acceptBid(ctx, store, job.ID.Hex(), second.ID.Hex(), owner, ""); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+
+	// Assert
+	for _, want := range []struct {
+		bid    structure.Bid
+		status structure.Status
+	}{{first, structure.StatusRejected}, {second, structure.StatusAccepted}} {
+		var bid structure.Bid
+		if err := store.Get(ctx, "bid", want.bid.ID.Hex(), &bid); err != nil {
+			t.Fatalf("Failed to get bid document: %v", err)
+		}
+		if bid.Status != want.status {
+			t.Errorf("Expected bid %s to be %s, got %s", bid.ID.Hex(), want.status, bid.Status)
+		}
+	}
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if job.JobStatus != structure.JobStatusBidAccepted || job.AcceptedBid != second.ID || job.ServiceProviderID != second.SPID || job.ServiceProviders.ID != second.SPID {
+		t.Errorf("Expected the job to be assigned to the winning bidder, got %+v", job)
+	}
+	if job.Bid[0].Status != structure.StatusRejected || job.Bid[1].Status != structure.StatusAccepted {
+		t.Errorf("Expected the job's copies of its bids to be decided, got %+v", job.Bid)
+	}
+	if len(job.History) != 1 || job.History[0].From != structure.JobStatusJobPosted || job.History[0].To != structure.JobStatusBidAccepted || job.History[0].Actor != owner {
+		t.Errorf("Expected the job's history to record the acceptance, got %+v", job.History)
+	}
+
+	// A job accepts only one bid
+	if err := acceptBid(ctx, store, job.ID.Hex(), first.ID.Hex(), owner, ""); !errors.Is(err, ErrJobNotOpen) {
+		t.Errorf("Expected ErrJobNotOpen accepting a second bid, got %v", err)
+	}
+}
+
+func TestAcceptBidAcceptsOneOfConcurrentBids(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &racingStore{MemoryStore: NewMemoryStore()}
+	job, first, second := newBiddingJob(t, store)
+	owner := primitive.NewObjectID()
+
+	// Act: both acceptances read the open job before either claims it
+	store.race("job", 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, bid := range []structure.Bid{first, second} {
+		wg.Add(1)
+		go func(i int, bid structure.Bid) {
+			defer wg.Done()
+			errs[i] = acceptBid(ctx, store, job.ID.Hex(), bid.ID.Hex(), owner, "")
+		}(i, bid)
+	}
+	wg.Wait()
+
+	// Assert
+	accepted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrJobNotOpen):
+			t.Errorf("Expected the losing acceptance to fail with ErrJobNotOpen, got %v", err)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("Expected exactly one bid to be accepted, accepted %d", accepted)
+	}
+	if count, _ := store.Count(ctx, "bid", bson.M{"status": structure.StatusAccepted}); count != 1 {
+		t.Errorf("Expected one accepted bid document, found %d", count)
+	}
+}
+
+func TestAcceptBidRestoresBidsOnFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	job, first, second := newBiddingJob(t, store)
+	owner := primitive.NewObjectID()
+
+	// Act
+	store.failCollection = "job"
+	if err := acceptBid(ctx, store, job.ID.Hex(), first.ID.Hex(), owner, ""); err == nil {
+		t.Fatal("Expected AcceptBid to fail when the job update fails")
+	}
+
+	// Assert: both bids are pending again and the job is open
+	for _, placed := range []structure.Bid{first, second} {
+		var bid structure.Bid
+		if err := store.Get(ctx, "bid", placed.ID.Hex(), &bid); err != nil {
+			t.Fatalf("Failed to get bid document: %v", err)
+		}
+		if bid.Status != structure.StatusPending {
+			t.Errorf("Expected bid %s to be pending again, got %s", bid.ID.Hex(), bid.Status)
+		}
+	}
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if job.JobStatus != structure.JobStatusJobPosted || !job.AcceptedBid.IsZero() || !job.ServiceProviderID.IsZero() || len(job.History) != 0 {
+		t.Errorf("Expected the job to be open again, got %+v", job)
+	}
+}
+
+func TestUpdateBidUpdatesJobCopy(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, first, second := newBiddingJob(t, store)
+
+	// Act
+	if err := updateBid(ctx, store, first.ID.Hex(), bson.M{"description": "Cheaper"}); err != nil {
+		t.Fatalf("Failed to update bid: %v", err)
+	}
+
+	// Assert: the bid and the job's copy of it changed
+	var bid structure.Bid
+	if err := store.Get(ctx, "bid", first.ID.Hex(), &bid); err != nil {
+		t.Fatalf("Failed to get bid document: %v", err)
+	}
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if bid.Description != "Cheaper" || job.Bid[0].Description != "Cheaper" || job.Bid[1].Description != second.Description {
+		t.Errorf("Expected the bid and its copy to be updated, got %+v and %+v", bid, job.Bid)
+	}
+
+	// Decided bids cannot change
+	if err := acceptBid(ctx, store, job.ID.Hex(), second.ID.Hex(), primitive.NewObjectID(), ""); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+	if err := updateBid(ctx, store, first.ID.Hex(), bson.M{"description": "Cheaper still"}); !errors.Is(err, ErrBidDecided) {
+		t.Errorf("Expected ErrBidDecided updating a rejected bid, got %v", err)
+	}
+}
+
+func TestDeleteBidPullsJobCopy(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, first, second := newBiddingJob(t, store)
+
+	// Act
+	if err := deleteBid(ctx, store, first.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete bid: %v", err)
+	}
+
+	// Assert: the bid and the job's copy of it are gone
+	var bid structure.Bid
+	if err := store.Get(ctx, "bid", first.ID.Hex(), &bid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the bid to be deleted, got %v", err)
+	}
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if len(job.Bid) != 1 || job.Bid[0].ID != second.ID {
+		t.Errorf("Expected only the other bid to be left on the job, got %+v", job.Bid)
+	}
+
+	// The accepted bid stays while the job refers to it
+	if err := acceptBid(ctx, store, job.ID.Hex(), second.ID.Hex(), primitive.NewObjectID(), ""); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+	if err := deleteBid(ctx, store, second.ID.Hex()); !errors.Is(err, ErrBidDecided) {
+		t.Errorf("Expected ErrBidDecided deleting the accepted bid, got %v", err)
+	}
+}
+
+func TestDeleteBidRestoresBidOnFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &pullFailingStore{MemoryStore: NewMemoryStore()}
+	job, first, _ := newBiddingJob(t, store.MemoryStore)
+
+	// Act
+	if err := deleteBid(ctx, store, first.ID.Hex()); err == nil {
+		t.Fatal("Expected DeleteBid to fail when pulling the job's copy fails")
+	}
+
+	// Assert
+	var bid structure.Bid
+	if err := store.Get(ctx, "bid", first.ID.Hex(), &bid); err != nil {
+		t.Fatalf("Expected the bid to be restored, got %v", err)
+	}
+	if bid.JobID != job.ID || bid.Description != first.Description {
+		t.Errorf("Expected the bid to be restored as it was, got %+v", bid)
+	}
+}
+
+func TestUpdateBidAfterDeadline(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, bid, _ := newBiddingJob(t, store)
+	if err := store.Update(ctx, "job", job.ID.Hex(), bson.M{"biddingdeadline": time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("Failed to update job document: %v", err)
+	}
+
+	err := updateBid(ctx, store, bid.ID.Hex(), bson.M{"description": "Cheaper"})
+
+	if !errors.Is(err, ErrBiddingClosed) {
+		t.Errorf("Expected ErrBiddingClosed, got %v", err)
+	}
+}
+
+func TestRejectBid(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, first, second := newBiddingJob(t, store)
+	other, _, _ := newBiddingJob(t, store)
+
+	if err := rejectBid(ctx, store, job.ID.Hex(), first.ID.Hex()); err != nil {
+		t.Fatalf("Failed to reject bid: %v", err)
+	}
+
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if job.JobStatus != structure.JobStatusJobPosted || job.Bid[0].Status != structure.StatusRejected || job.Bid[1].Status != structure.StatusPending {
+		t.Errorf("Expected only the rejected bid to change, got %+v", job)
+	}
+	if err := rejectBid(ctx, store, other.ID.Hex(), second.ID.Hex()); !errors.Is(err, ErrBidNotForJob) {
+		t.Errorf("Expected ErrBidNotForJob rejecting another job's bid, got %v", err)
+	}
+}
@@ -49,7 +49,7 @@ func TestCreateClient(t *testing.T) {
             // Manually setting other fields
             Name:        "Client 1",
             PhoneNumber: "01711377006",
-            NID:         "198426662698745",
+            NID:         "1984266626987",
             Birthdate:   "05-06-1984",
             FatherName:  "Father 1",
             MotherName:  "Mother 1",
@@ -1,70 +1,124 @@
 package database
 
 import (
+	"Go-sumon/structure"
 	"context"
-	"time"
-
-	"fmt"
+	"errors"
 	"log"
-	"reflect"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
 	dbName = "sumon"
 )
 
+// ErrNotFound is returned by Get, Update and Delete when no document has the
+// given ID.
+var ErrNotFound = errors.New("not found")
+
 // CollectionNamesArray represents an array of collection names.
-var CollectionNamesArray = []string{"review", "bid", "payment", "user", "client", "serviceProvider", "job", "point", "questionAnswer"}
+var CollectionNamesArray = []string{"review", "bid", "payment", "user", "client", "serviceProvider", "job", "point", "questionAnswer", "counters", "otp", "session", "lease", "outbox", "webhook", "webhookDelivery", "notification", "notificationPreferences", "journal"}
 
-// Database represents the interface for database operations.
+// Database represents the interface for database operations. Every
+// operation honours the cancellation and deadline of its context.
 type Database interface {
-	GetAll(collectionName string, result interface{}) error
-	Create(collectionName string, document interface{}) error
-	UserCreate(collectionName string, document interface{}) error
-	ClientCreate(document interface{}) error
-	SPCreate(collectionName string, document interface{}) error
-	Get(collectionName string, id string, result interface{}) error
-	Update(collectionName string, id string, update interface{}) error
-	Delete(collectionName string, id string) error
-	Find(collectionName string, filter interface{}, result interface{}) error
+	GetAll(ctx context.Context, collectionName string, result interface{}) error
+	Create(ctx context.Context, collectionName string, document interface{}) error
+	UserCreate(ctx context.Context, collectionName string, document interface{}) error
+	ClientCreate(ctx context.Context, document interface{}) error
+	SPCreate(ctx context.Context, collectionName string, document interface{}) error
+	Get(ctx context.Context, collectionName string, id string, result interface{}) error
+	Update(ctx context.Context, collectionName string, id string, update interface{}) error
+	// UpdateWhere applies update, a document of $set, $unset, $inc, $push and
+	// $pull operators, to the first document of the collection matching filter. It
+	// decodes the updated document into result unless result is nil, and
+	// returns ErrNotFound when no document matches.
+	UpdateWhere(ctx context.Context, collectionName string, filter interface{}, update interface{}, result interface{}) error
+	Delete(ctx context.Context, collectionName string, id string) error
+	Find(ctx context.Context, collectionName string, filter interface{}, result interface{}) error
+	FindPage(ctx context.Context, collectionName string, filter interface{}, opts FindOptions, result interface{}) (Page, error)
+	Count(ctx context.Context, collectionName string, filter interface{}) (int64, error)
+	ClientUpdate(ctx context.Context, userID string, userData bson.M) error
+	SPUpdate(ctx context.Context, userID string, userData bson.M) error
+	PlaceBid(ctx context.Context, jobID string, bid *structure.Bid) error
+	AcceptBid(ctx context.Context, jobID string, bidID string, actor primitive.ObjectID, reason string) error
+	RejectBid(ctx context.Context, jobID string, bidID string) error
+	UpdateBid(ctx context.Context, bidID string, update bson.M) error
+	DeleteBid(ctx context.Context, bidID string) error
+	CreateReview(ctx context.Context, review *structure.Review) error
+	// MoveJob applies update, which must set the job's new status and
+	// history, recording the transition in the outbox in the same write.
+	MoveJob(ctx context.Context, jobID string, transition structure.JobTransition, update bson.M) error
+	ClearCollection(ctx context.Context, collectionName string) error
+
+	// NextSequence atomically increments and returns the named counter.
+	NextSequence(ctx context.Context, name string) (int64, error)
+	// AdvanceSequence raises the named counter to at least atLeast.
+	AdvanceSequence(ctx context.Context, name string, atLeast int64) error
+
+	// AcquireLease takes or renews the named lease for holder until now+ttl.
+	// It reports false if another holder's lease has not expired by now.
+	AcquireLease(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up the named lease if holder holds it.
+	ReleaseLease(ctx context.Context, name string, holder string) error
+
+	// WithTransaction runs fn in a multi-document transaction, passing it the
+	// context to use for the transaction's operations. It returns
+	// ErrTransactionsUnsupported without calling fn if the backend has none.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
-var collection *mongo.Collection
+var (
+	defaultMu    sync.Mutex
+	defaultStore Database
+)
 
-func initMongoClient(collName string) {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
-	if err != nil {
-		log.Fatal(err)
-	}
+// Default returns the store used by the package-level functions. If none has
+// been set with SetDefault, it opens a pool with DefaultOptions on first use.
+func Default() Database {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
 
-	err = client.Ping(context.Background(), nil)
-	if err != nil {
-		log.Fatal(err)
+	if defaultStore == nil {
+		store, err := OpenDatabase(context.Background(), DefaultOptions())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defaultStore = store
 	}
+	return defaultStore
+}
 
-	// for _, collName := range CollectionNamesArray {
-	//     collection = client.Database(dbName).Collection(collName)
-	// }
-	collection = client.Database(dbName).Collection(collName)
+// SetDefault replaces the store used by the package-level functions.
+func SetDefault(db Database) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStore = db
 }
 
-func ClearCollection(collectionName string) error {
-	if !contains(CollectionNamesArray, collectionName) {
-		return fmt.Errorf("collection %s does not exist", collectionName)
+// Close closes the default store if it holds resources and clears it.
+func Close(ctx context.Context) error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	closer, ok := defaultStore.(interface{ Close(context.Context) error })
+	defaultStore = nil
+	if !ok {
+		return nil
 	}
+	return closer.Close(ctx)
+}
 
-	initMongoClient(collectionName)
+// The package-level functions below operate on the Default store with a
+// background context, bounded only by the store's operation timeout.
 
-	_, err := collection.DeleteMany(context.Background(), bson.D{})
-	if err != nil {
-		return fmt.Errorf("failed to clear collection: %v", err)
-	}
-	return nil
+func ClearCollection(collectionName string) error {
+	return Default().ClearCollection(context.Background(), collectionName)
 }
 
 func contains(slice []string, item string) bool {
@@ -76,146 +130,36 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-// func First[T any](items []T) T {
-// 	return items[0]
-// }
-
-// func GetAll('bid', *Bids[])
-
 func GetAll(collectionName string, result interface{}) error {
-
-	initMongoClient(collectionName)
-
-	cur, err := collection.Find(context.Background(), bson.D{})
-	if err != nil {
-		return fmt.Errorf("failed to find documents in collection %s: %v", collectionName, err)
-	}
-	defer cur.Close(context.Background())
-
-	if err := cur.All(context.Background(), result); err != nil {
-		return fmt.Errorf("failed to decode documents in collection %s: %v", collectionName, err)
-	}
-
-	return nil
+	return Default().GetAll(context.Background(), collectionName, result)
 }
 
-/*
-	func validation(collectionName string, document interface{}, validation_function func){
-		// error nil
-		err := validate(document)
-	}
-
-/*
-*/
-
 func Create(collectionName string, document interface{}) error {
-	// Initialize MongoDB client if not already initialized
-	initMongoClient(collectionName)
-
-	// Insert the document into the collection
-	res, err := collection.InsertOne(context.Background(), document)
-	if err != nil {
-		return fmt.Errorf("failed to insert document into collection %s: %v", collectionName, err)
-	}
-
-	// Set the ObjectID of the inserted document
-	v := reflect.ValueOf(document).Elem()
-	objectIDField := v.FieldByName("ID")
-	if objectIDField.IsValid() && objectIDField.CanSet() {
-		objectIDField.Set(reflect.ValueOf(res.InsertedID))
-	} else {
-		return fmt.Errorf("ID field not found or not settable")
-	}
-
-	return nil
+	return Default().Create(context.Background(), collectionName, document)
 }
 
 func Get(collectionName string, result interface{}, id string) error {
-    // Initialize the MongoDB client and collection
-    initMongoClient(collectionName)
-
-    // Convert id string to primitive.ObjectID
-    objID, err := primitive.ObjectIDFromHex(id)
-    if err != nil {
-        return fmt.Errorf("failed to parse ID: %v", err)
-    }
-
-    // Define the filter to find the document by ID
-    filter := bson.M{"_id": objID}
-
-    // Perform the database query to find the document
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
-    err = collection.FindOne(ctx, filter).Decode(result)
-    if err != nil {
-        if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("document with ID %v not found in collection %s", id, collectionName)
-        }
-        return fmt.Errorf("failed to find document in collection %s: %v", collectionName, err)
-    }
-
-    return nil
+	return Default().Get(context.Background(), collectionName, id, result)
 }
 
 func Update(collectionName string, id string, updateData bson.M) error {
-	// Convert id string to primitive.ObjectID
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return fmt.Errorf("failed to parse ID: %v", err)
-	}
-
-	// Define the update operation
-	update := bson.M{"$set": updateData}
-
-	// Perform the update operation
-	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
-	if err != nil {
-		return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
-	}
-
-	// Check if the document was found and updated
-	if result.ModifiedCount == 0 {
-		return fmt.Errorf("document with ID %s not found in collection %s", id, collectionName)
-	}
-
-	return nil
+	return Default().Update(context.Background(), collectionName, id, updateData)
 }
 
 func Delete(collectionName string, id string) error {
-	// Convert id string to primitive.ObjectID
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return fmt.Errorf("failed to parse ID: %v", err)
-	}
-
-	filter := bson.M{"_id": objID}
-
-	// Perform the deletion operation
-	result, err := collection.DeleteOne(context.Background(), filter)
-	if err != nil {
-		return fmt.Errorf("failed to delete document from collection %s: %v", collectionName, err)
-	}
-
-	// Check if the document was found and deleted
-	if result.DeletedCount == 0 {
-		return fmt.Errorf("document with ID %s not found in collection %s", id, collectionName)
-	}
-
-	return nil
+	return Default().Delete(context.Background(), collectionName, id)
 }
 
 func Find(collectionName string, filter interface{}, result interface{}) error {
-	initMongoClient(collectionName)
-
-	cur, err := collection.Find(context.Background(), filter)
-	if err != nil {
-		return fmt.Errorf("failed to find documents in collection %s: %v", collectionName, err)
-	}
-	defer cur.Close(context.Background())
+	return Default().Find(context.Background(), collectionName, filter, result)
+}
 
-	if err := cur.All(context.Background(), result); err != nil {
-		return fmt.Errorf("failed to decode documents in collection %s: %v", collectionName, err)
-	}
+func Count(collectionName string, filter interface{}) (int64, error) {
+	return Default().Count(context.Background(), collectionName, filter)
+}
 
-	return nil
+// IsTimeout reports whether err was caused by an exceeded deadline, either
+// from the caller's context or from MongoDB itself.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err)
 }
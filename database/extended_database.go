@@ -5,122 +5,217 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ErrPhoneNumberTaken is returned when a user registers with a phone number
+// that another user already has.
+var ErrPhoneNumberTaken = errors.New("phone number already exists")
+
 func UserCreate(userCollection string, document interface{}) error {
+	return Default().UserCreate(context.Background(), userCollection, document)
+}
+
+func ClientCreate(document *structure.Client) error {
+	return Default().ClientCreate(context.Background(), document)
+}
+
+func SPCreate(document *structure.ServiceProvider) error {
+	return Default().SPCreate(context.Background(), "serviceProvider", document)
+}
 
-	initMongoClient(userCollection)
+func ClientUpdate(userID string, userData bson.M) error {
+	return Default().ClientUpdate(context.Background(), userID, userData)
+}
+
+func SPUpdate(userID string, userData bson.M) error {
+	return Default().SPUpdate(context.Background(), userID, userData)
+}
+
+// maxUserCreateAttempts bounds the retries when a new UserID collides with
+// an existing one, e.g. after the counter was reset or restored from backup.
+const maxUserCreateAttempts = 5
 
+// userCreate validates a User and inserts it into collectionName after
+// checking phone number uniqueness and assigning the next UserID from the collection's sequence.
+// UserRegistered is recorded in the same write.
+func userCreate(ctx context.Context, db Database, collectionName string, document interface{}) error {
 	// Check if the document is of type User
 	user, ok := document.(*structure.User)
 	if !ok {
 		return errors.New("document is not of type User")
 	}
+	if err := user.Validate(); err != nil {
+		return err
+	}
 
+	return retryOnDuplicateUserID(ctx, db, collectionName, func() error {
+		return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+			return insertUserWithUndo(ctx, db, c, collectionName, user)
+		})
+	})
+}
+
+// insertUser makes a single attempt at checking the phone number, assigning
+// the next UserID and inserting user.
+func insertUser(ctx context.Context, db Database, collectionName string, user *structure.User) error {
 	// Check if the phone number already exists
 	filter := bson.M{"phonenumber": user.PhoneNumber}
-	existingUsers := []*structure.User{} // Use a slice to hold multiple results
-	err := Find(userCollection, filter, &existingUsers)
-	if err != nil {
-		return fmt.Errorf("failed to check phone number uniqueness: %v", err)
+	existingUsers := []*structure.User{}
+	if err := db.Find(ctx, collectionName, filter, &existingUsers); err != nil {
+		return fmt.Errorf("failed to check phone number uniqueness: %w", err)
 	}
-
-	// If any existing users found, check for uniqueness
 	if len(existingUsers) > 0 {
-		return errors.New("cannot insert data because phone number already exists")
+		return fmt.Errorf("cannot insert data because %w", ErrPhoneNumberTaken)
 	}
 
-	// Determine the current maximum UserID (if applicable)
-	maxUserID := 0
-	count, err := collection.CountDocuments(context.Background(), bson.D{})
+	// Assign the next UserID
+	next, err := db.NextSequence(ctx, collectionName)
 	if err != nil {
-		return fmt.Errorf("failed to count existing users: %v", err)
-	}
-	maxUserID = int(count) + 1
-
-	// Set the UserID for the new document
-	v := reflect.ValueOf(document).Elem()
-	userIDField := v.FieldByName("UserID")
-	if userIDField.IsValid() && userIDField.CanSet() && maxUserID > 0 {
-		userIDField.SetInt(int64(maxUserID))
-	} else {
-		return errors.New("UserID field not found or not settable")
+		return fmt.Errorf("failed to allocate UserID: %w", err)
 	}
+	user.UserID = int(next)
 
-	// Call the create function passing the collection and document
-	err = Create(userCollection, document)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return db.Create(ctx, collectionName, user)
 }
 
-func ClientCreate(document *structure.Client) error {
-	// Call the UserCreate function to create the user document
-	err := UserCreate("user", &document.User)
-	if err != nil {
-		return err
-	}
-
-	// If user type is "client", insert the user document into the client collection
-	if document.User.UserType == "client" {
-		// Call the create function passing the collection and document
-		err = Create("client", document)
-		if err != nil {
+// retryOnDuplicateUserID runs insert until it stops failing with a duplicate
+// key, moving the UserID sequence past the stored UserIDs between attempts.
+func retryOnDuplicateUserID(ctx context.Context, db Database, collectionName string, insert func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := insert()
+		if !IsDuplicateKey(err) || attempt == maxUserCreateAttempts {
+			return err
+		}
+		if err := advancePastMaxUserID(ctx, db, collectionName); err != nil {
 			return err
 		}
 	}
-
-	return nil
 }
 
-func SPCreate(document *structure.ServiceProvider) error {
-	// Call the UserCreate function to create the user document
-	err := UserCreate("user", &document.User)
-	if err != nil {
-		return err
+// advancePastMaxUserID raises the UserID sequence to the highest stored UserID.
+func advancePastMaxUserID(ctx context.Context, db Database, collectionName string) error {
+	var users []structure.User
+	opts := FindOptions{Limit: 1, Sort: bson.D{{Key: "userid", Value: -1}}, Fields: []string{"userid"}}
+	if _, err := db.FindPage(ctx, collectionName, bson.D{}, opts, &users); err != nil {
+		return fmt.Errorf("failed to find highest UserID: %w", err)
 	}
-
-	// If user type is "serviceProvider", insert the user document into the serviceProvider collection
-	if document.User.UserType == "serviceProvider" {
-		// Call the create function passing the collection and document
-		err = Create("serviceProvider", document)
-		if err != nil {
-			return err
-		}
+	if len(users) == 0 {
+		return nil
 	}
-
-	return nil
+	return db.AdvanceSequence(ctx, collectionName, int64(users[0].UserID))
 }
 
-func ClientUpdate(userID string, userData bson.M) error {
-	// Update user collection
-	if err := Update("user", userID, userData); err != nil {
-		return err
+// clientCreate validates the Client and inserts its user and then the client
+// document, which shares the user's ID, as a single atomic write.
+func clientCreate(ctx context.Context, db Database, document interface{}) error {
+	client, ok := document.(*structure.Client)
+	if !ok {
+		return errors.New("document is not of type Client")
 	}
-
-	// Update client collection
-	if err := Update("client", userID, userData); err != nil {
+	if err := client.Validate(); err != nil {
 		return err
 	}
 
-	return nil
+	return retryOnDuplicateUserID(ctx, db, "user", func() error {
+		return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+			// Create the user document first
+			if err := insertUserWithUndo(ctx, db, c, "user", &client.User); err != nil {
+				return err
+			}
+
+			// If user type is "client", insert the document into the client collection
+			if client.User.UserType == structure.UserTypeClient {
+				client.ID = client.User.ID
+				return db.Create(ctx, "client", client)
+			}
+			return nil
+		})
+	})
 }
 
-func SPUpdate(userID string, userData bson.M) error {
-	// Update user collection
-	if err := Update("user", userID, userData); err != nil {
+// spCreate validates the ServiceProvider and inserts its user and then the
+// service provider document into collectionName, sharing the user's ID, as a
+// single atomic write.
+func spCreate(ctx context.Context, db Database, collectionName string, document interface{}) error {
+	serviceProvider, ok := document.(*structure.ServiceProvider)
+	if !ok {
+		return errors.New("document is not of type ServiceProvider")
+	}
+	if err := serviceProvider.Validate(); err != nil {
 		return err
 	}
 
-	// Update serviceProvider collection
-	if err := Update("serviceProvider", userID, userData); err != nil {
+	return retryOnDuplicateUserID(ctx, db, "user", func() error {
+		return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+			// Create the user document first
+			if err := insertUserWithUndo(ctx, db, c, "user", &serviceProvider.User); err != nil {
+				return err
+			}
+
+			// If user type is "serviceProvider", insert the document into the given collection
+			if serviceProvider.User.UserType == structure.UserTypeServiceProvider {
+				serviceProvider.ID = serviceProvider.User.ID
+				return db.Create(ctx, collectionName, serviceProvider)
+			}
+			return nil
+		})
+	})
+}
+
+// insertUserWithUndo inserts user into collectionName and records
+// UserRegistered, registering their deletion as the compensating actions.
+func insertUserWithUndo(ctx context.Context, db Database, c *compensator, collectionName string, user *structure.User) error {
+	if err := insertUser(ctx, db, collectionName, user); err != nil {
 		return err
 	}
+	userID := user.ID.Hex()
+	c.onFailure(func(ctx context.Context) error {
+		return db.Delete(ctx, collectionName, userID)
+	})
+	return recordEvents(ctx, db, c, structure.UserRegistered{UserID: user.ID, UserType: user.UserType})
+}
+
+// clientUpdate applies userData to the user and client documents with the
+// given ID as a single atomic write.
+func clientUpdate(ctx context.Context, db Database, userID string, userData bson.M) error {
+	return updateUserAndRole(ctx, db, "client", userID, userData)
+}
+
+// spUpdate applies userData to the user and serviceProvider documents with
+// the given ID as a single atomic write.
+func spUpdate(ctx context.Context, db Database, userID string, userData bson.M) error {
+	return updateUserAndRole(ctx, db, "serviceProvider", userID, userData)
+}
 
-	return nil
+// updateUserAndRole applies userData to the user document and to the copy of
+// the user embedded in the matching document in roleCollection. Without a
+// transaction, a failed role update restores the user's previous values.
+func updateUserAndRole(ctx context.Context, db Database, roleCollection string, userID string, userData bson.M) error {
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		var previous bson.D
+		if err := db.Get(ctx, "user", userID, &previous); err != nil {
+			return err
+		}
+
+		// Update user collection
+		if err := db.Update(ctx, "user", userID, userData); err != nil {
+			return err
+		}
+
+		restore := restoreUpdate(previous, userData)
+		id, _ := lookupField(previous, "_id")
+		c.onFailure(func(ctx context.Context) error {
+			return db.UpdateWhere(ctx, "user", bson.M{"_id": id}, restore, nil)
+		})
+
+		// Update the role collection, whose documents keep the user under
+		// "user"
+		roleData := make(bson.M, len(userData))
+		for key, value := range userData {
+			roleData["user."+key] = value
+		}
+		return db.Update(ctx, roleCollection, userID, roleData)
+	})
 }
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// leasesCollection holds one {_id: name, holder, expiresat} document per
+// lease.
+const leasesCollection = "lease"
+
+// lease is a named lock held by one process until it expires.
+type lease struct {
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expiresat"`
+}
+
+func (s *MongoStore) AcquireLease(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Take the lease if it is ours or has expired. Otherwise the upsert tries
+	// to insert a second document with the same _id and fails.
+	filter := bson.M{"_id": name, "$or": bson.A{
+		bson.M{"holder": holder},
+		bson.M{"expiresat": bson.M{"$lte": now}},
+	}}
+	update := bson.M{"$set": lease{Holder: holder, ExpiresAt: now.Add(ttl)}}
+	_, err := s.collection(leasesCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (s *MongoStore) ReleaseLease(ctx context.Context, name string, holder string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection(leasesCollection).DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	if err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) AcquireLease(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, held := s.leases[name]
+	if held && current.Holder != holder && current.ExpiresAt.After(now) {
+		return false, nil
+	}
+	s.leases[name] = lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) ReleaseLease(ctx context.Context, name string, holder string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[name].Holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JournalCollection holds the entries of the escrow ledger. Balances are
+// never stored on their own; they are the sums of the entries' postings.
+const JournalCollection = "journal"
+
+// journalKeyIndex makes each movement of a job's escrow happen at most once.
+var journalKeyIndex = uniqueIndex{collection: JournalCollection, field: "key", unset: ""}
+
+// AccountBalance returns the balance of the ledger account, the sum of every
+// posting to it.
+func AccountBalance(ctx context.Context, db Database, account string) (structure.Money, error) {
+	var entries []structure.JournalEntry
+	if err := db.Find(ctx, JournalCollection, bson.M{"postings.account": account}, &entries); err != nil {
+		return 0, err
+	}
+	var balance structure.Money
+	for _, entry := range entries {
+		for _, posting := range entry.Postings {
+			if posting.Account == account {
+				balance += posting.Amount
+			}
+		}
+	}
+	return balance, nil
+}
+
+// holdEscrow moves the amount of the accepted bid from the job's client into
+// the job's escrow. There is no funds check: the client's account has no
+// deposits to draw on and may go negative, as structure.ClientAccount
+// describes.
+func holdEscrow(ctx context.Context, db Database, c *compensator, job structure.Job, bid structure.Bid) error {
+	if bid.BidAmount <= 0 {
+		return nil
+	}
+	return postEntry(ctx, db, c, structure.JournalEntry{
+		Key:   structure.JournalKey(job.ID, structure.EntryHold),
+		Kind:  structure.EntryHold,
+		JobID: job.ID,
+		Postings: []structure.Posting{
+			{Account: structure.ClientAccount(job.ClientID), Amount: -bid.BidAmount},
+			{Account: structure.EscrowAccount(job.ID), Amount: bid.BidAmount},
+		},
+	})
+}
+
+// settleEscrow empties the escrow of the job with ID jobID as it moves to
+// status to: completed jobs release it to their service provider, and
+// cancelled or banned jobs refund it to their client. Escrow that is already
+// empty, such as that of a job completed before it was cancelled, is left.
+func settleEscrow(ctx context.Context, db Database, c *compensator, jobID primitive.ObjectID, to structure.JobStatus) error {
+	var kind structure.EntryKind
+	switch to {
+	case structure.JobStatusCompleted:
+		kind = structure.EntryRelease
+	case structure.JobStatusCancelled, structure.JobStatusBan:
+		kind = structure.EntryRefund
+	default:
+		return nil
+	}
+
+	escrow := structure.EscrowAccount(jobID)
+	held, err := AccountBalance(ctx, db, escrow)
+	if err != nil || held <= 0 {
+		return err
+	}
+	var job structure.Job
+	if err := db.Get(ctx, "job", jobID.Hex(), &job); err != nil {
+		return err
+	}
+	payee := structure.ClientAccount(job.ClientID)
+	if kind == structure.EntryRelease {
+		payee = structure.ProviderAccount(job.ServiceProviderID)
+	}
+
+	err = postEntry(ctx, db, c, structure.JournalEntry{
+		Key:   structure.JournalKey(jobID, kind),
+		Kind:  kind,
+		JobID: jobID,
+		Postings: []structure.Posting{
+			{Account: escrow, Amount: -held},
+			{Account: payee, Amount: held},
+		},
+	})
+	if err != nil || kind != structure.EntryRelease {
+		return err
+	}
+	return syncBalance(ctx, db, c, job.ServiceProviderID)
+}
+
+// postEntry validates entry and adds it to the journal, unless an entry with
+// its key has already been posted. It registers removing the entry as the
+// compensating action; entries are otherwise never changed or removed.
+func postEntry(ctx context.Context, db Database, c *compensator, entry structure.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	posted, err := db.Count(ctx, JournalCollection, bson.M{"key": entry.Key})
+	if err != nil || posted > 0 {
+		return err
+	}
+
+	// Outside a transaction, the unique index on key reports an entry
+	// posted concurrently since it was looked for. In a transaction the
+	// duplicate has aborted the transaction, so it is returned.
+	entry.CreatedAt = time.Now().UTC()
+	err = db.Create(ctx, JournalCollection, &entry)
+	if IsDuplicateKey(err) && !c.transactional {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entryID := entry.ID.Hex()
+	c.onFailure(func(ctx context.Context) error {
+		return db.Delete(ctx, JournalCollection, entryID)
+	})
+	return nil
+}
+
+// syncBalance copies the ledger balance of the service provider with ID id
+// into their document, so that reads of the document show it.
+func syncBalance(ctx context.Context, db Database, c *compensator, id primitive.ObjectID) error {
+	var serviceProvider structure.ServiceProvider
+	err := db.Get(ctx, "serviceProvider", id.Hex(), &serviceProvider)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	balance, err := AccountBalance(ctx, db, structure.ProviderAccount(id))
+	if err != nil {
+		return err
+	}
+	previous := serviceProvider.SPBalance.Amount
+	if balance == previous {
+		return nil
+	}
+
+	if err := db.Update(ctx, "serviceProvider", id.Hex(), bson.M{"spbalance.amount": balance}); err != nil {
+		return err
+	}
+	c.onFailure(func(ctx context.Context) error {
+		return db.Update(ctx, "serviceProvider", id.Hex(), bson.M{"spbalance.amount": previous})
+	})
+	return nil
+}
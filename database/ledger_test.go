@@ -0,0 +1,245 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newAcceptedJob inserts a client's job and its bidder's service provider
+// document, and accepts the bidder's bid of amount on the job.
+func newAcceptedJob(t *testing.T, store Database, amount structure.Money) (structure.Job, structure.Bid) {
+	t.Helper()
+	ctx := context.Background()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted, ClientID: primitive.NewObjectID()}
+	if err := store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	serviceProvider := structure.ServiceProvider{ID: primitive.NewObjectID(), Skill: "Roofing"}
+	if err := store.Create(ctx, "serviceProvider", &serviceProvider); err != nil {
+		t.Fatalf("Failed to insert service provider document: %v", err)
+	}
+	bid := structure.Bid{Description: "I can do it", BidAmount: amount, SPID: serviceProvider.ID}
+	if err := placeBid(ctx, store, job.ID.Hex(), &bid); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if err := acceptBid(ctx, store, job.ID.Hex(), bid.ID.Hex(), job.ClientID, ""); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+	if err := store.Get(ctx, "job", job.ID.Hex(), &job); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	return job, bid
+}
+
+// moveTo moves the job to each status in turn, as the job's handlers do.
+func moveTo(t *testing.T, store Database, job *structure.Job, statuses ...structure.JobStatus) {
+	t.Helper()
+	ctx := context.Background()
+	for _, to := range statuses {
+		transition, err := job.Transition(to, job.ClientID, time.Now(), "")
+		if err != nil {
+			t.Fatalf("Failed to move job: %v", err)
+		}
+		update := bson.M{"jobstatus": to, "history": append(job.History, transition)}
+		if err := store.MoveJob(ctx, job.ID.Hex(), transition, update); err != nil {
+			t.Fatalf("Failed to move job to %s: %v", to, err)
+		}
+		if err := store.Get(ctx, "job", job.ID.Hex(), job); err != nil {
+			t.Fatalf("Failed to get job document: %v", err)
+		}
+	}
+}
+
+// balances returns the ledger balances of the job's client, escrow and
+// service provider.
+func balances(t *testing.T, store Database, job structure.Job) (client, escrow, provider structure.Money) {
+	t.Helper()
+	ctx := context.Background()
+	for account, balance := range map[string]*structure.Money{
+		structure.ClientAccount(job.ClientID):            &client,
+		structure.EscrowAccount(job.ID):                  &escrow,
+		structure.ProviderAccount(job.ServiceProviderID): &provider,
+	} {
+		var err error
+		if *balance, err = AccountBalance(ctx, store, account); err != nil {
+			t.Fatalf("Failed to get balance of %s: %v", account, err)
+		}
+	}
+	return client, escrow, provider
+}
+
+// journalKinds returns the kinds of the job's journal entries.
+func journalKinds(t *testing.T, store Database, job structure.Job) []structure.EntryKind {
+	t.Helper()
+	var entries []structure.JournalEntry
+	if err := store.Find(context.Background(), JournalCollection, bson.M{"jobid": job.ID}, &entries); err != nil {
+		t.Fatalf("Failed to find journal entries: %v", err)
+	}
+	kinds := make([]structure.EntryKind, len(entries))
+	for i, entry := range entries {
+		kinds[i] = entry.Kind
+	}
+	return kinds
+}
+
+func TestAcceptBidHoldsEscrow(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	amount, _ := structure.ParseMoney("1500.50")
+
+	// Act
+	job, _ := newAcceptedJob(t, store, amount)
+
+	// Assert
+	client, escrow, provider := balances(t, store, job)
+	if client != -amount || escrow != amount || provider != 0 {
+		t.Errorf("Expected %s to be held from the client, got client %s, escrow %s, provider %s", amount, client, escrow, provider)
+	}
+	if kinds := journalKinds(t, store, job); len(kinds) != 1 || kinds[0] != structure.EntryHold {
+		t.Errorf("Expected a single hold entry, got %v", kinds)
+	}
+}
+
+func TestCompletingJobReleasesEscrow(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, bid := newAcceptedJob(t, store, structure.Taka(200))
+
+	// Act: a disputed completion is completed again
+	moveTo(t, store, &job, structure.JobStatusJobStarted, structure.JobStatusCompleted, structure.JobStatusDisputed, structure.JobStatusCompleted)
+
+	// Assert
+	client, escrow, provider := balances(t, store, job)
+	if client != -structure.Taka(200) || escrow != 0 || provider != structure.Taka(200) {
+		t.Errorf("Expected the escrow to be released once, got client %s, escrow %s, provider %s", client, escrow, provider)
+	}
+	if kinds := journalKinds(t, store, job); len(kinds) != 2 || kinds[1] != structure.EntryRelease {
+		t.Errorf("Expected a hold and a release entry, got %v", kinds)
+	}
+	var serviceProvider structure.ServiceProvider
+	if err := store.Get(ctx, "serviceProvider", bid.SPID.Hex(), &serviceProvider); err != nil {
+		t.Fatalf("Failed to get service provider document: %v", err)
+	}
+	if serviceProvider.SPBalance.Amount != structure.Taka(200) {
+		t.Errorf("Expected the service provider's balance to follow the ledger, got %s", serviceProvider.SPBalance.Amount)
+	}
+}
+
+func TestCancellingJobRefundsEscrow(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	job, _ := newAcceptedJob(t, store, structure.Taka(300))
+
+	// Act
+	moveTo(t, store, &job, structure.JobStatusCancelled, structure.JobStatusBan)
+
+	// Assert
+	client, escrow, provider := balances(t, store, job)
+	if client != 0 || escrow != 0 || provider != 0 {
+		t.Errorf("Expected the escrow to be refunded, got client %s, escrow %s, provider %s", client, escrow, provider)
+	}
+	if kinds := journalKinds(t, store, job); len(kinds) != 2 || kinds[1] != structure.EntryRefund {
+		t.Errorf("Expected a hold and a refund entry, got %v", kinds)
+	}
+}
+
+func TestCancellingJobWithoutEscrow(t *testing.T) {
+	store := NewMemoryStore()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted, ClientID: primitive.NewObjectID()}
+	if err := store.Create(context.Background(), "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+
+	moveTo(t, store, &job, structure.JobStatusCancelled)
+
+	if kinds := journalKinds(t, store, job); len(kinds) != 0 {
+		t.Errorf("Expected no journal entries, got %v", kinds)
+	}
+}
+
+func TestEscrowIsPostedOncePerJob(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, bid := newAcceptedJob(t, store, structure.Taka(100))
+
+	// Act: the hold and refund are retried
+	err := holdEscrow(ctx, store, &compensator{}, job, bid)
+	if err == nil {
+		err = settleEscrow(ctx, store, &compensator{}, job.ID, structure.JobStatusCancelled)
+	}
+	if err == nil {
+		err = settleEscrow(ctx, store, &compensator{}, job.ID, structure.JobStatusCancelled)
+	}
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to retry escrow: %v", err)
+	}
+	if kinds := journalKinds(t, store, job); len(kinds) != 2 {
+		t.Errorf("Expected one hold and one refund entry, got %v", kinds)
+	}
+	if _, escrow, _ := balances(t, store, job); escrow != 0 {
+		t.Errorf("Expected the escrow to be empty, got %s", escrow)
+	}
+}
+
+func TestPostEntrySkipsPostedEntryWithoutInserting(t *testing.T) {
+	// Arrange
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	job, bid := newAcceptedJob(t, store, structure.Taka(100))
+
+	// Act: a failed insert, which would abort a transaction, is not tried
+	store.failCollection = JournalCollection
+	err := holdEscrow(context.Background(), store, &compensator{transactional: true}, job, bid)
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected the posted hold to be skipped, got %v", err)
+	}
+}
+
+func TestMoveJobRemovesEntryOnFailure(t *testing.T) {
+	// Arrange
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	job, _ := newAcceptedJob(t, store, structure.Taka(100))
+	transition, err := job.Transition(structure.JobStatusCancelled, job.ClientID, time.Now(), "")
+	if err != nil {
+		t.Fatalf("Failed to move job: %v", err)
+	}
+
+	// Act
+	store.failCollection = "job"
+	err = moveJob(context.Background(), store, job.ID.Hex(), transition, bson.M{"jobstatus": structure.JobStatusCancelled})
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected MoveJob to fail when the job update fails")
+	}
+	if _, escrow, _ := balances(t, store, job); escrow != structure.Taka(100) {
+		t.Errorf("Expected the escrow to stay held, got %s", escrow)
+	}
+}
+
+func TestPostEntryRejectsUnbalancedEntry(t *testing.T) {
+	store := NewMemoryStore()
+	entry := structure.JournalEntry{Key: "unbalanced", Postings: []structure.Posting{
+		{Account: "client:a", Amount: -structure.Taka(100)},
+		{Account: "escrow:b", Amount: structure.Taka(99)},
+	}}
+
+	err := postEntry(context.Background(), store, &compensator{}, entry)
+
+	if err == nil {
+		t.Error("Expected an unbalanced entry to be rejected")
+	}
+	if count, _ := store.Count(context.Background(), JournalCollection, bson.M{}); count != 0 {
+		t.Errorf("Expected no entry to be stored, found %d", count)
+	}
+}
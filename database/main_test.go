@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestMain runs the suite against the in-memory backend unless MONGO_TEST_URI
+// points at a MongoDB server to test against instead.
+func TestMain(m *testing.M) {
+	opts := DefaultOptions()
+	opts.Backend = BackendMemory
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		opts.Backend = BackendMongo
+		opts.URI = uri
+	}
+
+	store, err := OpenDatabase(context.Background(), opts)
+	if err != nil {
+		panic(err)
+	}
+	SetDefault(store)
+
+	code := m.Run()
+	Close(context.Background())
+	os.Exit(code)
+}
@@ -0,0 +1,494 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchDocument reports whether doc satisfies the MongoDB-style query. It
+// supports field equality, dotted paths into nested documents and arrays,
+// $and/$or/$nor, and the $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists
+// and $regex operators.
+func matchDocument(doc bson.D, query bson.D) (bool, error) {
+	for _, clause := range query {
+		switch clause.Key {
+		case "$and", "$or", "$nor":
+			subQueries, ok := clause.Value.(bson.A)
+			if !ok {
+				return false, fmt.Errorf("%s requires an array", clause.Key)
+			}
+			ok, err := matchLogical(doc, clause.Key, subQueries)
+			if err != nil || !ok {
+				return false, err
+			}
+		default:
+			values := lookupPath(doc, clause.Key)
+			ok, err := matchCondition(values, clause.Value)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// matchLogical evaluates a $and, $or or $nor clause.
+func matchLogical(doc bson.D, operator string, subQueries bson.A) (bool, error) {
+	for _, sub := range subQueries {
+		subQuery, ok := sub.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("%s requires an array of documents", operator)
+		}
+		matched, err := matchDocument(doc, subQuery)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == "$and" && !matched:
+			return false, nil
+		case operator == "$or" && matched:
+			return true, nil
+		case operator == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+// matchCondition applies a field condition to the values found at its path.
+func matchCondition(values []interface{}, condition interface{}) (bool, error) {
+	operators, ok := condition.(bson.D)
+	if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+		return matchEqual(values, condition), nil
+	}
+
+	for _, op := range operators {
+		var matched bool
+		switch op.Key {
+		case "$eq":
+			matched = matchEqual(values, op.Value)
+		case "$ne":
+			matched = !matchEqual(values, op.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			matched = matchAny(values, func(v interface{}) bool { return compareOperator(op.Key, v, op.Value) })
+		case "$in", "$nin":
+			candidates, ok := op.Value.(bson.A)
+			if !ok {
+				return false, fmt.Errorf("%s requires an array", op.Key)
+			}
+			in := matchAny(values, func(v interface{}) bool {
+				for _, candidate := range candidates {
+					if valuesEqual(v, candidate) {
+						return true
+					}
+				}
+				return false
+			})
+			matched = in == (op.Key == "$in")
+		case "$exists":
+			want, _ := op.Value.(bool)
+			matched = (len(values) > 0) == want
+		case "$regex":
+			re, err := compileRegex(op.Value, lookupOption(operators))
+			if err != nil {
+				return false, err
+			}
+			matched = matchAny(values, func(v interface{}) bool {
+				s, ok := v.(string)
+				return ok && re.MatchString(s)
+			})
+		case "$options":
+			continue
+		default:
+			return false, fmt.Errorf("unsupported operator %s", op.Key)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchEqual reports whether any value equals want. As in MongoDB, null also
+// matches a missing field.
+func matchEqual(values []interface{}, want interface{}) bool {
+	if want == nil && len(values) == 0 {
+		return true
+	}
+	return matchAny(values, func(v interface{}) bool { return valuesEqual(v, want) })
+}
+
+// matchAny reports whether pred holds for any value, descending into arrays
+// the way MongoDB matches array fields.
+func matchAny(values []interface{}, pred func(interface{}) bool) bool {
+	for _, v := range values {
+		if pred(v) {
+			return true
+		}
+		if arr, ok := v.(bson.A); ok {
+			for _, elem := range arr {
+				if pred(elem) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// lookupOption returns the $options flags accompanying a $regex, if any.
+func lookupOption(operators bson.D) string {
+	for _, op := range operators {
+		if op.Key == "$options" {
+			options, _ := op.Value.(string)
+			return options
+		}
+	}
+	return ""
+}
+
+// compileRegex builds a Go regexp from a $regex pattern and its options.
+func compileRegex(pattern interface{}, options string) (*regexp.Regexp, error) {
+	var expr string
+	switch p := pattern.(type) {
+	case string:
+		expr = p
+	case primitive.Regex:
+		expr, options = p.Pattern, options+p.Options
+	default:
+		return nil, fmt.Errorf("$regex requires a string pattern")
+	}
+
+	var flags string
+	for _, o := range options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// compareOperator evaluates $gt, $gte, $lt and $lte. Values of different
+// kinds never match, as in MongoDB.
+func compareOperator(operator string, a, b interface{}) bool {
+	c, ok := compareValues(a, b)
+	if !ok {
+		return false
+	}
+	switch operator {
+	case "$gt":
+		return c > 0
+	case "$gte":
+		return c >= 0
+	case "$lt":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+// valuesEqual compares two BSON values, treating all numeric types alike.
+func valuesEqual(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues orders two BSON values of the same kind. The boolean result
+// is false when the values cannot be ordered against each other.
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return compareOrdered(x, y), true
+		}
+		return 0, false
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(x.Hex(), y.Hex()), true
+		}
+	case primitive.DateTime:
+		if y, ok := toDateTime(b); ok {
+			return compareOrdered(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return compareOrdered(boolToInt(x), boolToInt(y)), true
+		}
+	}
+	return 0, false
+}
+
+func compareOrdered[T int | float64 | primitive.DateTime](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toDateTime(v interface{}) (primitive.DateTime, bool) {
+	switch t := v.(type) {
+	case primitive.DateTime:
+		return t, true
+	case time.Time:
+		return primitive.NewDateTimeFromTime(t), true
+	}
+	return 0, false
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// lookupField returns the value stored under key at the top level of doc.
+func lookupField(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// lookupPath resolves a dotted path against doc. Arrays along the path are
+// traversed element by element, so the result may hold several values.
+func lookupPath(doc bson.D, path string) []interface{} {
+	head, rest, nested := strings.Cut(path, ".")
+	value, ok := lookupField(doc, head)
+	if !ok {
+		return nil
+	}
+	if !nested {
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case bson.D:
+		return lookupPath(v, rest)
+	case bson.A:
+		var values []interface{}
+		for _, elem := range v {
+			if sub, ok := elem.(bson.D); ok {
+				values = append(values, lookupPath(sub, rest)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// setField assigns value at a dotted path in doc, creating nested documents
+// as needed, and returns the updated document.
+func setField(doc bson.D, path string, value interface{}) bson.D {
+	head, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != head {
+			continue
+		}
+		if !nested {
+			doc[i].Value = value
+			return doc
+		}
+		sub, _ := e.Value.(bson.D)
+		doc[i].Value = setField(sub, rest, value)
+		return doc
+	}
+
+	if nested {
+		value = setField(bson.D{}, rest, value)
+	}
+	return append(doc, bson.E{Key: head, Value: value})
+}
+
+// unsetField removes the value at a dotted path in doc, if there is one, and
+// returns the updated document.
+func unsetField(doc bson.D, path string) bson.D {
+	head, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != head {
+			continue
+		}
+		if !nested {
+			return append(doc[:i], doc[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetField(sub, rest)
+		}
+		return doc
+	}
+	return doc
+}
+
+// applyUpdate applies the $set, $unset, $inc and $push operators of update to doc and
+// returns the updated document.
+func applyUpdate(doc bson.D, update bson.D) (bson.D, error) {
+	for _, operator := range update {
+		fields, ok := operator.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a document", operator.Key)
+		}
+		switch operator.Key {
+		case "$set":
+			for _, field := range fields {
+				doc = setField(doc, field.Key, field.Value)
+			}
+		case "$unset":
+			for _, field := range fields {
+				doc = unsetField(doc, field.Key)
+			}
+		case "$inc":
+			for _, field := range fields {
+				var current interface{}
+				if values := lookupPath(doc, field.Key); len(values) > 0 {
+					current = values[0]
+				}
+				sum, err := addNumbers(current, field.Value)
+				if err != nil {
+					return nil, fmt.Errorf("cannot $inc %s: %v", field.Key, err)
+				}
+				doc = setField(doc, field.Key, sum)
+			}
+		case "$push":
+			for _, field := range fields {
+				var array bson.A
+				if values := lookupPath(doc, field.Key); len(values) > 0 {
+					current, ok := values[0].(bson.A)
+					if !ok {
+						return nil, fmt.Errorf("cannot $push to %s, which is not an array", field.Key)
+					}
+					array = current
+				}
+				doc = setField(doc, field.Key, append(array, field.Value))
+			}
+		case "$pull":
+			for _, field := range fields {
+				values := lookupPath(doc, field.Key)
+				if len(values) == 0 {
+					continue
+				}
+				array, ok := values[0].(bson.A)
+				if !ok {
+					return nil, fmt.Errorf("cannot $pull from %s, which is not an array", field.Key)
+				}
+				kept, err := pullElements(array, field.Value)
+				if err != nil {
+					return nil, err
+				}
+				doc = setField(doc, field.Key, kept)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported update operator %s", operator.Key)
+		}
+	}
+	return doc, nil
+}
+
+// pullElements returns the elements of array that condition does not remove.
+// As with MongoDB's $pull, a document condition is a query the removed
+// documents match, and any other condition a value they equal.
+func pullElements(array bson.A, condition interface{}) (bson.A, error) {
+	query, isQuery := condition.(bson.D)
+	kept := bson.A{}
+	for _, elem := range array {
+		var matched bool
+		if sub, ok := elem.(bson.D); ok && isQuery {
+			var err error
+			if matched, err = matchDocument(sub, query); err != nil {
+				return nil, err
+			}
+		} else {
+			matched = valuesEqual(elem, condition)
+		}
+		if !matched {
+			kept = append(kept, elem)
+		}
+	}
+	return kept, nil
+}
+
+// addNumbers returns current plus delta, keeping integers integral as
+// MongoDB's $inc does. A missing current value counts as zero.
+func addNumbers(current interface{}, delta interface{}) (interface{}, error) {
+	switch c := current.(type) {
+	case nil:
+		return delta, nil
+	case int32:
+		switch d := delta.(type) {
+		case int32:
+			return c + d, nil
+		case int64:
+			return int64(c) + d, nil
+		}
+	case int64:
+		switch d := delta.(type) {
+		case int32:
+			return c + int64(d), nil
+		case int64:
+			return c + d, nil
+		}
+	}
+	a, ok := toFloat(current)
+	b, okDelta := toFloat(delta)
+	if !ok || !okDelta {
+		return nil, fmt.Errorf("%v and %v are not both numbers", current, delta)
+	}
+	return a + b, nil
+}
+
+// cloneDocument returns a deep copy of doc so callers cannot alias stored data.
+func cloneDocument(doc bson.D) bson.D {
+	clone := make(bson.D, len(doc))
+	for i, e := range doc {
+		clone[i] = bson.E{Key: e.Key, Value: cloneValue(e.Value)}
+	}
+	return clone
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.D:
+		return cloneDocument(t)
+	case bson.A:
+		clone := make(bson.A, len(t))
+		for i, elem := range t {
+			clone[i] = cloneValue(elem)
+		}
+		return clone
+	}
+	return v
+}
@@ -0,0 +1,449 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore is a Database that keeps documents in process memory. It is
+// intended for tests and local development and is safe for concurrent use.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	collections map[string][]bson.D
+	sequences   map[string]int64
+	leases      map[string]lease
+}
+
+// Ensure MemoryStore implements the Database interface.
+var _ Database = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{collections: make(map[string][]bson.D), sequences: make(map[string]int64), leases: make(map[string]lease)}
+}
+
+func (s *MemoryStore) ClearCollection(ctx context.Context, collectionName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !contains(CollectionNamesArray, collectionName) {
+		return fmt.Errorf("collection %s does not exist", collectionName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections, collectionName)
+	delete(s.sequences, collectionName)
+	if collectionName == leasesCollection {
+		clear(s.leases)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAll(ctx context.Context, collectionName string, result interface{}) error {
+	return s.Find(ctx, collectionName, bson.D{}, result)
+}
+
+func (s *MemoryStore) Create(ctx context.Context, collectionName string, document interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	doc, err := toDocument(document)
+	if err != nil {
+		return fmt.Errorf("failed to insert document into collection %s: %v", collectionName, err)
+	}
+
+	// Assign an ObjectID when the document does not carry one, as MongoDB does
+	id, ok := lookupField(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+
+	s.mu.Lock()
+	for _, existing := range s.collections[collectionName] {
+		if existingID, _ := lookupField(existing, "_id"); valuesEqual(existingID, id) {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to insert document into collection %s: %w on _id: %v", collectionName, ErrDuplicateKey, id)
+		}
+	}
+	if err := s.checkUnique(collectionName, doc); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("failed to insert document into collection %s: %w", collectionName, err)
+	}
+	s.collections[collectionName] = append(s.collections[collectionName], doc)
+	s.mu.Unlock()
+
+	// Set the ObjectID of the inserted document
+	return setInsertedID(document, id)
+}
+
+func (s *MemoryStore) UserCreate(ctx context.Context, collectionName string, document interface{}) error {
+	return userCreate(ctx, s, collectionName, document)
+}
+
+func (s *MemoryStore) ClientCreate(ctx context.Context, document interface{}) error {
+	return clientCreate(ctx, s, document)
+}
+
+func (s *MemoryStore) SPCreate(ctx context.Context, collectionName string, document interface{}) error {
+	return spCreate(ctx, s, collectionName, document)
+}
+
+func (s *MemoryStore) Get(ctx context.Context, collectionName string, id string, result interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	s.mu.RLock()
+	index := s.indexOf(collectionName, objID)
+	var doc bson.D
+	if index >= 0 {
+		doc = s.collections[collectionName][index]
+	}
+	s.mu.RUnlock()
+
+	if index < 0 {
+		return fmt.Errorf("document with ID %v %w in collection %s", id, ErrNotFound, collectionName)
+	}
+	if err := decodeDocument(doc, result); err != nil {
+		return fmt.Errorf("failed to find document in collection %s: %v", collectionName, err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, collectionName string, id string, update interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	fields, err := toDocument(update)
+	if err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(collectionName, objID)
+	if index < 0 {
+		return fmt.Errorf("document with ID %s %w in collection %s", id, ErrNotFound, collectionName)
+	}
+
+	// Apply the fields as a $set, reporting not found when nothing changed
+	original := s.collections[collectionName][index]
+	updated := cloneDocument(original)
+	for _, field := range fields {
+		updated = setField(updated, field.Key, field.Value)
+	}
+	if reflect.DeepEqual(original, updated) {
+		return fmt.Errorf("document with ID %s %w in collection %s", id, ErrNotFound, collectionName)
+	}
+	if err := s.checkUnique(collectionName, updated); err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %w", collectionName, err)
+	}
+	s.collections[collectionName][index] = updated
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateWhere(ctx context.Context, collectionName string, filter interface{}, update interface{}, result interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
+	}
+	operators, err := toDocument(update)
+	if err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, doc := range s.collections[collectionName] {
+		ok, err := matchDocument(doc, query)
+		if err != nil {
+			return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
+		}
+		if !ok {
+			continue
+		}
+
+		updated, err := applyUpdate(cloneDocument(doc), operators)
+		if err != nil {
+			return fmt.Errorf("failed to update document in collection %s: %v", collectionName, err)
+		}
+		if err := s.checkUnique(collectionName, updated); err != nil {
+			return fmt.Errorf("failed to update document in collection %s: %w", collectionName, err)
+		}
+		s.collections[collectionName][i] = updated
+		if result == nil {
+			return nil
+		}
+		return decodeDocument(updated, result)
+	}
+	return fmt.Errorf("no matching document %w in collection %s", ErrNotFound, collectionName)
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, collectionName string, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(collectionName, objID)
+	if index < 0 {
+		return fmt.Errorf("document with ID %s %w in collection %s", id, ErrNotFound, collectionName)
+	}
+	docs := s.collections[collectionName]
+	s.collections[collectionName] = append(docs[:index:index], docs[index+1:]...)
+
+	return nil
+}
+
+func (s *MemoryStore) Find(ctx context.Context, collectionName string, filter interface{}, result interface{}) error {
+	docs, err := s.match(ctx, collectionName, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find documents in collection %s: %v", collectionName, err)
+	}
+
+	if err := decodeDocuments(docs, result); err != nil {
+		return fmt.Errorf("failed to decode documents in collection %s: %v", collectionName, err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindPage(ctx context.Context, collectionName string, filter interface{}, opts FindOptions, result interface{}) (Page, error) {
+	query, err := opts.pageFilter(filter)
+	if err != nil {
+		return Page{}, err
+	}
+
+	docs, err := s.match(ctx, collectionName, query)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to find documents in collection %s: %v", collectionName, err)
+	}
+
+	// Keep one document past the limit to learn whether another page exists
+	sortDocuments(docs, opts.sortKeys())
+	if opts.Limit > 0 && int64(len(docs)) > opts.Limit+1 {
+		docs = docs[:opts.Limit+1]
+	}
+
+	docs, page, err := opts.finishPage(docs)
+	if err != nil {
+		return Page{}, err
+	}
+	if err := decodeDocuments(docs, result); err != nil {
+		return Page{}, fmt.Errorf("failed to decode documents in collection %s: %v", collectionName, err)
+	}
+
+	if opts.CountTotal {
+		if page.Total, err = s.Count(ctx, collectionName, filter); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) Count(ctx context.Context, collectionName string, filter interface{}) (int64, error) {
+	docs, err := s.match(ctx, collectionName, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents in collection %s: %v", collectionName, err)
+	}
+	return int64(len(docs)), nil
+}
+
+func (s *MemoryStore) ClientUpdate(ctx context.Context, userID string, userData bson.M) error {
+	return clientUpdate(ctx, s, userID, userData)
+}
+
+func (s *MemoryStore) SPUpdate(ctx context.Context, userID string, userData bson.M) error {
+	return spUpdate(ctx, s, userID, userData)
+}
+
+func (s *MemoryStore) PlaceBid(ctx context.Context, jobID string, bid *structure.Bid) error {
+	return placeBid(ctx, s, jobID, bid)
+}
+
+func (s *MemoryStore) AcceptBid(ctx context.Context, jobID string, bidID string, actor primitive.ObjectID, reason string) error {
+	return acceptBid(ctx, s, jobID, bidID, actor, reason)
+}
+
+func (s *MemoryStore) RejectBid(ctx context.Context, jobID string, bidID string) error {
+	return rejectBid(ctx, s, jobID, bidID)
+}
+
+func (s *MemoryStore) UpdateBid(ctx context.Context, bidID string, update bson.M) error {
+	return updateBid(ctx, s, bidID, update)
+}
+
+func (s *MemoryStore) DeleteBid(ctx context.Context, bidID string) error {
+	return deleteBid(ctx, s, bidID)
+}
+
+func (s *MemoryStore) CreateReview(ctx context.Context, review *structure.Review) error {
+	return createReview(ctx, s, review)
+}
+
+func (s *MemoryStore) MoveJob(ctx context.Context, jobID string, transition structure.JobTransition, update bson.M) error {
+	return moveJob(ctx, s, jobID, transition, update)
+}
+
+// match returns copies of the documents in collectionName that satisfy filter.
+func (s *MemoryStore) match(ctx context.Context, collectionName string, filter interface{}) ([]bson.D, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []bson.D
+	for _, doc := range s.collections[collectionName] {
+		ok, err := matchDocument(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, cloneDocument(doc))
+		}
+	}
+	return docs, nil
+}
+
+// indexOf returns the position of the document with the given _id, or -1.
+// The caller must hold s.mu.
+func (s *MemoryStore) indexOf(collectionName string, id primitive.ObjectID) int {
+	for i, doc := range s.collections[collectionName] {
+		if docID, ok := lookupField(doc, "_id"); ok && docID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortDocuments orders docs by the given sort keys. Missing fields sort
+// before any value, as null does in MongoDB.
+func sortDocuments(docs []bson.D, keys bson.D) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareSortValues(firstValue(docs[i], key.Key), firstValue(docs[j], key.Key))
+			if c != 0 {
+				return c*sortDirection(key) < 0
+			}
+		}
+		return false
+	})
+}
+
+func firstValue(doc bson.D, path string) interface{} {
+	if values := lookupPath(doc, path); len(values) > 0 {
+		return values[0]
+	}
+	return nil
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compareValues(a, b)
+	return c
+}
+
+// toDocument round-trips v through BSON so it can be inspected as a bson.D.
+func toDocument(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeDocument decodes doc into result the same way a MongoDB cursor would.
+func decodeDocument(doc bson.D, result interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, result)
+}
+
+// decodeDocuments appends each document to the slice pointed to by result.
+func decodeDocuments(docs []bson.D, result interface{}) error {
+	resultsVal := reflect.ValueOf(result)
+	if resultsVal.Kind() != reflect.Ptr {
+		return fmt.Errorf("results argument must be a pointer to a slice, but was a %s", resultsVal.Kind())
+	}
+
+	sliceVal := resultsVal.Elem()
+	if sliceVal.Kind() == reflect.Interface {
+		sliceVal = sliceVal.Elem()
+	}
+	if sliceVal.Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice, but was a pointer to %s", sliceVal.Kind())
+	}
+
+	elementType := sliceVal.Type().Elem()
+	sliceVal = reflect.MakeSlice(sliceVal.Type(), 0, len(docs))
+	for _, doc := range docs {
+		elem := reflect.New(elementType)
+		if err := decodeDocument(doc, elem.Interface()); err != nil {
+			return err
+		}
+		sliceVal = reflect.Append(sliceVal, elem.Elem())
+	}
+	resultsVal.Elem().Set(sliceVal)
+	return nil
+}
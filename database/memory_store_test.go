@@ -0,0 +1,117 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemoryStoreFind(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	jobs := []structure.Job{
+		{Title: "Paint house", Budget: "500", JobStatus: structure.JobStatusJobPosted, Clients: structure.Client{Location: "Dhaka"}},
+		{Title: "Fix roof", Budget: "1500", JobStatus: structure.JobStatusBidAccepted, Clients: structure.Client{Location: "Khulna"}},
+		{Title: "Paint fence", Budget: "300", JobStatus: structure.JobStatusBan, Bid: []structure.Bid{{BidAmount: structure.Taka(250)}, {BidAmount: structure.Taka(90)}}},
+	}
+	for i := range jobs {
+		if err := store.Create(ctx, "job", &jobs[i]); err != nil {
+			t.Fatalf("Failed to insert job document: %v", err)
+		}
+		if jobs[i].ID.IsZero() {
+			t.Fatalf("Expected ObjectID to be assigned to job %d", i)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter interface{}
+		want   []string
+	}{
+		{"empty filter", bson.M{}, []string{"Paint house", "Fix roof", "Paint fence"}},
+		{"equality", bson.M{"title": "Fix roof"}, []string{"Fix roof"}},
+		{"$eq", bson.M{"jobstatus": bson.M{"$eq": "ban"}}, []string{"Paint fence"}},
+		{"$gt on strings", bson.M{"budget": bson.M{"$gt": "1000"}}, []string{"Paint house", "Fix roof", "Paint fence"}},
+		{"$lt", bson.M{"budget": bson.M{"$lt": "400"}}, []string{"Fix roof", "Paint fence"}},
+		{"$in", bson.M{"jobstatus": bson.M{"$in": bson.A{"job_posted", "bid_accepted"}}}, []string{"Paint house", "Fix roof"}},
+		{"$regex", bson.M{"title": bson.M{"$regex": "^paint", "$options": "i"}}, []string{"Paint house", "Paint fence"}},
+		{"nested field", bson.M{"clients.location": "Khulna"}, []string{"Fix roof"}},
+		{"array element", bson.M{"bid.bidamount": bson.M{"$lt": structure.Taka(100)}}, []string{"Paint fence"}},
+		{"$or", bson.M{"$or": bson.A{bson.M{"title": "Fix roof"}, bson.M{"budget": "300"}}}, []string{"Fix roof", "Paint fence"}},
+		{"by _id", bson.M{"_id": jobs[1].ID}, []string{"Fix roof"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			var result []structure.Job
+			if err := store.Find(ctx, "job", tt.filter, &result); err != nil {
+				t.Fatalf("Find returned an error: %v", err)
+			}
+
+			// Assert
+			if len(result) != len(tt.want) {
+				t.Fatalf("Expected %d jobs, got %d", len(tt.want), len(result))
+			}
+			for i, job := range result {
+				if job.Title != tt.want[i] {
+					t.Errorf("Expected job %q at position %d, got %q", tt.want[i], i, job.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreUpdateAndDelete(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	bid := structure.Bid{Description: "Initial", BidAmount: structure.Taka(100)}
+	if err := store.Create(ctx, "bid", &bid); err != nil {
+		t.Fatalf("Failed to insert bid document: %v", err)
+	}
+
+	// Act: Update the bid, then repeat the same update
+	if err := store.Update(ctx, "bid", bid.ID.Hex(), bson.M{"description": "Updated"}); err != nil {
+		t.Fatalf("Failed to update bid document: %v", err)
+	}
+	if err := store.Update(ctx, "bid", bid.ID.Hex(), bson.M{"description": "Updated"}); err == nil {
+		t.Error("Expected an error when the update changes nothing")
+	}
+
+	// Assert
+	var updated structure.Bid
+	if err := store.Get(ctx, "bid", bid.ID.Hex(), &updated); err != nil {
+		t.Fatalf("Failed to retrieve bid document: %v", err)
+	}
+	if updated.Description != "Updated" || updated.BidAmount != structure.Taka(100) {
+		t.Errorf("Unexpected bid after update: %+v", updated)
+	}
+
+	if err := store.Delete(ctx, "bid", bid.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete bid document: %v", err)
+	}
+	if err := store.Get(ctx, "bid", bid.ID.Hex(), &updated); err == nil {
+		t.Error("Expected an error retrieving a deleted document")
+	}
+}
+
+func TestMemoryStoreCreateWithoutIDField(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	document := struct {
+		Name string `bson:"name"`
+	}{Name: "No ID"}
+
+	err := store.Create(ctx, "point", &document)
+
+	if err != nil {
+		t.Fatalf("Expected a document without an ID field to be inserted, got %v", err)
+	}
+	if count, _ := store.Count(ctx, "point", bson.M{"name": "No ID"}); count != 1 {
+		t.Errorf("Expected the document to be stored, found %d", count)
+	}
+}
@@ -0,0 +1,508 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationsCollection holds one {_id: version, description, appliedAt}
+// document per applied migration.
+const migrationsCollection = "migrations"
+
+// Migration is a versioned change to the indexes or data of the sumon
+// database. Up and Down must be safe to run again after a partial failure,
+// since a migration is only recorded once Up has completed.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time // zero if the migration is pending
+}
+
+// migrations lists every migration in ascending version order. Append new
+// migrations; never renumber or edit one that has been released.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique indexes on user userid, phonenumber and nid",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, index := range []uniqueIndex{userIDIndex, phoneNumberIndex, nidIndex} {
+				if err := createIndex(ctx, db, index.collection, index.model()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, "user", "userid_1", "phonenumber_1", "nid_1")
+		},
+	},
+	{
+		Version:     2,
+		Description: "indexes on bid postedTime and job status",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndex(ctx, db, "bid", mongo.IndexModel{Keys: bson.D{{Key: "postedTime", Value: -1}}}); err != nil {
+				return err
+			}
+			if err := createIndex(ctx, db, "job", mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}}}); err != nil {
+				return err
+			}
+			return createIndex(ctx, db, "job", mongo.IndexModel{Keys: bson.D{{Key: "jobstatus", Value: 1}}})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db, "bid", "postedTime_-1"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db, "job", "status_1", "jobstatus_1")
+		},
+	},
+	{
+		// 2dsphere indexes read an embedded coordinate document as
+		// longitude first, but points were stored latitude first.
+		Version:     3,
+		Description: "store point gps coordinates longitude first",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return reorderGps(ctx, db, "longitude", "latitude")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return reorderGps(ctx, db, "latitude", "longitude")
+		},
+	},
+	{
+		Version:     4,
+		Description: "2dsphere index on point gps",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, "point", mongo.IndexModel{Keys: bson.D{{Key: "gps", Value: "2dsphere"}}})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, "point", "gps_2dsphere")
+		},
+	},
+	{
+		// Login codes are kept for a day after they are sent, so the rate
+		// limit can count them; sessions are deleted once they expire.
+		Version:     5,
+		Description: "indexes and expiry on login codes and sessions",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndex(ctx, db, "otp", mongo.IndexModel{Keys: bson.D{{Key: "phonenumber", Value: 1}, {Key: "createdat", Value: -1}}}); err != nil {
+				return err
+			}
+			if err := createIndex(ctx, db, "otp", mongo.IndexModel{Keys: bson.D{{Key: "createdat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)}); err != nil {
+				return err
+			}
+			return createIndex(ctx, db, "session", mongo.IndexModel{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db, "otp", "phonenumber_1_createdat_-1", "createdat_1"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db, "session", "expiresat_1")
+		},
+	},
+	{
+		// Bids and reviews used to be linked to jobs only by the copies
+		// embedded in the job. Down keeps the references, which older
+		// code ignores.
+		Version:     6,
+		Description: "backfill job, bid and review user and job references",
+		Up:          backfillReferences,
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	},
+	{
+		Version:     7,
+		Description: "indexes on job, bid and review references",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, index := range referenceIndexes {
+				if err := createIndex(ctx, db, index.collection, mongo.IndexModel{Keys: bson.D{{Key: index.field, Value: 1}}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, index := range referenceIndexes {
+				if err := dropIndexes(ctx, db, index.collection, index.field+"_1"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// The auction scheduler looks for open jobs whose bidding deadline
+		// has passed.
+		Version:     8,
+		Description: "index on job status and bidding deadline",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, "job", mongo.IndexModel{Keys: bson.D{{Key: "jobstatus", Value: 1}, {Key: "biddingdeadline", Value: 1}}})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, "job", "jobstatus_1_biddingdeadline_1")
+		},
+	},
+	{
+		// The outbox dispatcher looks for pending events that are due.
+		Version:     9,
+		Description: "index on outbox status and next attempt",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, OutboxCollection, mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}}})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, OutboxCollection, "status_1_nextattemptat_1")
+		},
+	},
+	{
+		// The webhook sender looks for pending deliveries that are due and
+		// for the deliveries of an event, and lists a webhook's deliveries.
+		Version:     10,
+		Description: "indexes on webhook deliveries",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, keys := range webhookDeliveryIndexes {
+				if err := createIndex(ctx, db, "webhookDelivery", mongo.IndexModel{Keys: keys}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, "webhookDelivery", "status_1_nextattemptat_1", "webhookid_1_eventid_1", "webhookid_1_createdat_-1")
+		},
+	},
+	{
+		// Users list their unread notifications, and the in-app notifier
+		// looks for the notifications of an event.
+		Version:     11,
+		Description: "indexes on notifications",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, keys := range notificationIndexes {
+				if err := createIndex(ctx, db, "notification", mongo.IndexModel{Keys: keys}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, "notification", "userid_1_read_1", "userid_1_eventid_1")
+		},
+	},
+	{
+		// Amounts were stored as doubles of taka; Money stores int64s of
+		// paisa, and still reads the doubles of documents written since.
+		Version:     12,
+		Description: "store bid amounts and balances as paisa",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return convertAmounts(ctx, db, true)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return convertAmounts(ctx, db, false)
+		},
+	},
+	{
+		// Each movement of a job's escrow is posted once, balances sum the
+		// postings to an account, and a job's ledger lists its entries.
+		Version:     13,
+		Description: "indexes on the escrow journal",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndex(ctx, db, JournalCollection, journalKeyIndex.model()); err != nil {
+				return err
+			}
+			for _, keys := range journalIndexes {
+				if err := createIndex(ctx, db, JournalCollection, mongo.IndexModel{Keys: keys}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, JournalCollection, "key_1", "postings.account_1", "jobid_1_createdat_1")
+		},
+	},
+}
+
+// journalIndexes are the keys of the non-unique indexes on the journal.
+var journalIndexes = []bson.D{
+	{{Key: "postings.account", Value: 1}},
+	{{Key: "jobid", Value: 1}, {Key: "createdat", Value: 1}},
+}
+
+// amountFields are the fields holding Money, by collection.
+var amountFields = []struct{ collection, field string }{
+	{"bid", "bidamount"},
+	{"serviceProvider", "spbalance.amount"},
+	{"payment", "balance"},
+}
+
+// webhookDeliveryIndexes are the keys of the indexes on webhook deliveries.
+var webhookDeliveryIndexes = []bson.D{
+	{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}},
+	{{Key: "webhookid", Value: 1}, {Key: "eventid", Value: 1}},
+	{{Key: "webhookid", Value: 1}, {Key: "createdat", Value: -1}},
+}
+
+// notificationIndexes are the keys of the indexes on notifications.
+var notificationIndexes = []bson.D{
+	{{Key: "userid", Value: 1}, {Key: "read", Value: 1}},
+	{{Key: "userid", Value: 1}, {Key: "eventid", Value: 1}},
+}
+
+// referenceIndexes are the fields that the per-owner listings query.
+var referenceIndexes = []struct{ collection, field string }{
+	{"job", "clientid"},
+	{"job", "serviceproviderid"},
+	{"bid", "jobid"},
+	{"bid", "serviceproviderid"},
+	{"review", "jobid"},
+	{"review", "reviewerid"},
+	{"review", "revieweeid"},
+}
+
+// LatestMigration returns the version of the newest migration.
+func LatestMigration() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate applies every pending migration.
+func (s *MongoStore) Migrate(ctx context.Context) error {
+	return s.MigrateTo(ctx, LatestMigration())
+}
+
+// MigrateTo applies pending migrations up to and including version, then
+// reverts applied migrations newer than version, newest first. Migrations
+// are bounded only by ctx, since index builds can outlast the operation
+// timeout.
+func (s *MongoStore) MigrateTo(ctx context.Context, version int) error {
+	if version < 0 || version > LatestMigration() {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	db := s.client.Database(s.dbName)
+	for _, m := range migrations {
+		if m.Version > version || !applied[m.Version].IsZero() {
+			continue
+		}
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		record := bson.M{"_id": m.Version, "description": m.Description, "appliedAt": time.Now().UTC()}
+		if _, err := s.collection(migrationsCollection).ReplaceOne(ctx, bson.M{"_id": m.Version}, record, options.Replace().SetUpsert(true)); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= version || applied[m.Version].IsZero() {
+			continue
+		}
+		if err := m.Down(ctx, db); err != nil {
+			return fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if _, err := s.collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+			return fmt.Errorf("failed to unrecord migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// Migrations returns every known migration with the time it was applied.
+func (s *MongoStore) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Migration: m, AppliedAt: applied[m.Version]}
+	}
+	return statuses, nil
+}
+
+// appliedMigrations returns the time each recorded migration was applied,
+// keyed by version.
+func (s *MongoStore) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	cur, err := s.collection(migrationsCollection).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer cur.Close(context.Background())
+
+	var records []struct {
+		Version   int       `bson:"_id"`
+		AppliedAt time.Time `bson:"appliedAt"`
+	}
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode applied migrations: %w", err)
+	}
+
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+	return applied, nil
+}
+
+// createIndex builds model on the collection. Creating an index that already
+// exists with the same options succeeds.
+func createIndex(ctx context.Context, db *mongo.Database, collectionName string, model mongo.IndexModel) error {
+	if _, err := db.Collection(collectionName).Indexes().CreateOne(ctx, model); err != nil {
+		return fmt.Errorf("failed to create index on %s: %w", collectionName, err)
+	}
+	return nil
+}
+
+// dropIndexes drops the named indexes from the collection, ignoring any that
+// do not exist.
+func dropIndexes(ctx context.Context, db *mongo.Database, collectionName string, names ...string) error {
+	for _, name := range names {
+		_, err := db.Collection(collectionName).Indexes().DropOne(ctx, name)
+		if err != nil && !isNamespaceOrIndexNotFound(err) {
+			return fmt.Errorf("failed to drop index %s on %s: %w", name, collectionName, err)
+		}
+	}
+	return nil
+}
+
+// isNamespaceOrIndexNotFound reports whether err means the collection or
+// index being dropped does not exist.
+func isNamespaceOrIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) // NamespaceNotFound, IndexNotFound
+}
+
+// reorderGps rewrites the coordinates in every point's gps array so that the
+// first field comes before the second.
+func reorderGps(ctx context.Context, db *mongo.Database, first, second string) error {
+	coordinate := bson.D{
+		{Key: first, Value: "$$c." + first},
+		{Key: second, Value: "$$c." + second},
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"gps": bson.M{"$map": bson.M{"input": "$gps", "as": "c", "in": coordinate}}}}},
+	}
+	_, err := db.Collection("point").UpdateMany(ctx, bson.M{"gps": bson.M{"$type": "array"}}, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to reorder point gps coordinates: %w", err)
+	}
+	return nil
+}
+
+// convertAmounts rewrites the stored amounts from doubles of taka to int64s
+// of paisa, or back again, including the copies of bids embedded in jobs.
+func convertAmounts(ctx context.Context, db *mongo.Database, toPaisa bool) error {
+	from := "double"
+	convert := func(value string) interface{} {
+		return bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{value, 100}}, 0}}}
+	}
+	if !toPaisa {
+		from = "long"
+		convert = func(value string) interface{} {
+			return bson.M{"$divide": bson.A{value, 100}}
+		}
+	}
+
+	for _, amount := range amountFields {
+		pipeline := mongo.Pipeline{{{Key: "$set", Value: bson.M{amount.field: convert("$" + amount.field)}}}}
+		if _, err := db.Collection(amount.collection).UpdateMany(ctx, bson.M{amount.field: bson.M{"$type": from}}, pipeline); err != nil {
+			return fmt.Errorf("failed to convert %s %s: %w", amount.collection, amount.field, err)
+		}
+	}
+
+	bid := bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$type": "$$b.bidamount"}, from}},
+		bson.M{"$mergeObjects": bson.A{"$$b", bson.M{"bidamount": convert("$$b.bidamount")}}},
+		"$$b",
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"bid": bson.M{"$map": bson.M{"input": "$bid", "as": "b", "in": bid}}}}},
+	}
+	if _, err := db.Collection("job").UpdateMany(ctx, bson.M{"bid.bidamount": bson.M{"$type": from}}, pipeline); err != nil {
+		return fmt.Errorf("failed to convert job bid amounts: %w", err)
+	}
+	return nil
+}
+
+// backfillReferences sets the user IDs of jobs from their embedded client
+// and service provider, then links the bids and review embedded in each job
+// to it. Fields that are already set are left alone.
+func backfillReferences(ctx context.Context, db *mongo.Database) error {
+	jobs := db.Collection("job")
+	for field, source := range map[string]string{"clientid": "clients._id", "serviceproviderid": "serviceproviders._id"} {
+		filter := bson.M{field: bson.M{"$exists": false}, source: bson.M{"$exists": true}}
+		pipeline := mongo.Pipeline{{{Key: "$set", Value: bson.M{field: "$" + source}}}}
+		if _, err := jobs.UpdateMany(ctx, filter, pipeline); err != nil {
+			return fmt.Errorf("failed to backfill job %s: %w", field, err)
+		}
+	}
+
+	projection := bson.M{"clientid": 1, "serviceproviderid": 1, "bid._id": 1, "review._id": 1}
+	cursor, err := jobs.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return fmt.Errorf("failed to read jobs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var job struct {
+			ID                primitive.ObjectID `bson:"_id"`
+			ClientID          primitive.ObjectID `bson:"clientid"`
+			ServiceProviderID primitive.ObjectID `bson:"serviceproviderid"`
+			Bid               []struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"bid"`
+			Review struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"review"`
+		}
+		if err := cursor.Decode(&job); err != nil {
+			return fmt.Errorf("failed to decode job: %w", err)
+		}
+
+		var bidIDs []primitive.ObjectID
+		for _, bid := range job.Bid {
+			if !bid.ID.IsZero() {
+				bidIDs = append(bidIDs, bid.ID)
+			}
+		}
+		if len(bidIDs) > 0 {
+			filter := bson.M{"_id": bson.M{"$in": bidIDs}, "jobid": bson.M{"$exists": false}}
+			if _, err := db.Collection("bid").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"jobid": job.ID}}); err != nil {
+				return fmt.Errorf("failed to backfill bids of job %s: %w", job.ID.Hex(), err)
+			}
+		}
+
+		if !job.Review.ID.IsZero() {
+			references := map[string]primitive.ObjectID{"jobid": job.ID, "reviewerid": job.ClientID, "revieweeid": job.ServiceProviderID}
+			for field, id := range references {
+				if id.IsZero() {
+					continue
+				}
+				filter := bson.M{"_id": job.Review.ID, field: bson.M{"$exists": false}}
+				if _, err := db.Collection("review").UpdateOne(ctx, filter, bson.M{"$set": bson.M{field: id}}); err != nil {
+					return fmt.Errorf("failed to backfill review of job %s: %w", job.ID.Hex(), err)
+				}
+			}
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"testing"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, m.Version)
+		}
+		if m.Up == nil || m.Down == nil {
+			t.Errorf("Expected migration %d to define Up and Down", m.Version)
+		}
+	}
+}
+
+func TestMigrateToRoundTrip(t *testing.T) {
+	store, ok := Default().(*MongoStore)
+	if !ok {
+		t.Skip("Migrations need MongoDB; set MONGO_TEST_URI to run this test")
+	}
+	ctx := context.Background()
+
+	// Act: revert everything, then apply every migration again
+	if err := store.MigrateTo(ctx, 0); err != nil {
+		t.Fatalf("Failed to revert migrations: %v", err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	// Assert
+	statuses, err := store.Migrations(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read migrations: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt.IsZero() {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+}
+
+func TestUserCreateRejectsDuplicateNID(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	first := structure.User{Name: "First", PhoneNumber: "01700000001", NID: "1984266626987"}
+	if err := store.UserCreate(ctx, "user", &first); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+
+	// Act
+	second := structure.User{Name: "Second", PhoneNumber: "01700000002", NID: "1984266626987"}
+	err := store.UserCreate(ctx, "user", &second)
+
+	// Assert
+	if !IsDuplicateKey(err) {
+		t.Errorf("Expected a duplicate key error for a reused NID, got %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is a Database backed by a MongoDB client and database name.
+type MongoStore struct {
+	client  *mongo.Client
+	dbName  string
+	timeout time.Duration
+
+	// noTransactions is set once the server has rejected a transaction
+	noTransactions atomic.Bool
+}
+
+// Ensure MongoStore implements the Database interface.
+var _ Database = (*MongoStore)(nil)
+
+// NewMongoStore returns a MongoStore that uses the given client and database.
+// Operations are bounded by the default operation timeout.
+func NewMongoStore(client *mongo.Client, dbName string) *MongoStore {
+	return &MongoStore{client: client, dbName: dbName, timeout: DefaultOptions().OperationTimeout}
+}
+
+// Client returns the underlying MongoDB client.
+func (s *MongoStore) Client() *mongo.Client {
+	return s.client
+}
+
+// SetTimeout sets the deadline applied to each operation. A zero duration
+// leaves deadlines entirely to the caller's context.
+func (s *MongoStore) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// collection returns a handle to the named collection in the store's database.
+func (s *MongoStore) collection(collectionName string) *mongo.Collection {
+	return s.client.Database(s.dbName).Collection(collectionName)
+}
+
+// withTimeout bounds ctx by the store's operation timeout.
+func (s *MongoStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+func (s *MongoStore) ClearCollection(ctx context.Context, collectionName string) error {
+	if !contains(CollectionNamesArray, collectionName) {
+		return fmt.Errorf("collection %s does not exist", collectionName)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection(collectionName).DeleteMany(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("failed to clear collection: %w", err)
+	}
+	return s.resetSequence(ctx, collectionName)
+}
+
+func (s *MongoStore) GetAll(ctx context.Context, collectionName string, result interface{}) error {
+	return s.Find(ctx, collectionName, bson.D{}, result)
+}
+
+func (s *MongoStore) Create(ctx context.Context, collectionName string, document interface{}) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Insert the document into the collection
+	res, err := s.collection(collectionName).InsertOne(ctx, document)
+	if err != nil {
+		return fmt.Errorf("failed to insert document into collection %s: %w", collectionName, err)
+	}
+
+	// Set the ObjectID of the inserted document
+	return setInsertedID(document, res.InsertedID)
+}
+
+func (s *MongoStore) UserCreate(ctx context.Context, collectionName string, document interface{}) error {
+	return userCreate(ctx, s, collectionName, document)
+}
+
+func (s *MongoStore) ClientCreate(ctx context.Context, document interface{}) error {
+	return clientCreate(ctx, s, document)
+}
+
+func (s *MongoStore) SPCreate(ctx context.Context, collectionName string, document interface{}) error {
+	return spCreate(ctx, s, collectionName, document)
+}
+
+func (s *MongoStore) Get(ctx context.Context, collectionName string, id string, result interface{}) error {
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err = s.collection(collectionName).FindOne(ctx, bson.M{"_id": objID}).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("document with ID %v %w in collection %s", id, ErrNotFound, collectionName)
+		}
+		return fmt.Errorf("failed to find document in collection %s: %w", collectionName, err)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) Update(ctx context.Context, collectionName string, id string, update interface{}) error {
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.collection(collectionName).UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": update})
+	if err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %w", collectionName, err)
+	}
+
+	// Check if the document was found and updated
+	if result.ModifiedCount == 0 {
+		return fmt.Errorf("document with ID %s %w in collection %s", id, ErrNotFound, collectionName)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) UpdateWhere(ctx context.Context, collectionName string, filter interface{}, update interface{}, result interface{}) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	updated := s.collection(collectionName).FindOneAndUpdate(ctx, filter, update, opts)
+	err := updated.Err()
+	if err == nil && result != nil {
+		err = updated.Decode(result)
+	}
+	if err == mongo.ErrNoDocuments {
+		return fmt.Errorf("no matching document %w in collection %s", ErrNotFound, collectionName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update document in collection %s: %w", collectionName, err)
+	}
+	return nil
+}
+
+func (s *MongoStore) Delete(ctx context.Context, collectionName string, id string) error {
+	// Convert id string to primitive.ObjectID
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.collection(collectionName).DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return fmt.Errorf("failed to delete document from collection %s: %w", collectionName, err)
+	}
+
+	// Check if the document was found and deleted
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("document with ID %s %w in collection %s", id, ErrNotFound, collectionName)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) Find(ctx context.Context, collectionName string, filter interface{}, result interface{}) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	cur, err := s.collection(collectionName).Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find documents in collection %s: %w", collectionName, err)
+	}
+	defer cur.Close(context.Background())
+
+	if err := cur.All(ctx, result); err != nil {
+		return fmt.Errorf("failed to decode documents in collection %s: %w", collectionName, err)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) FindPage(ctx context.Context, collectionName string, filter interface{}, opts FindOptions, result interface{}) (Page, error) {
+	query, err := opts.pageFilter(filter)
+	if err != nil {
+		return Page{}, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Fetch one document past the limit to learn whether another page exists
+	findOpts := options.Find().SetSort(opts.sortKeys())
+	if projection := opts.queryProjection(); projection != nil {
+		findOpts.SetProjection(projection)
+	}
+	if opts.Limit > 0 {
+		findOpts.SetLimit(opts.Limit + 1)
+	}
+
+	cur, err := s.collection(collectionName).Find(ctx, query, findOpts)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to find documents in collection %s: %w", collectionName, err)
+	}
+	defer cur.Close(context.Background())
+
+	var docs []bson.D
+	if err := cur.All(ctx, &docs); err != nil {
+		return Page{}, fmt.Errorf("failed to decode documents in collection %s: %w", collectionName, err)
+	}
+
+	docs, page, err := opts.finishPage(docs)
+	if err != nil {
+		return Page{}, err
+	}
+	if err := decodeDocuments(docs, result); err != nil {
+		return Page{}, fmt.Errorf("failed to decode documents in collection %s: %w", collectionName, err)
+	}
+
+	if opts.CountTotal {
+		if page.Total, err = s.Count(ctx, collectionName, filter); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+func (s *MongoStore) Count(ctx context.Context, collectionName string, filter interface{}) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	count, err := s.collection(collectionName).CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents in collection %s: %w", collectionName, err)
+	}
+	return count, nil
+}
+
+func (s *MongoStore) ClientUpdate(ctx context.Context, userID string, userData bson.M) error {
+	return clientUpdate(ctx, s, userID, userData)
+}
+
+func (s *MongoStore) SPUpdate(ctx context.Context, userID string, userData bson.M) error {
+	return spUpdate(ctx, s, userID, userData)
+}
+
+func (s *MongoStore) PlaceBid(ctx context.Context, jobID string, bid *structure.Bid) error {
+	return placeBid(ctx, s, jobID, bid)
+}
+
+func (s *MongoStore) AcceptBid(ctx context.Context, jobID string, bidID string, actor primitive.ObjectID, reason string) error {
+	return acceptBid(ctx, s, jobID, bidID, actor, reason)
+}
+
+func (s *MongoStore) RejectBid(ctx context.Context, jobID string, bidID string) error {
+	return rejectBid(ctx, s, jobID, bidID)
+}
+
+func (s *MongoStore) UpdateBid(ctx context.Context, bidID string, update bson.M) error {
+	return updateBid(ctx, s, bidID, update)
+}
+
+func (s *MongoStore) DeleteBid(ctx context.Context, bidID string) error {
+	return deleteBid(ctx, s, bidID)
+}
+
+func (s *MongoStore) CreateReview(ctx context.Context, review *structure.Review) error {
+	return createReview(ctx, s, review)
+}
+
+func (s *MongoStore) MoveJob(ctx context.Context, jobID string, transition structure.JobTransition, update bson.M) error {
+	return moveJob(ctx, s, jobID, transition, update)
+}
+
+// setInsertedID copies the inserted ObjectID back into the document's ID
+// field. Documents without a settable ID field of the ID's type, such as maps
+// and structs passed by value, are left as they are: the insert has already
+// succeeded, so there is nothing to report.
+func setInsertedID(document interface{}, insertedID interface{}) error {
+	v := reflect.ValueOf(document)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	objectIDField := v.Elem().FieldByName("ID")
+	id := reflect.ValueOf(insertedID)
+	if !objectIDField.IsValid() || !objectIDField.CanSet() || !id.IsValid() || !id.Type().AssignableTo(objectIDField.Type()) {
+		return nil
+	}
+	objectIDField.Set(id)
+	return nil
+}
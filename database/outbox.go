@@ -0,0 +1,145 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxCollection holds the domain events recorded with the changes they
+// describe, until they have been delivered to their subscribers.
+const OutboxCollection = "outbox"
+
+// OutboxStatus is the state of the delivery of an OutboxEntry.
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"   // waiting for its first or next attempt
+	OutboxDelivered OutboxStatus = "delivered" // handled by every subscriber
+	OutboxDead      OutboxStatus = "dead"      // given up on after too many failed attempts
+)
+
+// OutboxEntry is a domain event in the outbox.
+type OutboxEntry struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty"`
+	Type          structure.EventType `bson:"type"`
+	Payload       bson.Raw            `bson:"payload"`
+	OccurredAt    time.Time           `bson:"occurredat"`
+	Status        OutboxStatus        `bson:"status"`
+	Attempts      int                 `bson:"attempts"`
+	NextAttemptAt time.Time           `bson:"nextattemptat"`
+	DeliveredTo   []string            `bson:"deliveredto,omitempty"` // subscribers that have handled the event
+	LastError     string              `bson:"lasterror,omitempty"`
+}
+
+// Event decodes the entry's payload into the DomainEvent of its type.
+func (e OutboxEntry) Event() (structure.DomainEvent, error) {
+	switch e.Type {
+	case structure.EventBidPlaced:
+		return decodePayload[structure.BidPlaced](e.Payload)
+	case structure.EventBidAccepted:
+		return decodePayload[structure.BidAccepted](e.Payload)
+	case structure.EventJobStatusChanged:
+		return decodePayload[structure.JobStatusChanged](e.Payload)
+	case structure.EventReviewCreated:
+		return decodePayload[structure.ReviewCreated](e.Payload)
+	case structure.EventUserRegistered:
+		return decodePayload[structure.UserRegistered](e.Payload)
+	}
+	return nil, fmt.Errorf("unknown event type %q", e.Type)
+}
+
+func decodePayload[T structure.DomainEvent](payload bson.Raw) (structure.DomainEvent, error) {
+	var event T
+	if err := bson.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", event.EventType(), err)
+	}
+	return event, nil
+}
+
+// recordEvents adds events to the outbox as part of the caller's write, and
+// registers their removal as the compensating action.
+func recordEvents(ctx context.Context, db Database, c *compensator, events ...structure.DomainEvent) error {
+	now := time.Now().UTC()
+	for _, event := range events {
+		payload, err := bson.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+		}
+		entry := OutboxEntry{
+			Type:          event.EventType(),
+			Payload:       payload,
+			OccurredAt:    now,
+			Status:        OutboxPending,
+			NextAttemptAt: now,
+		}
+		if err := db.Create(ctx, OutboxCollection, &entry); err != nil {
+			return err
+		}
+		entryID := entry.ID.Hex()
+		c.onFailure(func(ctx context.Context) error {
+			return db.Delete(ctx, OutboxCollection, entryID)
+		})
+	}
+	return nil
+}
+
+func CreateReview(review *structure.Review) error {
+	return Default().CreateReview(context.Background(), review)
+}
+
+func MoveJob(jobID string, transition structure.JobTransition, update bson.M) error {
+	return Default().MoveJob(context.Background(), jobID, transition, update)
+}
+
+// createReview validates the Review and inserts it, recording ReviewCreated,
+// as a single atomic write.
+func createReview(ctx context.Context, db Database, review *structure.Review) error {
+	if err := review.Validate(); err != nil {
+		return err
+	}
+
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		if err := db.Create(ctx, "review", review); err != nil {
+			return err
+		}
+		reviewID := review.ID.Hex()
+		c.onFailure(func(ctx context.Context) error {
+			return db.Delete(ctx, "review", reviewID)
+		})
+
+		return recordEvents(ctx, db, c, structure.ReviewCreated{
+			ReviewID:   review.ID,
+			JobID:      review.JobID,
+			ReviewerID: review.ReviewerID,
+			RevieweeID: review.RevieweeID,
+		})
+	})
+}
+
+// moveJob applies update, which moves the job with ID jobID by transition,
+// records JobStatusChanged and settles the job's escrow if the move ends the
+// job, as a single atomic write.
+func moveJob(ctx context.Context, db Database, jobID string, transition structure.JobTransition, update bson.M) error {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return fmt.Errorf("failed to parse ID: %v", err)
+	}
+
+	return inTransaction(ctx, db, func(ctx context.Context, c *compensator) error {
+		// The event is recorded first, so that without a transaction a failed
+		// update leaves neither
+		err := recordEvents(ctx, db, c, structure.JobStatusChanged{JobID: id, JobTransition: transition})
+		if err != nil {
+			return err
+		}
+		if err := settleEscrow(ctx, db, c, id, transition.To); err != nil {
+			return err
+		}
+		return db.Update(ctx, "job", jobID, update)
+	})
+}
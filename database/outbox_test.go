@@ -0,0 +1,137 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// outboxEvents returns the events in the outbox, oldest first.
+func outboxEvents(t *testing.T, store Database) []structure.DomainEvent {
+	t.Helper()
+	var entries []OutboxEntry
+	opts := FindOptions{Sort: bson.D{{Key: "occurredat", Value: 1}, {Key: "_id", Value: 1}}}
+	if _, err := store.FindPage(context.Background(), OutboxCollection, bson.M{}, opts, &entries); err != nil {
+		t.Fatalf("Failed to find outbox entries: %v", err)
+	}
+
+	var events []structure.DomainEvent
+	for _, entry := range entries {
+		if entry.Status != OutboxPending || entry.NextAttemptAt.IsZero() {
+			t.Errorf("Expected a pending entry due now, got %+v", entry)
+		}
+		event, err := entry.Event()
+		if err != nil {
+			t.Fatalf("Failed to decode outbox entry: %v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestBiddingRecordsEvents(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job, first, second := newBiddingJob(t, store)
+	owner := primitive.NewObjectID()
+
+	// Act
+	if err := acceptBid(ctx, store, job.ID.Hex(), second.ID.Hex(), owner, "Cheaper"); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+
+	// Assert
+	events := outboxEvents(t, store)
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %+v", events)
+	}
+	if placed, _ := events[0].(structure.BidPlaced); placed.BidID != first.ID || placed.JobID != job.ID || placed.BidAmount != structure.Taka(100) {
+		t.Errorf("Expected the first bid to be placed, got %+v", events[0])
+	}
+	if accepted, _ := events[2].(structure.BidAccepted); accepted.BidID != second.ID || accepted.ServiceProviderID != second.SPID || accepted.Actor != owner {
+		t.Errorf("Expected the second bid to be accepted, got %+v", events[2])
+	}
+	changed, _ := events[3].(structure.JobStatusChanged)
+	if changed.JobID != job.ID || changed.From != structure.JobStatusJobPosted || changed.To != structure.JobStatusBidAccepted || changed.Reason != "Cheaper" {
+		t.Errorf("Expected the job to move to bid_accepted, got %+v", events[3])
+	}
+}
+
+func TestSignupRecordsEvent(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	client := structure.Client{User: structure.User{
+		Name:        "Client 1",
+		PhoneNumber: "01711377006",
+		NID:         "1984266626987",
+		Birthdate:   "05-06-1984",
+		UserType:    structure.UserTypeClient,
+	}}
+
+	// Act
+	if err := clientCreate(context.Background(), store, &client); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Assert
+	events := outboxEvents(t, store)
+	want := structure.UserRegistered{UserID: client.User.ID, UserType: structure.UserTypeClient}
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, events)
+	}
+}
+
+func TestMoveJobRecordsEvent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted}
+	if err := store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	transition, err := job.Transition(structure.JobStatusCancelled, primitive.NewObjectID(), time.Now(), "")
+	if err != nil {
+		t.Fatalf("Failed to move job: %v", err)
+	}
+	update := bson.M{"jobstatus": structure.JobStatusCancelled, "history": []structure.JobTransition{transition}}
+
+	// Act
+	err = store.MoveJob(ctx, job.ID.Hex(), transition, update)
+	missing := store.MoveJob(ctx, primitive.NewObjectID().Hex(), transition, update)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to move job: %v", err)
+	}
+	if !errors.Is(missing, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound moving a missing job, got %v", missing)
+	}
+	events := outboxEvents(t, store)
+	if len(events) != 1 || events[0].(structure.JobStatusChanged).JobID != job.ID {
+		t.Errorf("Expected only the job's move to be recorded, got %+v", events)
+	}
+}
+
+func TestCreateReviewRecordsEvent(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	review := structure.Review{JobID: primitive.NewObjectID(), ReviewerID: primitive.NewObjectID(), RevieweeID: primitive.NewObjectID(), Review: "Great work", Quality: 5}
+
+	// Act
+	if err := createReview(context.Background(), store, &review); err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+
+	// Assert
+	events := outboxEvents(t, store)
+	want := structure.ReviewCreated{ReviewID: review.ID, JobID: review.JobID, ReviewerID: review.ReviewerID, RevieweeID: review.RevieweeID}
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, events)
+	}
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInvalidCursor is returned when an After cursor cannot be decoded or does
+// not belong to the requested sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FindOptions controls paging, ordering and projection of a query.
+type FindOptions struct {
+	Limit      int64    // maximum number of documents to return; 0 means no limit
+	After      string   // cursor returned as Page.NextCursor by the previous page
+	Sort       bson.D   // sort keys with 1 (ascending) or -1 (descending)
+	Fields     []string // fields to include; empty means all fields
+	CountTotal bool     // also count every document matching the filter
+}
+
+// Page describes where a paged query stopped.
+type Page struct {
+	NextCursor string // cursor for the following page, empty on the last page
+	Total      int64  // documents matching the filter, set when CountTotal is true
+}
+
+// ParseSort parses a comma separated list of field names into sort keys. A
+// leading "-" sorts that field in descending order.
+func ParseSort(s string) bson.D {
+	var sort bson.D
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		direction := 1
+		if strings.HasPrefix(field, "-") {
+			field, direction = field[1:], -1
+		}
+		if field != "" {
+			sort = append(sort, bson.E{Key: field, Value: direction})
+		}
+	}
+	return sort
+}
+
+// sortKeys returns the sort order with _id appended as a tie-breaker so that
+// every document has a unique position for cursor pagination.
+func (o FindOptions) sortKeys() bson.D {
+	keys := append(bson.D{}, o.Sort...)
+	direction := 1
+	for _, key := range keys {
+		if key.Key == "_id" {
+			return keys
+		}
+		direction = sortDirection(key)
+	}
+	return append(keys, bson.E{Key: "_id", Value: direction})
+}
+
+// projection returns the MongoDB projection document for Fields, or nil.
+func (o FindOptions) projection() bson.D {
+	if len(o.Fields) == 0 {
+		return nil
+	}
+	projection := bson.D{}
+	for _, field := range o.Fields {
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+	return projection
+}
+
+// pageFilter combines filter with the keyset condition that selects the
+// documents following the After cursor.
+func (o FindOptions) pageFilter(filter interface{}) (interface{}, error) {
+	if o.After == "" {
+		return filter, nil
+	}
+
+	keys := o.sortKeys()
+	values, err := decodeCursor(o.After)
+	if err != nil || len(values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	// For keys k1..kn the next page holds documents where k1 is past the
+	// cursor, or k1 is equal and k2 is past it, and so on. Equality with a
+	// null cursor value also matches documents missing the field.
+	var branches bson.A
+	for i, key := range keys {
+		past, ok := pastCondition(key, values[i])
+		if !ok {
+			continue
+		}
+		branch := bson.D{}
+		for j := 0; j < i; j++ {
+			branch = append(branch, bson.E{Key: keys[j].Key, Value: values[j]})
+		}
+		branch = append(branch, past...)
+		branches = append(branches, branch)
+	}
+	if len(branches) == 0 {
+		// Nothing sorts past the cursor
+		branches = append(branches, bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}})
+	}
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "$or", Value: branches}}}}}, nil
+}
+
+// pastCondition returns the condition selecting the documents whose key
+// sorts after value. MongoDB sorts null and missing values before all others
+// and never matches them with $gt or $lt, so a null cursor value is passed by
+// every non-null value ascending and by nothing descending, and descending
+// past a non-null value also reaches the nulls. It reports false when no
+// document can sort after value.
+func pastCondition(key bson.E, value interface{}) (bson.D, bool) {
+	ascending := sortDirection(key) > 0
+	switch {
+	case value == nil && ascending:
+		return bson.D{{Key: key.Key, Value: bson.D{{Key: "$ne", Value: nil}}}}, true
+	case value == nil:
+		return nil, false
+	case ascending:
+		return bson.D{{Key: key.Key, Value: bson.D{{Key: "$gt", Value: value}}}}, true
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: key.Key, Value: bson.D{{Key: "$lt", Value: value}}}},
+		bson.D{{Key: key.Key, Value: nil}},
+	}}}, true
+}
+
+// nextCursor builds the cursor pointing just past doc in the given sort order.
+// Sort keys that doc lacks are stored as null.
+func nextCursor(doc bson.D, keys bson.D) (string, error) {
+	values := bson.A{}
+	for _, key := range keys {
+		var value interface{}
+		if found := lookupPath(doc, key.Key); len(found) > 0 {
+			value = found[0]
+		}
+		values = append(values, value)
+	}
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: values}})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the sort key values stored in a cursor.
+func decodeCursor(cursor string) (bson.A, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var decoded struct {
+		V bson.A `bson:"v"`
+	}
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded.V, nil
+}
+
+// sortDirection returns -1 for descending sort keys and 1 otherwise.
+func sortDirection(key bson.E) int {
+	if n, ok := toFloat(key.Value); ok && n < 0 {
+		return -1
+	}
+	return 1
+}
+
+// queryProjection returns the projection sent to the backend: the requested
+// fields plus the sort keys needed to build the next cursor.
+func (o FindOptions) queryProjection() bson.D {
+	projection := o.projection()
+	if projection == nil {
+		return nil
+	}
+	for _, key := range o.sortKeys() {
+		if !contains(o.Fields, key.Key) {
+			projection = append(projection, bson.E{Key: key.Key, Value: 1})
+		}
+	}
+	return projection
+}
+
+// finishPage drops the look-ahead document fetched beyond Limit, records the
+// cursor for the next page and applies the requested projection.
+func (o FindOptions) finishPage(docs []bson.D) ([]bson.D, Page, error) {
+	var page Page
+	if o.Limit > 0 && int64(len(docs)) > o.Limit {
+		docs = docs[:o.Limit]
+		cursor, err := nextCursor(docs[len(docs)-1], o.sortKeys())
+		if err != nil {
+			return nil, page, err
+		}
+		page.NextCursor = cursor
+	}
+
+	if len(o.Fields) > 0 {
+		for i, doc := range docs {
+			docs[i] = projectDocument(doc, o.Fields)
+		}
+	}
+	return docs, page, nil
+}
+
+// projectDocument keeps _id and the given dotted fields of doc.
+func projectDocument(doc bson.D, fields []string) bson.D {
+	projected := bson.D{}
+	if id, ok := lookupField(doc, "_id"); ok {
+		projected = append(projected, bson.E{Key: "_id", Value: id})
+	}
+	for _, field := range fields {
+		if field == "_id" {
+			continue
+		}
+		if values := lookupPath(doc, field); len(values) > 0 {
+			projected = setField(projected, field, values[0])
+		}
+	}
+	return projected
+}
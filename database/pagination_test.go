@@ -0,0 +1,103 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFindPageWithTies(t *testing.T) {
+	// Arrange: four bids share the same amount, so only _id breaks the tie
+	ctx := context.Background()
+	store := NewMemoryStore()
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		bid := structure.Bid{Description: "Tied bid", BidAmount: structure.Taka(100)}
+		if err := store.Create(ctx, "bid", &bid); err != nil {
+			t.Fatalf("Failed to insert bid document: %v", err)
+		}
+	}
+
+	// Act: walk the collection one document at a time
+	opts := FindOptions{Limit: 1, Sort: ParseSort("-bidamount"), CountTotal: true}
+	for pages := 0; pages < 10; pages++ {
+		var bids []structure.Bid
+		page, err := store.FindPage(ctx, "bid", bson.M{"description": "Tied bid"}, opts, &bids)
+		if err != nil {
+			t.Fatalf("FindPage returned an error: %v", err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Expected total 4, got %d", page.Total)
+		}
+		for _, bid := range bids {
+			if seen[bid.ID.Hex()] {
+				t.Fatalf("Bid %s returned twice", bid.ID.Hex())
+			}
+			seen[bid.ID.Hex()] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.After = page.NextCursor
+	}
+
+	// Assert
+	if len(seen) != 4 {
+		t.Errorf("Expected to page through 4 bids, got %d", len(seen))
+	}
+}
+
+func TestFindPageInvalidCursor(t *testing.T) {
+	store := NewMemoryStore()
+	var bids []structure.Bid
+	_, err := store.FindPage(context.Background(), "bid", bson.M{}, FindOptions{After: "bogus"}, &bids)
+	if err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestFindPageWithMissingSortValues(t *testing.T) {
+	// Arrange: two points lack the sort field and one has it set to null
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, doc := range []bson.M{
+		{"title": "a", "rank": 2},
+		{"title": "b"},
+		{"title": "c", "rank": nil},
+		{"title": "d", "rank": 1},
+		{"title": "e"},
+	} {
+		if err := store.Create(ctx, "point", doc); err != nil {
+			t.Fatalf("Failed to insert point document: %v", err)
+		}
+	}
+
+	for _, sort := range []string{"rank", "-rank"} {
+		t.Run(sort, func(t *testing.T) {
+			// Act: walk the collection one document at a time
+			seen := map[string]bool{}
+			opts := FindOptions{Limit: 1, Sort: ParseSort(sort)}
+			for pages := 0; pages < 10; pages++ {
+				var points []bson.M
+				page, err := store.FindPage(ctx, "point", bson.M{}, opts, &points)
+				if err != nil {
+					t.Fatalf("FindPage returned an error: %v", err)
+				}
+				for _, point := range points {
+					seen[point["title"].(string)] = true
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				opts.After = page.NextCursor
+			}
+
+			// Assert
+			if len(seen) != 5 {
+				t.Errorf("Expected to page through 5 points, got %v", seen)
+			}
+		})
+	}
+}
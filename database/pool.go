@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Backends accepted in Options.Backend.
+const (
+	BackendMongo  = "mongo"
+	BackendMemory = "memory"
+)
+
+// Options configures the database backend and its MongoDB connection pool.
+type Options struct {
+	Backend                string
+	URI                    string
+	DBName                 string
+	MaxPoolSize            uint64
+	MinPoolSize            uint64
+	MaxConnIdleTime        time.Duration
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	OperationTimeout       time.Duration // default deadline for each database operation
+	AutoMigrate            bool          // apply pending migrations when opening a MongoDB store
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		Backend:                BackendMongo,
+		URI:                    "mongodb://localhost:27017",
+		DBName:                 dbName,
+		MaxPoolSize:            100,
+		MinPoolSize:            0,
+		MaxConnIdleTime:        5 * time.Minute,
+		ConnectTimeout:         10 * time.Second,
+		ServerSelectionTimeout: 10 * time.Second,
+		OperationTimeout:       10 * time.Second,
+		AutoMigrate:            true,
+	}
+}
+
+// clientOptions converts Options into MongoDB driver client options.
+func (o Options) clientOptions() *options.ClientOptions {
+	return options.Client().
+		ApplyURI(o.URI).
+		SetMaxPoolSize(o.MaxPoolSize).
+		SetMinPoolSize(o.MinPoolSize).
+		SetMaxConnIdleTime(o.MaxConnIdleTime).
+		SetConnectTimeout(o.ConnectTimeout).
+		SetServerSelectionTimeout(o.ServerSelectionTimeout)
+}
+
+// Open connects to MongoDB once and returns a store backed by a single
+// connection pool, applying pending migrations if opts.AutoMigrate is set.
+// Callers must Close the store on shutdown.
+func Open(ctx context.Context, opts Options) (*MongoStore, error) {
+	client, err := mongo.Connect(ctx, opts.clientOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %v", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %v", err)
+	}
+
+	store := NewMongoStore(client, opts.DBName)
+	store.SetTimeout(opts.OperationTimeout)
+	if opts.AutoMigrate {
+		if err := store.Migrate(ctx); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Close disconnects the store's client and releases its pooled connections.
+func (s *MongoStore) Close(ctx context.Context) error {
+	if err := s.client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("failed to disconnect from MongoDB: %v", err)
+	}
+	return nil
+}
+
+// OpenDatabase returns the backend selected by opts.Backend.
+func OpenDatabase(ctx context.Context, opts Options) (Database, error) {
+	switch opts.Backend {
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendMongo, "":
+		return Open(ctx, opts)
+	default:
+		return nil, fmt.Errorf("unknown database backend %q", opts.Backend)
+	}
+}
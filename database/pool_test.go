@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.URI = "mongodb://db.example:27017"
+	opts.MaxPoolSize = 50
+	opts.MinPoolSize = 5
+	opts.MaxConnIdleTime = time.Minute
+
+	clientOpts := opts.clientOptions()
+
+	if clientOpts.MaxPoolSize == nil || *clientOpts.MaxPoolSize != 50 {
+		t.Errorf("Expected MaxPoolSize 50, got %v", clientOpts.MaxPoolSize)
+	}
+	if clientOpts.MinPoolSize == nil || *clientOpts.MinPoolSize != 5 {
+		t.Errorf("Expected MinPoolSize 5, got %v", clientOpts.MinPoolSize)
+	}
+	if clientOpts.MaxConnIdleTime == nil || *clientOpts.MaxConnIdleTime != time.Minute {
+		t.Errorf("Expected MaxConnIdleTime 1m, got %v", clientOpts.MaxConnIdleTime)
+	}
+	if len(clientOpts.Hosts) != 1 || clientOpts.Hosts[0] != "db.example:27017" {
+		t.Errorf("Expected host db.example:27017, got %v", clientOpts.Hosts)
+	}
+}
+
+func TestSetDefaultAndClose(t *testing.T) {
+	defaultMu.Lock()
+	previous := defaultStore
+	defaultMu.Unlock()
+	defer SetDefault(previous)
+
+	store := &closeRecorder{}
+	SetDefault(store)
+
+	if Default() != store {
+		t.Fatal("Expected Default to return the store passed to SetDefault")
+	}
+	if err := Close(context.Background()); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+	if !store.closed {
+		t.Error("Expected Close to close the default store")
+	}
+}
+
+type closeRecorder struct {
+	Database
+	closed bool
+}
+
+func (c *closeRecorder) Close(ctx context.Context) error {
+	c.closed = true
+	return nil
+}
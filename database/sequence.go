@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// countersCollection holds one {_id: name, seq: n} document per sequence.
+const countersCollection = "counters"
+
+// ErrDuplicateKey is returned by the in-memory backend when an insert would
+// violate a unique index. Use IsDuplicateKey to test errors from any backend.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// uniqueIndex describes a unique index that every backend enforces.
+// Documents whose field is missing or not greater than unset, such as a zero
+// UserID or an empty phone number, are not constrained.
+type uniqueIndex struct {
+	collection string
+	field      string
+	unset      interface{}
+}
+
+var (
+	userIDIndex      = uniqueIndex{collection: "user", field: "userid", unset: 0}
+	phoneNumberIndex = uniqueIndex{collection: "user", field: "phonenumber", unset: ""}
+	nidIndex         = uniqueIndex{collection: "user", field: "nid", unset: ""}
+)
+
+// uniqueIndexes lists the unique indexes of the sumon database. The
+// migrations create them in MongoDB; the in-memory backend checks them itself.
+var uniqueIndexes = []uniqueIndex{userIDIndex, phoneNumberIndex, nidIndex, journalKeyIndex}
+
+// IsDuplicateKey reports whether err was caused by a unique index violation.
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrDuplicateKey) || mongo.IsDuplicateKeyError(err)
+}
+
+func (s *MongoStore) NextSequence(ctx context.Context, name string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Increment atomically, creating the counter on first use
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.collection(countersCollection).
+		FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": 1}}, opts).
+		Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment sequence %s: %w", name, err)
+	}
+	return counter.Seq, nil
+}
+
+func (s *MongoStore) AdvanceSequence(ctx context.Context, name string, atLeast int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collection(countersCollection).UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{"$max": bson.M{"seq": atLeast}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to advance sequence %s: %w", name, err)
+	}
+	return nil
+}
+
+// resetSequence removes the counter so the sequence starts again from 1.
+func (s *MongoStore) resetSequence(ctx context.Context, name string) error {
+	_, err := s.collection(countersCollection).DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return fmt.Errorf("failed to reset sequence %s: %w", name, err)
+	}
+	return nil
+}
+
+// model returns the MongoDB index model for the unique index.
+func (index uniqueIndex) model() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: index.field, Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{index.field: bson.M{"$gt": index.unset}}),
+	}
+}
+
+func (s *MemoryStore) NextSequence(ctx context.Context, name string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequences[name]++
+	return s.sequences[name], nil
+}
+
+func (s *MemoryStore) AdvanceSequence(ctx context.Context, name string, atLeast int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sequences[name] < atLeast {
+		s.sequences[name] = atLeast
+	}
+	return nil
+}
+
+// checkUnique returns ErrDuplicateKey if doc collides with another stored
+// document on one of the collection's unique indexes. The caller must hold s.mu.
+func (s *MemoryStore) checkUnique(collectionName string, doc bson.D) error {
+	id, _ := lookupField(doc, "_id")
+	for _, index := range uniqueIndexes {
+		if index.collection != collectionName {
+			continue
+		}
+		value, ok := lookupField(doc, index.field)
+		if !ok || !compareOperator("$gt", value, index.unset) {
+			continue
+		}
+		for _, existing := range s.collections[collectionName] {
+			if existingID, _ := lookupField(existing, "_id"); valuesEqual(existingID, id) {
+				continue
+			}
+			if other, ok := lookupField(existing, index.field); ok && valuesEqual(other, value) {
+				return fmt.Errorf("%w on %s.%s: %v", ErrDuplicateKey, collectionName, index.field, value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUserCreateConcurrentUserIDs(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// Act: register users concurrently
+	const signups = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, signups)
+	for i := 0; i < signups; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := structure.User{Name: fmt.Sprintf("User %d", i), PhoneNumber: fmt.Sprintf("017%08d", i)}
+			errs <- store.UserCreate(ctx, "user", &user)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Failed to insert user document: %v", err)
+		}
+	}
+
+	// Assert: every user received a distinct UserID
+	var users []structure.User
+	if err := store.GetAll(ctx, "user", &users); err != nil {
+		t.Fatalf("Failed to retrieve user documents: %v", err)
+	}
+	seen := map[int]bool{}
+	for _, user := range users {
+		if seen[user.UserID] {
+			t.Errorf("UserID %d assigned twice", user.UserID)
+		}
+		seen[user.UserID] = true
+	}
+	if len(seen) != signups {
+		t.Errorf("Expected %d distinct UserIDs, got %d", signups, len(seen))
+	}
+}
+
+func TestUserCreateDoesNotReuseDeletedUserID(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	first := structure.User{Name: "First", PhoneNumber: "01700000001"}
+	second := structure.User{Name: "Second", PhoneNumber: "01700000002"}
+	if err := store.UserCreate(ctx, "user", &first); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+	if err := store.UserCreate(ctx, "user", &second); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+
+	// Act: delete the first user and register another
+	if err := store.Delete(ctx, "user", first.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete user document: %v", err)
+	}
+	third := structure.User{Name: "Third", PhoneNumber: "01700000003"}
+	if err := store.UserCreate(ctx, "user", &third); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+
+	// Assert
+	if third.UserID != 3 {
+		t.Errorf("Expected UserID 3, got %d", third.UserID)
+	}
+}
+
+func TestUserCreateRetriesOnDuplicateUserID(t *testing.T) {
+	// Arrange: users stored before the counter existed
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i := 1; i <= 2; i++ {
+		legacy := structure.User{UserID: i, Name: "Legacy", PhoneNumber: fmt.Sprintf("0170000000%d", i)}
+		if err := store.Create(ctx, "user", &legacy); err != nil {
+			t.Fatalf("Failed to insert legacy user document: %v", err)
+		}
+	}
+
+	// Act
+	user := structure.User{Name: "New", PhoneNumber: "01700000009"}
+	if err := store.UserCreate(ctx, "user", &user); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+
+	// Assert
+	if user.UserID != 3 {
+		t.Errorf("Expected UserID 3 after skipping legacy IDs, got %d", user.UserID)
+	}
+}
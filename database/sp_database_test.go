@@ -8,6 +8,7 @@ import (
 
 func TestCreateSP(t *testing.T) {
     // Arrange: Clear the "user" and "serviceProvider" collections
+    ClearCollection("user")
     ClearCollection("serviceProvider")
     
     // Act: Define a service provider document to insert
@@ -16,7 +17,7 @@ func TestCreateSP(t *testing.T) {
             // Manually setting other fields
             Name:        "Service Provider 1",
             PhoneNumber: "01711377008",
-            NID:         "198426662698745",
+            NID:         "1984266626987",
             Birthdate:   "05-06-1984",
             FatherName:  "Father 1",
             MotherName:  "Mother 1",
@@ -26,7 +27,7 @@ func TestCreateSP(t *testing.T) {
         Location:           "City X",
         Education:          structure.Education{Level: "Bachelor", Institute: "University Y"},
         VerifiedByPorichoy: true,
-        SPBalance:          structure.Balance{Amount: 1000},
+        SPBalance:          structure.Balance{Amount: structure.Taka(1000)},
     }
 
     // Call the SPCreate function to insert the service provider document
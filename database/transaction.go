@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTransactionsUnsupported is returned by WithTransaction when the backend
+// cannot run multi-document transactions, e.g. a standalone mongod.
+var ErrTransactionsUnsupported = errors.New("transactions are not supported by this database")
+
+// illegalOperationCode is the server error code MongoDB returns when a
+// transaction is started against a standalone server.
+const illegalOperationCode = 20
+
+func (s *MongoStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.noTransactions.Load() {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	if isTransactionsUnsupported(err) {
+		s.noTransactions.Store(true)
+		return ErrTransactionsUnsupported
+	}
+	return err
+}
+
+// isTransactionsUnsupported reports whether err means the server rejected
+// the transaction because it is not part of a replica set.
+func isTransactionsUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == illegalOperationCode {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
+
+func (s *MemoryStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return ErrTransactionsUnsupported
+}
+
+// compensator collects the actions that undo writes made outside a
+// transaction.
+type compensator struct {
+	undo []func(ctx context.Context) error
+
+	// transactional is set when the writes run in a database transaction,
+	// which a failed write aborts, so that none of them may be let fail
+	transactional bool
+}
+
+// onFailure registers an action that reverses a completed write.
+func (c *compensator) onFailure(undo func(ctx context.Context) error) {
+	c.undo = append(c.undo, undo)
+}
+
+// inTransaction runs fn atomically. It uses a database transaction when the
+// backend supports one; otherwise it runs fn directly and, if fn fails, runs
+// the registered compensating actions in reverse order.
+func inTransaction(ctx context.Context, db Database, fn func(ctx context.Context, c *compensator) error) error {
+	err := db.WithTransaction(ctx, func(ctx context.Context) error {
+		return fn(ctx, &compensator{transactional: true})
+	})
+	if !errors.Is(err, ErrTransactionsUnsupported) {
+		return err
+	}
+
+	c := &compensator{}
+	if err := fn(ctx, c); err != nil {
+		// Undo even if the request was cancelled part way through
+		undoCtx := context.WithoutCancel(ctx)
+		for i := len(c.undo) - 1; i >= 0; i-- {
+			if undoErr := c.undo[i](undoCtx); undoErr != nil {
+				log.Printf("Error compensating failed write: %v", undoErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreUpdate returns the update that undoes setting the fields of update
+// on the previous document: the fields it had are set back and the others
+// are unset, rather than left as null.
+func restoreUpdate(previous bson.D, update bson.M) bson.D {
+	set, unset := bson.D{}, bson.D{}
+	for key := range update {
+		if values := lookupPath(previous, key); len(values) > 0 {
+			set = append(set, bson.E{Key: key, Value: values[0]})
+		} else {
+			unset = append(unset, bson.E{Key: key, Value: ""})
+		}
+	}
+	restore := bson.D{}
+	if len(set) > 0 {
+		restore = append(restore, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		restore = append(restore, bson.E{Key: "$unset", Value: unset})
+	}
+	return restore
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"Go-sumon/structure"
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// failingStore is a MemoryStore whose writes to one collection fail.
+type failingStore struct {
+	*MemoryStore
+	failCollection string
+}
+
+func (s *failingStore) Create(ctx context.Context, collectionName string, document interface{}) error {
+	if collectionName == s.failCollection {
+		return errors.New("insert failed")
+	}
+	return s.MemoryStore.Create(ctx, collectionName, document)
+}
+
+func (s *failingStore) Update(ctx context.Context, collectionName string, id string, update interface{}) error {
+	if collectionName == s.failCollection {
+		return errors.New("update failed")
+	}
+	return s.MemoryStore.Update(ctx, collectionName, id, update)
+}
+
+func TestClientCreateCompensatesFailedClientInsert(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &failingStore{MemoryStore: NewMemoryStore(), failCollection: "client"}
+	client := structure.Client{
+		User:     structure.User{Name: "Client", PhoneNumber: "01711377006", UserType: structure.UserTypeClient},
+		Location: "Dhaka",
+	}
+
+	// Act
+	if err := clientCreate(ctx, store, &client); err == nil {
+		t.Fatal("Expected ClientCreate to fail when the client insert fails")
+	}
+
+	// Assert: the user was removed, so the phone number can register again
+	count, err := store.Count(ctx, "user", bson.M{"phonenumber": "01711377006"})
+	if err != nil {
+		t.Fatalf("Failed to count user documents: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected orphaned user to be deleted, found %d", count)
+	}
+
+	store.failCollection = ""
+	if err := clientCreate(ctx, store, &client); err != nil {
+		t.Errorf("Expected the phone number to be registrable again: %v", err)
+	}
+}
+
+func TestSPUpdateUpdatesEmbeddedUser(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	serviceProvider := structure.ServiceProvider{
+		User:  structure.User{Name: "Original", PhoneNumber: "01711377010", UserType: structure.UserTypeServiceProvider},
+		Skill: "Plumbing",
+	}
+	if err := spCreate(ctx, store, "serviceProvider", &serviceProvider); err != nil {
+		t.Fatalf("Failed to insert service provider document: %v", err)
+	}
+
+	// Act
+	if err := spUpdate(ctx, store, serviceProvider.ID.Hex(), bson.M{"phonenumber": "01711377011"}); err != nil {
+		t.Fatalf("Failed to update service provider: %v", err)
+	}
+
+	// Assert
+	var updated structure.ServiceProvider
+	if err := store.Get(ctx, "serviceProvider", serviceProvider.ID.Hex(), &updated); err != nil {
+		t.Fatalf("Failed to retrieve service provider document: %v", err)
+	}
+	if updated.User.PhoneNumber != "01711377011" {
+		t.Errorf("Expected user.phonenumber to be 01711377011, got %q", updated.User.PhoneNumber)
+	}
+	var document bson.D
+	if err := store.Get(ctx, "serviceProvider", serviceProvider.ID.Hex(), &document); err != nil {
+		t.Fatalf("Failed to retrieve service provider document: %v", err)
+	}
+	if _, ok := lookupField(document, "phonenumber"); ok {
+		t.Error("Expected no top-level phonenumber field in the service provider document")
+	}
+}
+
+func TestSPUpdateRestoresUserOnFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	serviceProvider := structure.ServiceProvider{
+		User:  structure.User{Name: "Original", PhoneNumber: "01711377008", UserType: structure.UserTypeServiceProvider},
+		Skill: "Plumbing",
+	}
+	if err := spCreate(ctx, store, "serviceProvider", &serviceProvider); err != nil {
+		t.Fatalf("Failed to insert service provider document: %v", err)
+	}
+
+	// Act
+	store.failCollection = "serviceProvider"
+	if err := spUpdate(ctx, store, serviceProvider.ID.Hex(), bson.M{"name": "Changed"}); err == nil {
+		t.Fatal("Expected SPUpdate to fail when the service provider update fails")
+	}
+
+	// Assert
+	var user structure.User
+	if err := store.Get(ctx, "user", serviceProvider.ID.Hex(), &user); err != nil {
+		t.Fatalf("Failed to retrieve user document: %v", err)
+	}
+	if user.Name != "Original" {
+		t.Errorf("Expected user name to be restored to Original, got %q", user.Name)
+	}
+}
+
+func TestSPUpdateUnsetsAddedFieldsOnFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	serviceProvider := structure.ServiceProvider{
+		User:  structure.User{Name: "Original", PhoneNumber: "01711377009", UserType: structure.UserTypeServiceProvider},
+		Skill: "Plumbing",
+	}
+	if err := spCreate(ctx, store, "serviceProvider", &serviceProvider); err != nil {
+		t.Fatalf("Failed to insert service provider document: %v", err)
+	}
+
+	// Act
+	store.failCollection = "serviceProvider"
+	if err := spUpdate(ctx, store, serviceProvider.ID.Hex(), bson.M{"name": "Changed", "nickname": "Fixer"}); err == nil {
+		t.Fatal("Expected SPUpdate to fail when the service provider update fails")
+	}
+
+	// Assert: the field the user did not have is removed, not set to null
+	var user bson.M
+	if err := store.Get(ctx, "user", serviceProvider.ID.Hex(), &user); err != nil {
+		t.Fatalf("Failed to retrieve user document: %v", err)
+	}
+	if user["name"] != "Original" {
+		t.Errorf("Expected user name to be restored to Original, got %v", user["name"])
+	}
+	if value, ok := user["nickname"]; ok {
+		t.Errorf("Expected nickname to be removed, got %v", value)
+	}
+}
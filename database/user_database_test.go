@@ -2,7 +2,6 @@ package database
 
 import (
 	"Go-sumon/structure"
-	"context"
 	"fmt"
 
 	"reflect"
@@ -17,7 +16,7 @@ func TestGetAllUser(t *testing.T) {
 	ClearCollection("user")
 
 	// Get the count of existing users
-	count, err := collection.CountDocuments(context.Background(), bson.D{})
+	count, err := Count("user", bson.D{})
 	if err != nil {
 		t.Fatalf("Failed to count existing users: %v", err)
 	}
@@ -29,8 +28,8 @@ func TestGetAllUser(t *testing.T) {
 		user := structure.User{
 			ID:          id, // Assign the ObjectID directly
 			Name:        fmt.Sprintf("User %d", count+i+1),
-			PhoneNumber: fmt.Sprintf("123456789%d", count+i),
-			NID:         fmt.Sprintf("NID%d", count+i+1),
+			PhoneNumber: fmt.Sprintf("012345678%02d", count+i),
+			NID:         fmt.Sprintf("10000000000%02d", count+i+1),
 			Birthdate:   fmt.Sprintf("200%d-01-01", count+i+1),
 			FatherName:  fmt.Sprintf("Father %d", count+i+1),
 			MotherName:  fmt.Sprintf("Mother %d", count+i+1),
@@ -71,8 +70,8 @@ func TestCreateUser(t *testing.T) {
 	// Define a client user document to insert
 	clientUser := structure.User{
 		Name:        "Client User",
-		PhoneNumber: "0123456789",
-		NID:         "NID1",
+		PhoneNumber: "01234567891",
+		NID:         "1000000000001",
 		Birthdate:   "2001-01-01",
 		FatherName:  "Client Father",
 		MotherName:  "Client Mother",
@@ -82,8 +81,8 @@ func TestCreateUser(t *testing.T) {
 	// Define a service provider user document to insert
 	serviceProviderUser := structure.User{
 		Name:        "Service Provider User",
-		PhoneNumber: "0987654321",
-		NID:         "NID2",
+		PhoneNumber: "09876543210",
+		NID:         "1000000000002",
 		Birthdate:   "1990-01-01",
 		FatherName:  "SP Father",
 		MotherName:  "SP Mother",
@@ -143,8 +142,8 @@ func TestGetUser(t *testing.T) {
 	// Insert two user documents
 	expectedUser1 := structure.User{
 		Name:        "User One",
-		PhoneNumber: "1234567890",
-		NID:         "NID123456",
+		PhoneNumber: "01234567890",
+		NID:         "1000000123456",
 		Birthdate:   "2000-01-01",
 		FatherName:  "Father One",
 		MotherName:  "Mother One",
@@ -152,8 +151,8 @@ func TestGetUser(t *testing.T) {
 	}
 	expectedUser2 := structure.User{
 		Name:        "User Two",
-		PhoneNumber: "0987654321",
-		NID:         "NID654321",
+		PhoneNumber: "09876543210",
+		NID:         "1000000654321",
 		Birthdate:   "2001-01-01",
 		FatherName:  "Father Two",
 		MotherName:  "Mother Two",
@@ -187,8 +186,8 @@ func TestUpdateUser(t *testing.T) {
 	// Insert a user document
 	expectedUser := structure.User{
 		Name:        "Initial User",
-		PhoneNumber: "1234567890",
-		NID:         "NID123456",
+		PhoneNumber: "01234567890",
+		NID:         "1000000123456",
 		Birthdate:   "2000-01-01",
 		FatherName:  "Initial Father",
 		MotherName:  "Initial Mother",
@@ -201,7 +200,7 @@ func TestUpdateUser(t *testing.T) {
 	// Define the update data
 	updateData := bson.M{
 		"name":       "Updated User",
-		"nid":        "NID654321",
+		"nid":        "1000000654321",
 		"birthdate":  "2000-01-02",
 		"fathername": "Updated Father",
 		"mothername": "Updated Mother",
@@ -241,8 +240,8 @@ func TestDeleteUser(t *testing.T) {
 	expectedUser1 := structure.User{
 		ID:          primitive.NewObjectID(),
 		Name:        "User One",
-		PhoneNumber: "1234567890",
-		NID:         "NID123456",
+		PhoneNumber: "01234567890",
+		NID:         "1000000123456",
 		Birthdate:   "2000-01-01",
 		FatherName:  "Father One",
 		MotherName:  "Mother One",
@@ -251,8 +250,8 @@ func TestDeleteUser(t *testing.T) {
 	expectedUser2 := structure.User{
 		ID:          primitive.NewObjectID(),
 		Name:        "User Two",
-		PhoneNumber: "0987654321",
-		NID:         "NID654321",
+		PhoneNumber: "09876543210",
+		NID:         "1000000654321",
 		Birthdate:   "2001-01-01",
 		FatherName:  "Father Two",
 		MotherName:  "Mother Two",
@@ -261,8 +260,8 @@ func TestDeleteUser(t *testing.T) {
 	expectedUser3 := structure.User{
 		ID:          primitive.NewObjectID(),
 		Name:        "User Three",
-		PhoneNumber: "0123456789",
-		NID:         "NID789012",
+		PhoneNumber: "01234567891",
+		NID:         "1000000789012",
 		Birthdate:   "2002-01-01",
 		FatherName:  "Father Three",
 		MotherName:  "Mother Three",
@@ -316,8 +315,8 @@ func TestFindUser(t *testing.T) {
 	expectedUser1 := structure.User{
 		ID:          primitive.NewObjectID(),
 		Name:        "User One",
-		PhoneNumber: "1234567890",
-		NID:         "NID123456",
+		PhoneNumber: "01234567890",
+		NID:         "1000000123456",
 		Birthdate:   "2000-01-01",
 		FatherName:  "Father One",
 		MotherName:  "Mother One",
@@ -326,8 +325,8 @@ func TestFindUser(t *testing.T) {
 	expectedUser2 := structure.User{
 		ID:          primitive.NewObjectID(),
 		Name:        "User Two",
-		PhoneNumber: "0987654321",
-		NID:         "NID654321",
+		PhoneNumber: "09876543210",
+		NID:         "1000000654321",
 		Birthdate:   "2001-01-01",
 		FatherName:  "Father Two",
 		MotherName:  "Mother Two",
@@ -0,0 +1,34 @@
+package feed
+
+import (
+	"context"
+
+	"Go-sumon/auction"
+	"Go-sumon/structure"
+)
+
+// AuctionNotifier publishes the jobs the auction scheduler closes to Hub.
+type AuctionNotifier struct {
+	Hub Hub
+}
+
+func (n AuctionNotifier) Notify(ctx context.Context, event auction.Event) error {
+	topics := []string{JobTopic(event.JobID), UserTopic(event.ClientID)}
+	switch event.Type {
+	case auction.EventBidWon:
+		topics = append(topics, UserTopic(event.ServiceProviderID))
+		accepted := Event{Type: EventBidAccepted, JobID: event.JobID, BidID: event.BidID, ServiceProviderID: event.ServiceProviderID, At: event.At}
+		if err := n.Hub.Publish(ctx, accepted, topics...); err != nil {
+			return err
+		}
+		return n.Hub.Publish(ctx, statusChanged(event, structure.JobStatusBidAccepted), topics...)
+	case auction.EventExpired:
+		return n.Hub.Publish(ctx, statusChanged(event, structure.JobStatusCancelled), topics...)
+	}
+	return nil
+}
+
+// statusChanged is the event of a job the scheduler moved out of job_posted.
+func statusChanged(event auction.Event, to structure.JobStatus) Event {
+	return Event{Type: EventJobStatusChanged, JobID: event.JobID, From: structure.JobStatusJobPosted, To: to, At: event.At}
+}
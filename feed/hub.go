@@ -0,0 +1,71 @@
+// Package feed pushes events about jobs and bids to the users following a
+// job or their own account.
+package feed
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrClosed is returned when publishing to or subscribing to a closed Hub.
+var ErrClosed = errors.New("feed is closed")
+
+// EventType names what happened to a job or bid.
+type EventType string
+
+const (
+	// EventBidCreated is published when a bid is placed on a job.
+	EventBidCreated EventType = "bid_created"
+	// EventBidAccepted is published when a job's bid is accepted, by its
+	// client or when bidding closes.
+	EventBidAccepted EventType = "bid_accepted"
+	// EventJobStatusChanged is published when a job moves to another status.
+	EventJobStatusChanged EventType = "job_status_changed"
+	// EventOutbid is published to a service provider whose bid was the lowest
+	// on a job until another bidder placed a lower one.
+	EventOutbid EventType = "outbid"
+)
+
+// Event is one change pushed to subscribers.
+type Event struct {
+	ID                string              `json:"id"` // assigned when published
+	Type              EventType           `json:"type"`
+	JobID             primitive.ObjectID  `json:"jobId"`
+	BidID             primitive.ObjectID  `json:"bidId,omitempty"`
+	ServiceProviderID primitive.ObjectID  `json:"serviceProviderId,omitempty"` // who made the bid
+	BidAmount         structure.Money     `json:"bidAmount,omitempty"`         // left out for sealed jobs
+	From              structure.JobStatus `json:"from,omitempty"`
+	To                structure.JobStatus `json:"to,omitempty"`
+	At                time.Time           `json:"at"`
+}
+
+// JobTopic is the topic of the events about the job with ID id.
+func JobTopic(id primitive.ObjectID) string {
+	return "job:" + id.Hex()
+}
+
+// UserTopic is the topic of the events for the user with ID id.
+func UserTopic(id primitive.ObjectID) string {
+	return "user:" + id.Hex()
+}
+
+// Hub delivers published events to the subscribers of their topics.
+// MemoryHub delivers them within one process; a Hub backed by MongoDB change
+// streams could deliver them across replicas.
+type Hub interface {
+	// Publish assigns the event an ID and delivers it to the subscribers of
+	// each topic.
+	Publish(ctx context.Context, event Event, topics ...string) error
+
+	// Subscribe returns the events published to topic after the event with
+	// ID lastEventID, or only new events when lastEventID is empty. The
+	// channel is closed when ctx is done, when the Hub closes, or when the
+	// subscriber falls too far behind; it may then subscribe again from the
+	// last event it received.
+	Subscribe(ctx context.Context, topic string, lastEventID string) (<-chan Event, error)
+}
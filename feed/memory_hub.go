@@ -0,0 +1,182 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a MemoryHub.
+type Options struct {
+	History int // events kept to replay to subscribers that reconnect
+	Buffer  int // events queued per subscriber before it is dropped as too slow
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		History: 1000,
+		Buffer:  64,
+	}
+}
+
+// published is an event in the history of a MemoryHub.
+type published struct {
+	event  Event
+	topics []string
+}
+
+// subscriber is one Subscribe call of a MemoryHub.
+type subscriber struct {
+	topic  string
+	events chan Event
+}
+
+// MemoryHub is a Hub within one process. Event IDs are "<epoch>-<seq>",
+// where epoch identifies the hub, so a subscriber reconnecting after a
+// restart is sent the whole history.
+type MemoryHub struct {
+	opts  Options
+	epoch string
+	now   func() time.Time
+
+	mu          sync.Mutex
+	seq         uint64
+	history     []published // the last opts.History events, oldest first
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+// NewMemoryHub returns an empty MemoryHub.
+func NewMemoryHub(opts Options) *MemoryHub {
+	return &MemoryHub{
+		opts:        opts,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		now:         time.Now,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (h *MemoryHub) Publish(ctx context.Context, event Event, topics ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrClosed
+	}
+
+	h.seq++
+	event.ID = fmt.Sprintf("%s-%d", h.epoch, h.seq)
+	if event.At.IsZero() {
+		event.At = h.now().UTC()
+	}
+	h.history = append(h.history, published{event: event, topics: topics})
+	if len(h.history) > h.opts.History {
+		h.history = h.history[len(h.history)-h.opts.History:]
+	}
+
+	for sub := range h.subscribers {
+		if !contains(topics, sub.topic) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Too slow: drop the subscriber, which replays what it missed
+			// when it subscribes again
+			h.drop(sub)
+		}
+	}
+	return nil
+}
+
+func (h *MemoryHub) Subscribe(ctx context.Context, topic string, lastEventID string) (<-chan Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+
+	var replay []Event
+	for _, p := range h.since(lastEventID) {
+		if contains(p.topics, topic) {
+			replay = append(replay, p.event)
+		}
+	}
+	sub := &subscriber{topic: topic, events: make(chan Event, h.opts.Buffer+len(replay))}
+	for _, event := range replay {
+		sub.events <- event
+	}
+	h.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.drop(sub)
+	}()
+	return sub.events, nil
+}
+
+// Close ends every subscription. Later calls to Publish and Subscribe return
+// ErrClosed.
+func (h *MemoryHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub)
+	}
+}
+
+// since returns the history after the event with ID lastEventID: none when
+// it is empty, and all of it when the ID is from another epoch or too old to
+// be kept.
+func (h *MemoryHub) since(lastEventID string) []published {
+	if lastEventID == "" {
+		return nil
+	}
+	epoch, seq, _ := strings.Cut(lastEventID, "-")
+	last, err := strconv.ParseUint(seq, 10, 64)
+	if epoch != h.epoch || err != nil {
+		return h.history
+	}
+
+	// The history holds the events numbered first to h.seq
+	first := h.seq - uint64(len(h.history)) + 1
+	switch {
+	case last >= h.seq:
+		return nil
+	case last < first:
+		return h.history
+	}
+	return h.history[last-first+1:]
+}
+
+// drop removes sub and closes its channel, if it has not been dropped
+// already. h.mu must be held.
+func (h *MemoryHub) drop(sub *subscriber) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func contains(topics []string, topic string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package feed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// receive returns the next event on events, failing if none arrives.
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Expected an event, the subscription ended")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event, got none")
+	}
+	return Event{}
+}
+
+func TestMemoryHubDeliversToTopic(t *testing.T) {
+	// Arrange
+	hub := NewMemoryHub(DefaultOptions())
+	job, user := primitive.NewObjectID(), primitive.NewObjectID()
+	jobEvents, err := hub.Subscribe(context.Background(), JobTopic(job), "")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	userEvents, err := hub.Subscribe(context.Background(), UserTopic(user), "")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Act
+	ctx := context.Background()
+	hub.Publish(ctx, Event{Type: EventBidCreated, JobID: job}, JobTopic(job))
+	hub.Publish(ctx, Event{Type: EventOutbid, JobID: job}, UserTopic(user))
+
+	// Assert
+	if event := receive(t, jobEvents); event.Type != EventBidCreated || event.ID == "" || event.At.IsZero() {
+		t.Errorf("Expected the job's bid_created event, got %+v", event)
+	}
+	if event := receive(t, userEvents); event.Type != EventOutbid {
+		t.Errorf("Expected the user's outbid event, got %+v", event)
+	}
+	select {
+	case event := <-jobEvents:
+		t.Errorf("Expected no further job events, got %+v", event)
+	default:
+	}
+}
+
+func TestMemoryHubReplaysAfterLastEventID(t *testing.T) {
+	// Arrange
+	hub := NewMemoryHub(Options{History: 3, Buffer: 8})
+	ctx := context.Background()
+	topic := JobTopic(primitive.NewObjectID())
+	live, _ := hub.Subscribe(ctx, topic, "")
+	for i := 0; i < 4; i++ {
+		hub.Publish(ctx, Event{Type: EventBidCreated, BidAmount: structure.Money(i)}, topic)
+	}
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, receive(t, live).ID)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []structure.Money
+	}{
+		{"from a kept event", ids[1], []structure.Money{2, 3}},
+		{"from the last event", ids[3], nil},
+		{"from an event no longer kept", ids[0], []structure.Money{1, 2, 3}},
+		{"from another hub", "abc-2", []structure.Money{1, 2, 3}},
+		{"without an ID", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := hub.Subscribe(ctx, topic, tt.lastEventID)
+			if err != nil {
+				t.Fatalf("Failed to subscribe: %v", err)
+			}
+
+			// Assert
+			for _, amount := range tt.want {
+				if event := receive(t, events); event.BidAmount != amount {
+					t.Errorf("Expected the event with amount %v, got %+v", amount, event)
+				}
+			}
+			if len(events) != 0 {
+				t.Errorf("Expected %d events to be replayed, got %d more", len(tt.want), len(events))
+			}
+		})
+	}
+}
+
+func TestMemoryHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewMemoryHub(Options{History: 10, Buffer: 2})
+	ctx := context.Background()
+	topic := JobTopic(primitive.NewObjectID())
+	events, _ := hub.Subscribe(ctx, topic, "")
+
+	for i := 0; i < 3; i++ {
+		if err := hub.Publish(ctx, Event{Type: EventBidCreated}, topic); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+
+	receive(t, events)
+	receive(t, events)
+	if _, ok := <-events; ok {
+		t.Error("Expected the subscription of the slow subscriber to end")
+	}
+}
+
+func TestMemoryHubEndsSubscriptions(t *testing.T) {
+	hub := NewMemoryHub(DefaultOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, _ := hub.Subscribe(ctx, "job:a", "")
+	open, _ := hub.Subscribe(context.Background(), "job:a", "")
+
+	cancel()
+	if _, ok := <-cancelled; ok {
+		t.Error("Expected the subscription to end with its context")
+	}
+	hub.Close()
+	if _, ok := <-open; ok {
+		t.Error("Expected the subscription to end when the hub closes")
+	}
+	if err := hub.Publish(context.Background(), Event{}, "job:a"); err != ErrClosed {
+		t.Errorf("Expected ErrClosed publishing to a closed hub, got %v", err)
+	}
+}
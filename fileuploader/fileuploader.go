@@ -9,17 +9,40 @@ import (
 	"regexp"
 )
 
-// Define the maximum file size allowed (in bytes)
-const maxFileSize = 10 << 20 // 10 MB
-
-// Define the allowed file types
-var allowedFileTypes = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".pdf":  true,
-	".txt":  true,
-	// Add more file types as needed
+// Options configures the upload endpoint.
+type Options struct {
+	Dir              string   // directory the uploaded files are stored in
+	MaxFileSize      int64    // in bytes
+	AllowedFileTypes []string // file extensions including the dot, e.g. ".pdf"
+}
+
+// DefaultOptions returns the options used unless Configure is called.
+func DefaultOptions() Options {
+	return Options{
+		Dir:              "uploadedfiles",
+		MaxFileSize:      10 << 20, // 10 MB
+		AllowedFileTypes: []string{".jpg", ".jpeg", ".png", ".pdf", ".txt"},
+	}
+}
+
+var (
+	uploadDir        string
+	maxFileSize      int64
+	allowedFileTypes map[string]bool
+)
+
+func init() {
+	Configure(DefaultOptions())
+}
+
+// Configure replaces the upload options. Call it before serving requests.
+func Configure(opts Options) {
+	uploadDir = opts.Dir
+	maxFileSize = opts.MaxFileSize
+	allowedFileTypes = make(map[string]bool, len(opts.AllowedFileTypes))
+	for _, ext := range opts.AllowedFileTypes {
+		allowedFileTypes[ext] = true
+	}
 }
 
 func UploadFile(w http.ResponseWriter, r *http.Request) {
@@ -64,12 +87,9 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("newFilename", newFilename)
 
-	// Set the upload directory name
-	uploadDir := "uploadedfiles"
-
-	// Check if the directory exists, if not, create it
+	// Check if the upload directory exists, if not, create it
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		err := os.Mkdir(uploadDir, 0755)
+		err := os.MkdirAll(uploadDir, 0755)
 		if err != nil {
 			fmt.Println("Error creating directory:", err)
 			http.Error(w, "Error creating directory", http.StatusInternalServerError)
@@ -1,6 +1,6 @@
 module Go-sumon
 
-go 1.21.5
+go 1.22
 
 require go.mongodb.org/mongo-driver v1.14.0
 
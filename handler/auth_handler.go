@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"Go-sumon/auth"
+)
+
+// authService authenticates requests to protected routes.
+var authService *auth.Service
+
+// SetAuth sets the service used by the /auth routes and protected routes.
+func SetAuth(service *auth.Service) {
+	authService = service
+}
+
+// requireUser rejects requests without a valid access token. Protected routes
+// fail closed when no auth service is configured.
+func requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authService == nil {
+			http.Error(w, "Authentication is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		authService.RequireUser(next)(w, r)
+	}
+}
+
+// identifyUser serves requests to public routes as the user of their access
+// token, if any, so that responses can depend on who asks.
+func identifyUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authService == nil {
+			next(w, r)
+			return
+		}
+		authService.IdentifyUser(next)(w, r)
+	}
+}
+
+// RequestCodeHandler sends a login code to the phone number in the body. It
+// answers 202 whether or not the number is registered, so the endpoint
+// cannot be used to discover users.
+func RequestCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PhoneNumber string `json:"phoneNumber"`
+	}
+	if !decodeAuthBody(w, r, &body) {
+		return
+	}
+
+	err := authService.RequestCode(r.Context(), body.PhoneNumber)
+	switch {
+	case err == nil, errors.Is(err, auth.ErrUnknownPhoneNumber):
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("If the phone number is registered, a code has been sent"))
+	case errors.Is(err, auth.ErrRateLimited):
+		retryAfter := int(authService.Options().RateWindow.Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "Too many codes requested, try again later", http.StatusTooManyRequests)
+	default:
+		log.Printf("Failed to send login code: %v", err)
+		http.Error(w, "Failed to send code", databaseErrorStatus(err))
+	}
+}
+
+// VerifyCodeHandler exchanges a phone number and code for session tokens.
+func VerifyCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PhoneNumber string `json:"phoneNumber"`
+		Code        string `json:"code"`
+	}
+	if !decodeAuthBody(w, r, &body) {
+		return
+	}
+
+	tokens, err := authService.VerifyCode(r.Context(), body.PhoneNumber, body.Code)
+	writeTokens(w, tokens, err, auth.ErrInvalidCode)
+}
+
+// RefreshHandler exchanges a refresh token for a new token pair.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if !decodeAuthBody(w, r, &body) {
+		return
+	}
+
+	tokens, err := authService.Refresh(r.Context(), body.RefreshToken)
+	writeTokens(w, tokens, err, auth.ErrInvalidToken)
+}
+
+// LogoutHandler revokes the session of the request's access token.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	if err := authService.Revoke(r.Context(), claims.SessionID); err != nil {
+		log.Printf("Failed to revoke session: %v", err)
+		http.Error(w, "Failed to log out", databaseErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MeHandler returns the authenticated user.
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	responseBody, err := json.Marshal(user)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// authConfigured wraps the public /auth handlers, answering 503 when no auth
+// service is configured.
+func authConfigured(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authService == nil {
+			http.Error(w, "Authentication is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// decodeAuthBody decodes the JSON request body into body, responding 400 if
+// it is malformed.
+func decodeAuthBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeTokens writes issued tokens, or 401 if err is the expected rejection.
+func writeTokens(w http.ResponseWriter, tokens auth.Tokens, err error, rejected error) {
+	if errors.Is(err, rejected) {
+		http.Error(w, rejected.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		http.Error(w, "Failed to issue tokens", databaseErrorStatus(err))
+		return
+	}
+
+	responseBody, err := json.Marshal(tokens)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(responseBody)
+}
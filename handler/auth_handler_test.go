@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"Go-sumon/auth"
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+// codeSender keeps the last login code it was asked to send.
+type codeSender struct {
+	code string
+}
+
+func (s *codeSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	s.code = regexp.MustCompile(`\d{6}`).FindString(message)
+	return nil
+}
+
+// loginForTest configures an auth service, logs a new user of userType in
+// through the /auth routes and returns the user and tokens. The service is
+// removed when the test ends.
+func loginForTest(t *testing.T, mux *http.ServeMux, userType structure.UserType) (structure.User, auth.Tokens) {
+	t.Helper()
+	database.ClearCollection("user")
+	database.ClearCollection("otp")
+	database.ClearCollection("session")
+	user := structure.User{Name: "Karim", PhoneNumber: "01711111111", NID: "9876543210123", UserType: userType}
+	if err := database.Create("user", &user); err != nil {
+		t.Fatalf("Failed to insert user document: %v", err)
+	}
+	sender := &codeSender{}
+	SetAuth(auth.NewService(database.Default(), sender, []byte("test-secret-test-secret-test-secret"), auth.DefaultOptions()))
+	t.Cleanup(func() { SetAuth(nil) })
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/auth/otp", strings.NewReader(`{"phoneNumber":"01711111111"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("POST /auth/otp returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	body := `{"phoneNumber":"01711111111","code":"` + sender.code + `"}`
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/auth/verify", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST /auth/verify returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var tokens auth.Tokens
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return user, tokens
+}
+
+// authorized returns a request carrying the access token.
+func authorized(req *http.Request, tokens auth.Tokens) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	return req
+}
+
+func TestAuthMeAndLogout(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	_, tokens := loginForTest(t, mux, structure.UserTypeClient)
+
+	// Act & Assert: the token identifies the user
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/auth/me", nil), tokens))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Karim") {
+		t.Fatalf("GET /auth/me returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	// Act & Assert: after logout the token is rejected
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, authorized(httptest.NewRequest("POST", "/auth/logout", nil), tokens))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("POST /auth/logout returned %v: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/auth/me", nil), tokens))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %v after logout, got %v", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestAuthRequestCodeRateLimited(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	loginForTest(t, mux, structure.UserTypeClient)
+
+	// The login used one code; use up the rest of the limit
+	var rr *httptest.ResponseRecorder
+	for i := 0; i < auth.DefaultOptions().MaxCodes; i++ {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("POST", "/auth/otp", strings.NewReader(`{"phoneNumber":"01711111111"}`)))
+	}
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %v, got %v", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
+func TestProtectedRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	SetAuth(nil)
+
+	// Writes are rejected without a token, while reads and signup are open
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/bid/0123456789abcdef01234567", nil))
+	if rr.Code == http.StatusOK {
+		t.Errorf("Expected DELETE /bid/{id} to require authentication, got %v", rr.Code)
+	}
+
+	for _, route := range Routes() {
+		write := route.Method != http.MethodGet
+		signup := route.Method == http.MethodPost && (route.Path == "/user" || route.Path == "/client" || route.Path == "/serviceProvider")
+		public := route.Path == "/auth/otp" || route.Path == "/auth/verify" || route.Path == "/auth/refresh"
+		mine := route.Path == "/auth/me" || strings.HasSuffix(route.Path, "/mine") || route.Path == "/user/{id}/events" || strings.HasPrefix(route.Path, "/user/{id}/notification")
+		admin := strings.HasPrefix(route.Path, "/webhook")
+		money := strings.HasSuffix(route.Path, "/ledger") || strings.HasSuffix(route.Path, "/balance")
+		if want := (write && !signup && !public) || mine || admin || money; route.Protected != want {
+			t.Errorf("Expected %s protected=%v", route.pattern(), want)
+		}
+	}
+}
@@ -1,32 +1,131 @@
 package handler
 
 import (
-    "net/http"
-    "Go-sumon/structure"
+	"Go-sumon/auth"
+	"Go-sumon/database"
+	"Go-sumon/structure"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func GetAllBidHandler(w http.ResponseWriter, r *http.Request) {
-    var bids []structure.Bid
-    GenericGetAllHandler(w, r, "bid", &bids)
+	var bids []structure.Bid
+	GenericGetAllHandler(w, r, "bid", &bids)
 }
 
 func CreateBidHandler(w http.ResponseWriter, r *http.Request) {
-    var bid structure.Bid
-    GenericCreateHandler(w, r, "bid", &bid)
+	placeBid(w, r, "")
+}
+
+// placeBid places the bid in the request body on the job with ID jobID, or
+// on the job the body names when jobID is empty, and responds with the bid.
+func placeBid(w http.ResponseWriter, r *http.Request, jobID string) {
+	// Set Access-Control-Allow-Origin header to allow cross-origin requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var bid structure.Bid
+	if err := json.NewDecoder(r.Body).Decode(&bid); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	// The bid is made by the service provider sending it
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		bid.SPID = user.ID
+	}
+	if jobID == "" && !bid.JobID.IsZero() {
+		jobID = bid.JobID.Hex()
+	}
+
+	// Bids that name no job, which the route policy rejects, are stored as
+	// they are
+	var err error
+	if jobID == "" {
+		if err = bid.Validate(); err == nil {
+			err = db().Create(r.Context(), "bid", &bid)
+		}
+	} else {
+		err = db().PlaceBid(r.Context(), jobID, &bid)
+	}
+	if err != nil {
+		writeBiddingError(w, err)
+		return
+	}
+	if jobID != "" {
+		publishBidPlaced(r, bid)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bid)
+}
+
+// writeBiddingError responds to a bid that could not be placed, accepted or
+// rejected.
+func writeBiddingError(w http.ResponseWriter, err error) {
+	switch {
+	case writeValidationError(w, err):
+	case errors.Is(err, database.ErrNotFound):
+		http.Error(w, "No such job or bid", http.StatusNotFound)
+	case errors.Is(err, database.ErrJobNotOpen):
+		http.Error(w, "The job is not open for bids", http.StatusConflict)
+	case errors.Is(err, database.ErrBiddingClosed):
+		http.Error(w, "Bidding on the job has closed", http.StatusConflict)
+	case errors.Is(err, database.ErrBidNotForJob):
+		http.Error(w, "The bid is not for this job", http.StatusConflict)
+	default:
+		log.Printf("Failed to update bids: %v", err)
+		http.Error(w, "Failed to update bids", databaseErrorStatus(err))
+	}
+}
+
+// updateBid applies an update to a bid and to its job's copy of it. It
+// answers 409 Conflict if the bid was decided or bidding on its job closed
+// after the update's policy was checked.
+func updateBid(r *http.Request, id string, update bson.M) error {
+	err := db().UpdateBid(r.Context(), id, update)
+	switch {
+	case errors.Is(err, database.ErrBidDecided):
+		return &policyError{Status: http.StatusConflict, Code: "bid_decided", Message: "This bid has already been decided"}
+	case errors.Is(err, database.ErrJobNotOpen), errors.Is(err, database.ErrBiddingClosed):
+		return &policyError{Status: http.StatusConflict, Code: "bidding_closed", Message: "Bidding on this job has closed"}
+	}
+	return err
 }
 
 func GetBidHandler(w http.ResponseWriter, r *http.Request) {
-    GenericGetHandler(w, r, "bid")
+	GenericGetHandler(w, r, "bid")
 }
 
 func UpdateBidHandler(w http.ResponseWriter, r *http.Request) {
-    GenericUpdateHandler(w, r, "bid")
+	GenericUpdateHandler(w, r, "bid")
+}
+
+// deleteBid deletes a bid and its job's copy of it. It answers 409 Conflict
+// for the bid the job has accepted.
+func deleteBid(r *http.Request, id string) error {
+	err := db().DeleteBid(r.Context(), id)
+	if errors.Is(err, database.ErrBidDecided) {
+		return &policyError{Status: http.StatusConflict, Code: "bid_decided", Message: "The job has accepted this bid"}
+	}
+	return err
 }
 
 func DeleteBidHandler(w http.ResponseWriter, r *http.Request) {
-    GenericDeleteHandler(w, r, "bid")
+	GenericDeleteHandler(w, r, "bid")
 }
 
 func FindBidHandler(w http.ResponseWriter, r *http.Request) {
-    GenericFindHandler(w, r, "bid")
+	GenericFindHandler(w, r, "bid")
+}
+
+// MyBidsHandler lists the bids of the authenticated service provider.
+func MyBidsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	var bids []structure.Bid
+	listDocuments(w, r, "bid", bson.M{"serviceproviderid": user.ID}, &bids)
 }
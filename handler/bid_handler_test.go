@@ -3,8 +3,10 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -23,14 +25,14 @@ func TestGetAllBidHandler(t *testing.T) {
 		ID:          primitive.NewObjectID(),
 		Description: "Bid 1 description",
 		Time:        "Bid 1 time",
-		BidAmount:   100,
+		BidAmount:   structure.Taka(100),
 		PostedTime:  time.Now(),
 	}
 	bid2 := structure.Bid{
 		ID:          primitive.NewObjectID(),
 		Description: "Bid 2 description",
 		Time:        "Bid 2 time",
-		BidAmount:   150,
+		BidAmount:   structure.Taka(150),
 		PostedTime:  time.Now(),
 	}
 	if err := database.Create("bid", &bid1); err != nil {
@@ -82,7 +84,7 @@ func TestCreateBidHandler(t *testing.T) {
 		ID:          primitive.NewObjectID(),
 		Description: "Bid 1 description",
 		Time:        "Bid 1 time",
-		BidAmount:   100,
+		BidAmount:   structure.Taka(100),
 		PostedTime:  time.Now(),
 	}
 
@@ -137,7 +139,7 @@ func TestGetBidHandler(t *testing.T) {
 		ID:          primitive.NewObjectID(),
 		Description: "Great bid 1",
 		Time:        "10:00",
-		BidAmount:   100,
+		BidAmount:   structure.Taka(100),
 		PostedTime:  time.Now(),
 	}
 
@@ -145,7 +147,7 @@ func TestGetBidHandler(t *testing.T) {
 		ID:          primitive.NewObjectID(),
 		Description: "Great bid 2",
 		Time:        "11:00",
-		BidAmount:   150,
+		BidAmount:   structure.Taka(150),
 		PostedTime:  time.Now(),
 	}
 
@@ -195,7 +197,7 @@ func TestUpdateBidHandler(t *testing.T) {
 	expectedBid := structure.Bid{
 		Description: "Bid description", // <-- This needs to be "Updated description"
 		Time:        "2024-03-14T12:00:00Z",
-		BidAmount:   100,
+		BidAmount:   structure.Taka(100),
 		PostedTime:  time.Now(),
 	}
 	if err := database.Create("bid", &expectedBid); err != nil {
@@ -257,7 +259,7 @@ func TestDeleteBidHandler(t *testing.T) {
 	expectedBid := structure.Bid{
 		Description: "Test bid",
 		Time:        "2024-03-14T12:00:00Z",
-		BidAmount:   100,
+		BidAmount:   structure.Taka(100),
 		PostedTime:  time.Now(),
 	}
 	if err := database.Create("bid", &expectedBid); err != nil {
@@ -298,8 +300,8 @@ func TestFindBidHandler(t *testing.T) {
 
 	// Insert test bids into the database
 	testBids := []structure.Bid{
-		{Description: "First bid", Time: "2024-03-14T12:00:00Z", BidAmount: 100, PostedTime: time.Now()},
-		{Description: "Second bid", Time: "2024-03-14T13:00:00Z", BidAmount: 150, PostedTime: time.Now()},
+		{Description: "First bid", Time: "2024-03-14T12:00:00Z", BidAmount: structure.Taka(100), PostedTime: time.Now()},
+		{Description: "Second bid", Time: "2024-03-14T13:00:00Z", BidAmount: structure.Taka(150), PostedTime: time.Now()},
 	}
 
 	for _, bid := range testBids {
@@ -309,7 +311,7 @@ func TestFindBidHandler(t *testing.T) {
 	}
 
 	// Create a mock HTTP request with query parameters
-	req, err := http.NewRequest("GET", "/find?collection=bid&filter={\"Description\":\"Second bid\"}", nil)
+	req, err := http.NewRequest("GET", "/find?collection=bid&filter={\"description\":\"Second bid\"}", nil)
 	if err != nil {
 		t.Fatal("Failed to create request:", err)
 	}
@@ -345,3 +347,91 @@ func TestFindBidHandler(t *testing.T) {
 		t.Errorf("Expected bid with description '%s' not found in retrieved bids", expectedDescription)
 	}
 }
+
+func TestGetAllBidHandlerPagination(t *testing.T) {
+	// Clear the "bid" collection before running the test
+	database.ClearCollection("bid")
+
+	// Insert five bids with distinct amounts
+	for i := 1; i <= 5; i++ {
+		bid := structure.Bid{Description: fmt.Sprintf("Bid %d", i), BidAmount: structure.Taka(int64(i * 100))}
+		if err := database.Create("bid", &bid); err != nil {
+			t.Fatalf("Failed to insert test bid document %d: %v", i, err)
+		}
+	}
+
+	// Walk the pages from the highest amount down, two bids at a time
+	var amounts []structure.Money
+	after := ""
+	for page := 0; page < 3; page++ {
+		req := httptest.NewRequest("GET", "/bid?limit=2&sort=-bidamount&total=true&after="+after, nil)
+		rr := httptest.NewRecorder()
+		GetAllBidHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		var response struct {
+			Items      []structure.Bid `json:"items"`
+			NextCursor string          `json:"nextCursor"`
+			Total      int64           `json:"total"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if response.Total != 5 {
+			t.Errorf("unexpected total: got %d, want 5", response.Total)
+		}
+		for _, bid := range response.Items {
+			amounts = append(amounts, bid.BidAmount)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		after = response.NextCursor
+	}
+
+	want := []structure.Money{structure.Taka(500), structure.Taka(400), structure.Taka(300), structure.Taka(200), structure.Taka(100)}
+	if !reflect.DeepEqual(amounts, want) {
+		t.Errorf("unexpected bid amounts across pages: got %v, want %v", amounts, want)
+	}
+}
+
+func TestGetAllBidHandlerFields(t *testing.T) {
+	// Clear the "bid" collection before running the test
+	database.ClearCollection("bid")
+
+	bid := structure.Bid{Description: "Projected bid", Time: "2 hours", BidAmount: structure.Taka(100)}
+	if err := database.Create("bid", &bid); err != nil {
+		t.Fatalf("Failed to insert test bid document: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/bid?fields=description", nil)
+	rr := httptest.NewRecorder()
+	GetAllBidHandler(rr, req)
+
+	var docs []map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&docs); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("unexpected number of bids retrieved: got %d, want 1", len(docs))
+	}
+	if docs[0]["description"] != "Projected bid" {
+		t.Errorf("unexpected description: got %v", docs[0]["description"])
+	}
+	if _, ok := docs[0]["bidamount"]; ok {
+		t.Error("expected bidamount to be excluded by the projection")
+	}
+}
+
+func TestGetAllBidHandlerInvalidCursor(t *testing.T) {
+	req := httptest.NewRequest("GET", "/bid?limit=2&after=not-a-cursor", nil)
+	rr := httptest.NewRecorder()
+	GetAllBidHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -11,7 +11,7 @@ func GetAllClientHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func CreateClientHandler(w http.ResponseWriter, r *http.Request) {
-    ClientCreateHandler(w, r, "user", "client")
+    ClientCreateHandler(w, r)
 }
 
 func GetClientHandler(w http.ResponseWriter, r *http.Request) {
@@ -13,52 +13,110 @@ import (
 
 func TestCreateClientHandler(t *testing.T) {
 
-    // Clear the user and client collections before running the test
-    database.ClearCollection("user")
-    database.ClearCollection("client")
-
-    // Create a sample client payload
-    client := structure.Client{
-        User: structure.User{
-            // Manually setting other fields
-            Name:        "Client 1",
-            PhoneNumber: "01711377006",
-            NID:         "198426662698745",
-            Birthdate:   "05-06-1984",
-            FatherName:  "Father 1",
-            MotherName:  "Mother 1",
-            UserType:    "client",
-        },
-        Location: "New York",
-    }
-    clientJSON, err := json.Marshal(client)
-    if err != nil {
-        t.Fatal(err)
-    }
-
-    // Create a request with the sample payload
-    req, err := http.NewRequest("POST", "/create-client", bytes.NewBuffer(clientJSON))
-    if err != nil {
-        t.Fatal(err)
-    }
-    req.Header.Set("Content-Type", "application/json")
-
-    // Create a ResponseRecorder to record the response
-    rr := httptest.NewRecorder()
-
-    // Call the handler function
-    CreateClientHandler(rr, req)
-
-    // Check the status code
-    if status := rr.Code; status != http.StatusOK {
-        t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
-    }
-
-    // Check the response body
-    expected := `{"message":"Document created successfully"}`
-    if rr.Body.String() != expected {
-        t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-    }
-
-    
-}
\ No newline at end of file
+	// Clear the user and client collections before running the test
+	database.ClearCollection("user")
+	database.ClearCollection("client")
+
+	// Create a sample client payload
+	client := structure.Client{
+		User: structure.User{
+			// Manually setting other fields
+			Name:        "Client 1",
+			PhoneNumber: "01711377006",
+			NID:         "1984266626987",
+			Birthdate:   "05-06-1984",
+			FatherName:  "Father 1",
+			MotherName:  "Mother 1",
+			UserType:    "client",
+		},
+		Location: "New York",
+	}
+	clientJSON, err := json.Marshal(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a request with the sample payload
+	req, err := http.NewRequest("POST", "/create-client", bytes.NewBuffer(clientJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	CreateClientHandler(rr, req)
+
+	// Check the status code
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// Check the response body
+	expected := `{"message":"Document created successfully"}`
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+}
+func TestCreateClientHandlerWithTakenPhoneNumber(t *testing.T) {
+	// Arrange
+	database.ClearCollection("user")
+	database.ClearCollection("client")
+	client := structure.Client{
+		User:     structure.User{Name: "Client 1", PhoneNumber: "01711377006", UserType: "client"},
+		Location: "Dhaka",
+	}
+	clientJSON, err := json.Marshal(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CreateClientHandler(httptest.NewRecorder(), httptest.NewRequest("POST", "/create-client", bytes.NewReader(clientJSON)))
+
+	// Act
+	rr := httptest.NewRecorder()
+	CreateClientHandler(rr, httptest.NewRequest("POST", "/create-client", bytes.NewReader(clientJSON)))
+
+	// Assert
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %v registering a taken phone number, got %v: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func TestUpdateClientHandlerUpdatesUserAndClient(t *testing.T) {
+	// Arrange
+	database.ClearCollection("user")
+	database.ClearCollection("client")
+	client := structure.Client{
+		User:     structure.User{Name: "Client 1", PhoneNumber: "01711377006", UserType: "client"},
+		Location: "Dhaka",
+	}
+	if err := database.ClientCreate(&client); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Act
+	body := bytes.NewReader([]byte(`{"user.name":"Renamed","location":"Khulna"}`))
+	rr := serveAs(&client.User, httptest.NewRequest("PATCH", "/client/"+client.ID.Hex(), body))
+
+	// Assert
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v updating the client, got %v: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var user structure.User
+	if err := database.Get("user", &user, client.ID.Hex()); err != nil {
+		t.Fatalf("Failed to retrieve user document: %v", err)
+	}
+	if user.Name != "Renamed" {
+		t.Errorf("Expected the user to be renamed, got %q", user.Name)
+	}
+	var updated structure.Client
+	if err := database.Get("client", &updated, client.ID.Hex()); err != nil {
+		t.Fatalf("Failed to retrieve client document: %v", err)
+	}
+	if updated.User.Name != "Renamed" || updated.Location != "Khulna" {
+		t.Errorf("Expected the client to be renamed and moved to Khulna, got %+v", updated)
+	}
+}
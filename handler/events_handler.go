@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"Go-sumon/feed"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// eventHub receives the events of the handlers and serves the event streams.
+var eventHub feed.Hub
+
+// heartbeatInterval is the time between comments sent on idle event streams
+// to keep proxies from closing them.
+var heartbeatInterval = 15 * time.Second
+
+// SetFeed sets the hub of the event streams and how often idle streams are
+// sent a heartbeat.
+func SetFeed(hub feed.Hub, heartbeat time.Duration) {
+	eventHub = hub
+	heartbeatInterval = heartbeat
+}
+
+// JobEventsHandler streams the events about a job as Server-Sent Events.
+func JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var job structure.Job
+	if err := getTarget(r, "job", jobID.Hex(), &job); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	streamEvents(w, r, feed.JobTopic(job.ID))
+}
+
+// UserEventsHandler streams the events for the authenticated user as
+// Server-Sent Events.
+func UserEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	streamEvents(w, r, feed.UserTopic(userID))
+}
+
+// streamEvents writes the events of topic until the client goes away or the
+// hub ends the subscription. A client that reconnects with the Last-Event-ID
+// header, or the lastEventId query parameter, is first sent what it missed.
+func streamEvents(w http.ResponseWriter, r *http.Request, topic string) {
+	if eventHub == nil {
+		http.Error(w, "Event feed is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	events, err := eventHub.Subscribe(r.Context(), topic, lastEventID)
+	if err != nil {
+		http.Error(w, "Failed to subscribe to events", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Failed to clear write deadline of event stream: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode event %s: %v", event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// publish sends event to the topics, logging rather than failing the request
+// if the hub cannot take it.
+func publish(r *http.Request, event feed.Event, topics ...string) {
+	if eventHub == nil {
+		return
+	}
+	if err := eventHub.Publish(r.Context(), event, topics...); err != nil {
+		log.Printf("Failed to publish %s event for job %s: %v", event.Type, event.JobID.Hex(), err)
+	}
+}
+
+// publishBidPlaced announces a bid on a job to the job's followers and
+// client, and tells the bidder whose bid was the lowest that they have been
+// outbid. Bids on sealed jobs are announced without their amount, and nobody
+// is told they were outbid.
+func publishBidPlaced(r *http.Request, bid structure.Bid) {
+	if eventHub == nil {
+		return
+	}
+	var job structure.Job
+	if err := db().Get(r.Context(), "job", bid.JobID.Hex(), &job); err != nil {
+		log.Printf("Failed to get job %s to publish its bid: %v", bid.JobID.Hex(), err)
+		return
+	}
+	sealed := job.AuctionMode == structure.AuctionModeSealed
+	event := feed.Event{Type: feed.EventBidCreated, JobID: job.ID, BidID: bid.ID, ServiceProviderID: bid.SPID, At: bid.PostedTime}
+	if !sealed {
+		event.BidAmount = bid.BidAmount
+	}
+	publish(r, event, feed.JobTopic(job.ID), feed.UserTopic(job.ClientID))
+	if sealed {
+		return
+	}
+
+	var bids []structure.Bid
+	filter := bson.M{"jobid": job.ID, "status": structure.StatusPending, "_id": bson.M{"$ne": bid.ID}}
+	if err := db().Find(r.Context(), "bid", filter, &bids); err != nil {
+		log.Printf("Failed to find bids on job %s: %v", job.ID.Hex(), err)
+		return
+	}
+	var lowest *structure.Bid
+	for i := range bids {
+		if lowest == nil || bids[i].BidAmount < lowest.BidAmount {
+			lowest = &bids[i]
+		}
+	}
+	if lowest != nil && lowest.SPID != bid.SPID && bid.BidAmount < lowest.BidAmount {
+		outbid := feed.Event{Type: feed.EventOutbid, JobID: job.ID, BidID: bid.ID, ServiceProviderID: bid.SPID, BidAmount: bid.BidAmount, At: bid.PostedTime}
+		publish(r, outbid, feed.UserTopic(lowest.SPID))
+	}
+}
+
+// publishJobMoved announces the last change of the job's status to the
+// job's followers, its client and its service provider.
+func publishJobMoved(r *http.Request, job structure.Job) {
+	if eventHub == nil || len(job.History) == 0 {
+		return
+	}
+	moved := job.History[len(job.History)-1]
+	topics := []string{feed.JobTopic(job.ID), feed.UserTopic(job.ClientID)}
+	if !job.ServiceProviderID.IsZero() {
+		topics = append(topics, feed.UserTopic(job.ServiceProviderID))
+	}
+	if moved.To == structure.JobStatusBidAccepted {
+		accepted := feed.Event{Type: feed.EventBidAccepted, JobID: job.ID, BidID: job.AcceptedBid, ServiceProviderID: job.ServiceProviderID, At: moved.At}
+		publish(r, accepted, topics...)
+	}
+	publish(r, feed.Event{Type: feed.EventJobStatusChanged, JobID: job.ID, From: moved.From, To: moved.To, At: moved.At}, topics...)
+}
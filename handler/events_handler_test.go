@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Go-sumon/feed"
+	"Go-sumon/structure"
+)
+
+// sseEvent is an event read from a Server-Sent Events stream.
+type sseEvent struct {
+	id    string
+	event feed.Event
+}
+
+// openStream requests the event stream at path from server, resuming after
+// lastEventID, and returns its events as they arrive.
+func openStream(t *testing.T, server *httptest.Server, path string, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s returned %v %s", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		var current sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				current.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.event)
+			case line == "" && current.id != "":
+				events <- current
+				current = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event of a stream, failing if none arrives.
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event on the stream, got none")
+	}
+	return sseEvent{}
+}
+
+func TestJobEventStream(t *testing.T) {
+	// Arrange: a job's stream and the first bidder's own feed
+	f := newPolicyFixtures(t)
+	hub := feed.NewMemoryHub(feed.DefaultOptions())
+	SetFeed(hub, time.Hour)
+	t.Cleanup(func() { SetFeed(nil, 15*time.Second) })
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer hub.Close()
+
+	jobPath := "/job/" + f.openJob.ID.Hex()
+	stream := openStream(t, server, jobPath+"/events", "")
+	outbid, err := hub.Subscribe(context.Background(), feed.UserTopic(f.provider.ID), "")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Act: two bids, the second lower, and the client accepts it
+	placeBid := func(user *structure.User, body string) structure.Bid {
+		rr := serveAs(user, httptest.NewRequest("POST", jobPath+"/bids", strings.NewReader(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Placing the bid returned %v: %s", rr.Code, rr.Body.String())
+		}
+		var bid structure.Bid
+		json.NewDecoder(rr.Body).Decode(&bid)
+		return bid
+	}
+	placeBid(f.provider, `{"description":"Hire me","bidAmount":300}`)
+	lower := placeBid(f.otherProvider, `{"description":"Cheaper","bidAmount":200}`)
+	rr := serveAs(f.owner, httptest.NewRequest("POST", jobPath+"/bids/"+lower.ID.Hex()+"/accept", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Accepting the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	// Assert
+	var received []sseEvent
+	for _, want := range []feed.EventType{feed.EventBidCreated, feed.EventBidCreated, feed.EventBidAccepted, feed.EventJobStatusChanged} {
+		event := nextEvent(t, stream)
+		if event.event.Type != want || event.id != event.event.ID {
+			t.Errorf("Expected a %s event, got %+v", want, event)
+		}
+		received = append(received, event)
+	}
+	if received[1].event.BidAmount != structure.Taka(200) || received[3].event.To != structure.JobStatusBidAccepted {
+		t.Errorf("Expected the lower bid and the acceptance, got %+v", received)
+	}
+	select {
+	case event := <-outbid:
+		if event.Type != feed.EventOutbid || event.BidAmount != structure.Taka(200) {
+			t.Errorf("Expected the first bidder to be outbid, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the first bidder to be told they were outbid")
+	}
+
+	// A client reconnecting after the second bid is sent what it missed
+	resumed := openStream(t, server, jobPath+"/events", received[1].id)
+	for _, want := range received[2:] {
+		if event := nextEvent(t, resumed); event.id != want.id {
+			t.Errorf("Expected event %s to be replayed, got %+v", want.id, event)
+		}
+	}
+}
+
+func TestJobEventStreamMissingJob(t *testing.T) {
+	newPolicyFixtures(t)
+	SetFeed(feed.NewMemoryHub(feed.DefaultOptions()), time.Hour)
+	t.Cleanup(func() { SetFeed(nil, 15*time.Second) })
+
+	rr := serveAs(nil, httptest.NewRequest("GET", "/job/0123456789abcdef01234567/events", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for a missing job, got %v", http.StatusNotFound, rr.Code)
+	}
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -9,6 +11,8 @@ import (
 
 	"Go-sumon/database"
 	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func UserCreateHandler(w http.ResponseWriter, r *http.Request, collectionName string) {
@@ -30,12 +34,15 @@ func UserCreateHandler(w http.ResponseWriter, r *http.Request, collectionName st
 	}
 
 	// Call the appropriate create function to create the document
-	err = database.UserCreate(collectionName, document)
+	err = db().UserCreate(r.Context(), collectionName, &document)
 	if err != nil {
-		if strings.Contains(err.Error(), "phone number already exists") {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, database.ErrPhoneNumberTaken) {
 			http.Error(w, "Phone number already exists", http.StatusConflict)
 		} else {
-			http.Error(w, fmt.Sprintf("Failed to create document: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to create document: %v", err), databaseErrorStatus(err))
 		}
 		return
 	}
@@ -50,79 +57,128 @@ func UserCreateHandler(w http.ResponseWriter, r *http.Request, collectionName st
 	json.NewEncoder(w).Encode(map[string]string{"message": "Document created successfully"})
 }
 
+// ClientCreateHandler creates a client's user and client documents together,
+// so a failed client insert never leaves an orphaned user behind.
+func ClientCreateHandler(w http.ResponseWriter, r *http.Request) {
+	// Set content type header
+	w.Header().Set("Content-Type", "application/json")
 
-func ClientCreateHandler(w http.ResponseWriter, r *http.Request, userCollectionName string, clientCollectionName string) {
-    // Set content type header
-    w.Header().Set("Content-Type", "application/json")
-
-    // Parse the request body into a structure.Client object
-    var client structure.Client
-    err := json.NewDecoder(r.Body).Decode(&client)
-    if err != nil {
-        http.Error(w, "Failed to decode request body", http.StatusBadRequest)
-        return
-    }
-
-    // Call the UserCreate function to create the user document
-    err = database.UserCreate(userCollectionName, &client.User)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-
-    // If user type is "client", insert the user document into the client collection
-    if client.User.UserType == "client" {
-        // Call the Create function passing the collection and document
-        err = database.Create(clientCollectionName, &client)
-        if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            return
-        }
-    }
-
-    // Encode the response
-    response := map[string]string{"message": "Document created successfully"}
-    err = json.NewEncoder(w).Encode(response)
-    if err != nil {
-        http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-        return
-    }
+	// Parse the request body into a structure.Client object
+	var client structure.Client
+	err := json.NewDecoder(r.Body).Decode(&client)
+	if err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	// Create the user and client documents atomically
+	err = db().ClientCreate(r.Context(), &client)
+	if err != nil {
+		writeCreateUserError(w, err)
+		return
+	}
+
+	writeCreatedMessage(w)
 }
 
-func SPCreateHandler(w http.ResponseWriter, r *http.Request, userCollectionName string, SPCollectionName string) {
-    // Set content type header
-    w.Header().Set("Content-Type", "application/json")
-
-    // Parse the request body into a structure.Client object
-    var serviceProvider structure.ServiceProvider
-    err := json.NewDecoder(r.Body).Decode(&serviceProvider)
-    if err != nil {
-        http.Error(w, "Failed to decode request body", http.StatusBadRequest)
-        return
-    }
-
-    // Call the UserCreate function to create the user document
-    err = database.UserCreate(userCollectionName, &serviceProvider.User)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-
-    // If user type is "client", insert the user document into the client collection
-    if serviceProvider.User.UserType == "serviceProvider" {
-        // Call the Create function passing the collection and document
-        err = database.Create(SPCollectionName, &serviceProvider)
-        if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            return
-        }
-    }
-
-    // Encode the response
-    response := map[string]string{"message": "Document created successfully"}
-    err = json.NewEncoder(w).Encode(response)
-    if err != nil {
-        http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-        return
-    }
-}
\ No newline at end of file
+// SPCreateHandler creates a service provider's user and service provider
+// documents together, so a failed insert never leaves an orphaned user behind.
+func SPCreateHandler(w http.ResponseWriter, r *http.Request, spCollectionName string) {
+	// Set content type header
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the request body into a structure.ServiceProvider object
+	var serviceProvider structure.ServiceProvider
+	err := json.NewDecoder(r.Body).Decode(&serviceProvider)
+	if err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	// The escrow ledger owns the balance
+	serviceProvider.SPBalance = structure.Balance{}
+
+	// Create the user and service provider documents atomically
+	err = db().SPCreate(r.Context(), spCollectionName, &serviceProvider)
+	if err != nil {
+		writeCreateUserError(w, err)
+		return
+	}
+
+	writeCreatedMessage(w)
+}
+
+// writeCreateUserError reports a failed user registration, using 422 for
+// invalid fields and 409 for a phone number that is already registered.
+func writeCreateUserError(w http.ResponseWriter, err error) {
+	if writeValidationError(w, err) {
+		return
+	}
+	if errors.Is(err, database.ErrPhoneNumberTaken) {
+		http.Error(w, "Phone number already exists", http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), databaseErrorStatus(err))
+}
+
+// writeCreatedMessage writes the success body shared by the registration handlers.
+func writeCreatedMessage(w http.ResponseWriter) {
+	responseBody, err := json.Marshal(map[string]string{"message": "Document created successfully"})
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Write(responseBody)
+}
+
+// updateClient applies an update to a client, writing the fields of its user
+// to the user document and the client's copy of it together.
+func updateClient(r *http.Request, id string, update bson.M) error {
+	return updateUserAndRole(r, "client", id, update, db().ClientUpdate)
+}
+
+// updateSP applies an update to a service provider, writing the fields of its
+// user to the user document and the service provider's copy of it together.
+func updateSP(r *http.Request, id string, update bson.M) error {
+	return updateUserAndRole(r, "serviceProvider", id, update, db().SPUpdate)
+}
+
+// updateUserAndRole splits an update to a document of roleCollection into the
+// fields of the embedded user, which updateUser writes, and the fields of the
+// role document itself, which are written after them.
+func updateUserAndRole(r *http.Request, roleCollection string, id string, update bson.M,
+	updateUser func(ctx context.Context, userID string, userData bson.M) error) error {
+	userData, roleData := splitUserUpdate(update)
+	if len(userData) > 0 {
+		if err := updateUser(r.Context(), id, userData); err != nil {
+			return err
+		}
+	}
+	if len(roleData) > 0 {
+		return db().Update(r.Context(), roleCollection, id, roleData)
+	}
+	return nil
+}
+
+// splitUserUpdate separates the fields an update sets on the user embedded in
+// a client or service provider, under "user" or "user.<key>", from the rest.
+func splitUserUpdate(update bson.M) (userData bson.M, roleData bson.M) {
+	userData, roleData = bson.M{}, bson.M{}
+	for key, value := range update {
+		switch {
+		case key == "user":
+			user, ok := value.(map[string]interface{})
+			if !ok {
+				user, _ = value.(bson.M)
+			}
+			for field, fieldValue := range user {
+				userData[field] = fieldValue
+			}
+		case strings.HasPrefix(key, "user."):
+			userData[strings.TrimPrefix(key, "user.")] = value
+		default:
+			roleData[key] = value
+		}
+	}
+	return userData, roleData
+}
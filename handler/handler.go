@@ -2,17 +2,46 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"net/http"
 
 	"Go-sumon/database"
+	"Go-sumon/structure"
 
 	"go.mongodb.org/mongo-driver/bson"
-	
 )
 
+// store is the database backend used by the handlers.
+var store database.Database
+
+// SetDatabase sets the database backend used by the handlers.
+func SetDatabase(backend database.Database) {
+	store = backend
+}
+
+// db returns the configured database backend, falling back to database.Default.
+func db() database.Database {
+	if store != nil {
+		return store
+	}
+	return database.Default()
+}
+
+// databaseErrorStatus maps a database error to an HTTP status code, reporting
+// exceeded deadlines as 504 Gateway Timeout.
+func databaseErrorStatus(err error) int {
+	if database.IsTimeout(err) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
 func GenericGetAllHandler(w http.ResponseWriter, r *http.Request, collectionName string, result interface{}) {
 	// Set Access-Control-Allow-Origin header to allow cross-origin requests
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -23,22 +52,57 @@ func GenericGetAllHandler(w http.ResponseWriter, r *http.Request, collectionName
 		return
 	}
 
-	// Call the GetAll function to retrieve all items from the specified collection in the database
-	err := database.GetAll(collectionName, result)
+	listDocuments(w, r, collectionName, bson.D{}, result)
+}
+
+// listDocuments responds with the documents of the collection matching
+// filter, honouring the paging, sorting and projection query parameters.
+func listDocuments(w http.ResponseWriter, r *http.Request, collectionName string, filter interface{}, result interface{}) {
+	// Parse the paging, sorting and projection parameters
+	opts, paginated, err := parseFindOptions(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Set the status code to 200 (OK) before writing the response body
-	w.WriteHeader(http.StatusOK)
+	// A projection returns partial documents, so decode into plain maps
+	if len(opts.Fields) > 0 {
+		result = &[]bson.M{}
+	}
+
+	// Retrieve the requested items from the specified collection in the database
+	filter, err = sealFilter(r, collectionName, filter, opts)
+	if err != nil {
+		http.Error(w, "Failed to retrieve items", databaseErrorStatus(err))
+		return
+	}
+	page, err := db().FindPage(r.Context(), collectionName, filter, opts, result)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to retrieve items", databaseErrorStatus(err))
+		return
+	}
+	if err := sealResult(r, collectionName, result); err != nil {
+		http.Error(w, "Failed to retrieve items", databaseErrorStatus(err))
+		return
+	}
+	hideFields(collectionName, result)
 
 	// Respond with the retrieved items
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeFindResponse(w, result, page, opts, paginated)
 }
 
 func GenericCreateHandler(w http.ResponseWriter, r *http.Request, collectionName string, document interface{}) {
+	createDocument(w, r, collectionName, document, nil)
+}
+
+// createDocument is GenericCreateHandler with a prepare hook, called after
+// the body is decoded, that fills in fields the server owns, such as who
+// created the document.
+func createDocument(w http.ResponseWriter, r *http.Request, collectionName string, document interface{}, prepare func(r *http.Request) error) {
 	// Set Access-Control-Allow-Origin header to allow cross-origin requests
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
@@ -54,11 +118,29 @@ func GenericCreateHandler(w http.ResponseWriter, r *http.Request, collectionName
 		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
 		return
 	}
+	if prepare != nil {
+		if err := prepare(r); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create document in collection %s: %v", collectionName, err), databaseErrorStatus(err))
+			return
+		}
+	}
+
+	// Reject documents with invalid fields
+	if validator, ok := document.(structure.Validator); ok {
+		if err := validator.Validate(); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+	}
 
 	// Call the provided Create function to insert the document into the specified collection
-	err = database.Create(collectionName, document)
+	if create, ok := createWriters[collectionName]; ok {
+		err = create(r, document)
+	} else {
+		err = db().Create(r.Context(), collectionName, document)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create document in collection %s: %v", collectionName, err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to create document in collection %s: %v", collectionName, err), databaseErrorStatus(err))
 		return
 	}
 
@@ -71,43 +153,118 @@ func GenericCreateHandler(w http.ResponseWriter, r *http.Request, collectionName
 }
 
 func GenericGetHandler(w http.ResponseWriter, r *http.Request, collectionName string) {
-    // Set Access-Control-Allow-Origin header to allow cross-origin requests
-    w.Header().Set("Access-Control-Allow-Origin", "*")
-
-    // Check if the request method is GET
-    if r.Method != http.MethodGet {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-        return
-    }
-
-    // Parse request parameters
-    params := r.URL.Query()
-    id := params.Get("id")
-    if id == "" {
-        http.Error(w, "Missing ID parameter", http.StatusBadRequest)
-        return
-    }
-
-    // Call the Get function to retrieve the document
-    var result interface{}
-    err := database.Get(collectionName, &result, id)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Failed to get document: %v", err), http.StatusInternalServerError)
-        return
-    }
-
-    // Set the status code to 200 (OK) before writing the response body
-    w.WriteHeader(http.StatusOK)
-
-    // Respond with the retrieved document
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(result)
+	// Set Access-Control-Allow-Origin header to allow cross-origin requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// Check if the request method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read the document ID from the path
+	id := requestID(w, r)
+	if id == "" {
+		http.Error(w, "Missing ID parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Call the Get function to retrieve the document
+	var result interface{}
+	err := db().Get(r.Context(), collectionName, id, &result)
+	if err == nil {
+		err = sealResult(r, collectionName, &result)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get document: %v", err), databaseErrorStatus(err))
+		return
+	}
+	hideFields(collectionName, &result)
+
+	// Set the status code to 200 (OK) before writing the response body
+	w.WriteHeader(http.StatusOK)
+
+	// Respond with the retrieved document
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// hiddenFields are the fields of a collection that are written but never
+// returned, such as the secrets of webhooks.
+var hiddenFields = map[string][]string{
+	"webhook": {"secret"},
+}
+
+// hideFields removes the hidden fields of the collection from the documents
+// in result, which holds them as bson.M or bson.D. Typed documents leave
+// them out when they are encoded.
+func hideFields(collectionName string, result interface{}) {
+	hidden := hiddenFields[collectionName]
+	if len(hidden) == 0 {
+		return
+	}
+	eachDocument(result, func(doc interface{}) interface{} {
+		for _, key := range hidden {
+			switch d := doc.(type) {
+			case bson.M:
+				delete(d, key)
+			case bson.D:
+				kept := d[:0]
+				for _, e := range d {
+					if e.Key != key {
+						kept = append(kept, e)
+					}
+				}
+				doc = kept
+			}
+		}
+		return doc
+	})
+}
+
+// updateHooks prepare the updates of a collection before GenericUpdateHandler
+// validates and applies them.
+var updateHooks = map[string]func(r *http.Request, id string, updateData bson.M) error{
+	"bid":     prepareMoney("bidamount", "bidAmount"),
+	"job":     prepareJobUpdate,
+	"payment": prepareMoney("balance", "balance"),
+	"webhook": prepareWebhookUpdate,
+}
+
+// createWriters and updateWriters write the documents of a collection in
+// place of Database.Create and Database.Update, to record the domain events
+// of the change, or update the documents that copy it, in the same write.
+var createWriters = map[string]func(r *http.Request, document interface{}) error{
+	"review": createReview,
+}
+
+var updateWriters = map[string]func(r *http.Request, id string, updateData bson.M) error{
+	"bid":             updateBid,
+	"client":          updateClient,
+	"job":             updateJob,
+	"serviceProvider": updateSP,
+}
+
+// deleteWriters delete the documents of a collection in place of
+// Database.Delete, to remove the copies other documents hold in the same
+// write.
+var deleteWriters = map[string]func(r *http.Request, id string) error{
+	"bid": deleteBid,
+}
+
+// updatedHooks run after GenericUpdateHandler has applied an update, to
+// announce the changes it made.
+var updatedHooks = map[string]func(r *http.Request, id string, updateData bson.M){
+	"job": jobUpdated,
 }
 
 func GenericUpdateHandler(w http.ResponseWriter, r *http.Request, collectionName string) {
-	// Parse request parameters
-	params := r.URL.Query()
-	id := params.Get("id")
+	// Read the document ID from the path
+	id := requestID(w, r)
+	if id == "" {
+		http.Error(w, "Missing ID parameter", http.StatusBadRequest)
+		return
+	}
 
 	// Decode request body
 	var updateData bson.M
@@ -117,12 +274,46 @@ func GenericUpdateHandler(w http.ResponseWriter, r *http.Request, collectionName
 		return
 	}
 
+	// Check and record the changes the collection tracks, such as a job's
+	// status
+	if prepare, ok := updateHooks[collectionName]; ok {
+		if err := prepare(r, id, updateData); err != nil {
+			writePolicyError(w, err)
+			return
+		}
+	}
+
+	// Reject updates that would leave the document with invalid fields
+	err = validateUpdate(r.Context(), collectionName, id, updateData)
+	if errors.Is(err, errInvalidUpdate) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		if !writeValidationError(w, err) {
+			http.Error(w, fmt.Sprintf("Failed to update document: %v", err), databaseErrorStatus(err))
+		}
+		return
+	}
+
 	// Update document in the database
-	err = database.Update(collectionName, id, updateData)
+	if update, ok := updateWriters[collectionName]; ok {
+		err = update(r, id, updateData)
+	} else {
+		err = db().Update(r.Context(), collectionName, id, updateData)
+	}
+	var denied *policyError
+	if errors.As(err, &denied) {
+		writePolicyError(w, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update document: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to update document: %v", err), databaseErrorStatus(err))
 		return
 	}
+	if updated, ok := updatedHooks[collectionName]; ok {
+		updated(r, id, updateData)
+	}
 
 	// Write success response
 	w.WriteHeader(http.StatusOK)
@@ -138,8 +329,8 @@ func GenericDeleteHandler(w http.ResponseWriter, r *http.Request, collectionName
 		return
 	}
 
-	// Extract the ID from the URL query parameters
-	id := r.URL.Query().Get("id")
+	// Read the document ID from the path
+	id := requestID(w, r)
 	if id == "" {
 		http.Error(w, "ID not provided", http.StatusBadRequest)
 		return
@@ -149,14 +340,24 @@ func GenericDeleteHandler(w http.ResponseWriter, r *http.Request, collectionName
 	fmt.Printf("Attempting to delete document with ID: %s from collection: %s\n", id, collectionName)
 
 	// Call the delete function to delete the document from the specified collection
-	err := database.Delete(collectionName, id)
+	var err error
+	if remove, ok := deleteWriters[collectionName]; ok {
+		err = remove(r, id)
+	} else {
+		err = db().Delete(r.Context(), collectionName, id)
+	}
+	var denied *policyError
+	if errors.As(err, &denied) {
+		writePolicyError(w, err)
+		return
+	}
 	if err != nil {
 		log.Printf("Error deleting document: %v\n", err) // Log the error
 
 		// Print the ID being used for deletion
 		fmt.Printf("Failed to delete document with ID: %s\n", id)
 
-		http.Error(w, fmt.Sprintf("Failed to delete document: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to delete document: %v", err), databaseErrorStatus(err))
 		return
 	}
 
@@ -188,37 +389,118 @@ func GenericFindHandler(w http.ResponseWriter, r *http.Request, collectionName s
 	}
 
 	// Convert the filter string to a map[string]interface{}
-	var filter interface{}
+	var filter map[string]interface{}
 	err := json.Unmarshal([]byte(filterParam), &filter)
 	if err != nil {
 		http.Error(w, "Invalid filter format", http.StatusBadRequest)
 		return
 	}
 
-	// Define a variable to hold the result of the find operation
-	// Assuming result is of type interface{}, you can modify this based on your implementation
-	var result interface{}
+	// Parse the paging, sorting and projection parameters
+	opts, paginated, err := parseFindOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Call the Find function to retrieve documents from the specified collection
-	err = database.Find(collectionName, filter, &result)
+	// Retrieve the matching documents from the specified collection, keeping
+	// sealed bids out of queries on their amount and description
+	query, err := sealFilter(r, collectionName, filter, opts)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to find documents: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to find documents: %v", err), databaseErrorStatus(err))
 		return
 	}
+	result := []bson.M{}
+	page, err := db().FindPage(r.Context(), collectionName, query, opts, &result)
+	if err == nil {
+		err = sealResult(r, collectionName, &result)
+	}
+	hideFields(collectionName, &result)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to find documents: %v", err), databaseErrorStatus(err))
+		return
+	}
+
+	// Respond with the matching documents
+	writeFindResponse(w, &result, page, opts, paginated)
+}
+
+// maxPageSize caps the limit query parameter of list and find endpoints.
+const maxPageSize = 1000
+
+// pageResponse is the envelope returned by list and find endpoints when
+// pagination is requested.
+type pageResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+	Total      *int64      `json:"total,omitempty"`
+}
+
+// parseFindOptions reads the limit, after, sort, fields and total query
+// parameters. The boolean result reports whether the client asked for a
+// paginated response envelope rather than a plain array.
+func parseFindOptions(query url.Values) (database.FindOptions, bool, error) {
+	var opts database.FindOptions
+	paginated := false
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n <= 0 || n > maxPageSize {
+			return opts, false, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = n
+		paginated = true
+	}
+	if after := query.Get("after"); after != "" {
+		opts.After = after
+		paginated = true
+	}
+	if total := query.Get("total"); total != "" {
+		countTotal, err := strconv.ParseBool(total)
+		if err != nil {
+			return opts, false, fmt.Errorf("total must be true or false")
+		}
+		opts.CountTotal = countTotal
+		paginated = true
+	}
+	if sort := query.Get("sort"); sort != "" {
+		opts.Sort = database.ParseSort(sort)
+	}
+	if fields := query.Get("fields"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				opts.Fields = append(opts.Fields, field)
+			}
+		}
+	}
+
+	return opts, paginated, nil
+}
+
+// writeFindResponse writes items either as a plain JSON array or, for
+// paginated requests, wrapped in a pageResponse.
+func writeFindResponse(w http.ResponseWriter, items interface{}, page database.Page, opts database.FindOptions, paginated bool) {
+	var body interface{} = items
+	if paginated {
+		response := pageResponse{Items: items, NextCursor: page.NextCursor}
+		if opts.CountTotal {
+			response.Total = &page.Total
+		}
+		body = response
+	}
 
 	// Marshal the result to JSON
-	responseBody, err := json.Marshal(result)
+	responseBody, err := json.Marshal(body)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to marshal response: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Set the Content-Type header to application/json
 	w.Header().Set("Content-Type", "application/json")
-
-	// Set the status code to 200 (OK)
 	w.WriteHeader(http.StatusOK)
-
-	// Write the response body
 	w.Write(responseBody)
 }
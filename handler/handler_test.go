@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+// slowDatabase is a database.Database whose reads block until the request
+// context is done.
+type slowDatabase struct {
+	database.Database
+}
+
+func (slowDatabase) FindPage(ctx context.Context, collectionName string, filter interface{}, opts database.FindOptions, result interface{}) (database.Page, error) {
+	<-ctx.Done()
+	return database.Page{}, ctx.Err()
+}
+
+func TestGenericGetAllHandlerTimeout(t *testing.T) {
+	// Use a backend that never answers before the deadline
+	SetDatabase(slowDatabase{})
+	defer SetDatabase(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/bid", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	var bids []structure.Bid
+	GenericGetAllHandler(rr, req, "bid", &bids)
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestGenericCreateHandlerValidation(t *testing.T) {
+	// A bid without a description or amount
+	req := httptest.NewRequest("POST", "/bid/create", strings.NewReader(`{"t_time":"2024-03-14T12:00:00Z"}`))
+	rr := httptest.NewRecorder()
+
+	CreateBidHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+	var body structure.ValidationError
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	codes := map[string]string{}
+	for _, fieldErr := range body.Errors {
+		codes[fieldErr.Field] = fieldErr.Code
+	}
+	if codes["description"] != structure.CodeRequired || codes["bidAmount"] != structure.CodeOutOfRange {
+		t.Errorf("unexpected field errors: %+v", body.Errors)
+	}
+}
+
+func TestGenericUpdateHandlerValidation(t *testing.T) {
+	// Arrange
+	database.ClearCollection("review")
+	review := structure.Review{Review: "Great service", Timelines: 4, Quality: 4, Communication: 4, Behavior: 4}
+	if err := database.Create("review", &review); err != nil {
+		t.Fatalf("Failed to insert review document: %v", err)
+	}
+
+	// Act: a partial update that pushes one rating out of range
+	req := httptest.NewRequest("PUT", "/review?id="+review.ID.Hex(), strings.NewReader(`{"quality":7}`))
+	rr := httptest.NewRecorder()
+	UpdateReviewHandler(rr, req)
+
+	// Assert
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+	var stored structure.Review
+	if err := database.Get("review", &stored, review.ID.Hex()); err != nil {
+		t.Fatalf("Failed to retrieve review document: %v", err)
+	}
+	if stored.Quality != 4 {
+		t.Errorf("Expected the invalid update not to be stored, got quality %v", stored.Quality)
+	}
+}
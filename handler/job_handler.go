@@ -1,34 +1,307 @@
 package handler
 
 import (
-    "net/http"
+	"Go-sumon/auth"
+	"Go-sumon/database"
 	"Go-sumon/structure"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func GetAllJobHandler(w http.ResponseWriter, r *http.Request) {
 
-    var job []structure.Review
-    GenericGetAllHandler(w, r, "job", &job)
+	var job []structure.Job
+	GenericGetAllHandler(w, r, "job", &job)
 }
 
 func CreateJobHandler(w http.ResponseWriter, r *http.Request) {
-   
-    var job structure.Review
-    GenericCreateHandler(w, r, "job", &job)
+
+	var job structure.Job
+	createDocument(w, r, "job", &job, func(r *http.Request) error {
+		// Bids and their acceptance are recorded by the bidding workflow
+		job.Bid = nil
+		job.AcceptedBid = primitive.NilObjectID
+		job.ServiceProviderID = primitive.NilObjectID
+		job.ServiceProviders = structure.ServiceProvider{}
+		job.BiddingClosed = false
+		job.Status = ""
+
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			return nil
+		}
+
+		// The job belongs to the client posting it and starts open for bids
+		var client structure.Client
+		if err := db().Get(r.Context(), "client", user.ID.Hex(), &client); err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				return err
+			}
+			client = structure.Client{ID: user.ID, User: *user}
+		}
+		job.Clients = client
+		job.ClientID = user.ID
+		job.JobStatus = structure.JobStatusJobPosted
+		job.History = []structure.JobTransition{{To: structure.JobStatusJobPosted, Actor: user.ID, At: time.Now().UTC()}}
+		return nil
+	})
 }
 
 func GetJobHandler(w http.ResponseWriter, r *http.Request) {
-    GenericGetHandler(w, r, "job")
+	GenericGetHandler(w, r, "job")
 }
 
 func UpdateJobHandler(w http.ResponseWriter, r *http.Request) {
-    GenericUpdateHandler(w, r, "job")
+	GenericUpdateHandler(w, r, "job")
 }
 
 func DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
-    GenericDeleteHandler(w, r, "job")
+	GenericDeleteHandler(w, r, "job")
 }
 
 func FindJobHandler(w http.ResponseWriter, r *http.Request) {
-    GenericFindHandler(w, r, "job")
-}
\ No newline at end of file
+	GenericFindHandler(w, r, "job")
+}
+
+// PlaceBidHandler places a pending bid on an open job.
+func PlaceBidHandler(w http.ResponseWriter, r *http.Request) {
+	placeBid(w, r, r.PathValue("id"))
+}
+
+// AcceptBidHandler accepts a bid on an open job and rejects its other bids,
+// assigning the job to the service provider who made the bid.
+func AcceptBidHandler(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if err := db().AcceptBid(r.Context(), jobID, r.PathValue("bidId"), actorID(r), ""); err != nil {
+		writeBiddingError(w, err)
+		return
+	}
+	writeJobUpdate(w, r, jobID, nil, true)
+}
+
+// RejectBidHandler rejects a bid on an open job, which stays open for other
+// bids, and responds with the bid.
+func RejectBidHandler(w http.ResponseWriter, r *http.Request) {
+	bidID := r.PathValue("bidId")
+	if err := db().RejectBid(r.Context(), r.PathValue("id"), bidID); err != nil {
+		writeBiddingError(w, err)
+		return
+	}
+
+	var bid structure.Bid
+	if err := db().Get(r.Context(), "bid", bidID, &bid); err != nil {
+		http.Error(w, "Failed to get bid", databaseErrorStatus(err))
+		return
+	}
+	bids := []structure.Bid{bid}
+	if err := sealResult(r, "bid", &bids); err != nil {
+		http.Error(w, "Failed to get bid", databaseErrorStatus(err))
+		return
+	}
+	responseBody, err := json.Marshal(bids[0])
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// BanJobHandler bans a job, closing it to updates and bids. The optional
+// reason in the body is kept in the job's history.
+func BanJobHandler(w http.ResponseWriter, r *http.Request) {
+	var job structure.Job
+	if err := getTarget(r, "job", r.PathValue("id"), &job); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	if job.JobStatus == structure.JobStatusBan {
+		writeJobUpdate(w, r, job.ID.Hex(), nil, false)
+		return
+	}
+
+	body, err := requestBody(r)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	reason, _ := bodyValue(body, "reason").(string)
+	update, err := moveJob(r, job, structure.JobStatusBan, reason)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	writeJobUpdate(w, r, job.ID.Hex(), update, true)
+}
+
+// JobHistoryHandler lists the status changes of a job, oldest first.
+func JobHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := pathObjectID(w, r); !ok {
+		return
+	}
+	var job structure.Job
+	if err := getTarget(r, "job", r.PathValue("id"), &job); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	history := job.History
+	if history == nil {
+		history = []structure.JobTransition{}
+	}
+	responseBody, err := json.Marshal(history)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// prepareJobUpdate checks a change of the job's status in an update against
+// the allowed transitions and adds it to the job's history. The optional
+// reason in the body explains the change and is kept only in the history.
+func prepareJobUpdate(r *http.Request, id string, update bson.M) error {
+	var reason string
+	var status interface{}
+	for key, value := range update {
+		switch strings.ToLower(key) {
+		case "reason":
+			reason, _ = value.(string)
+			delete(update, key)
+		case "jobstatus":
+			status = value
+			delete(update, key)
+		}
+	}
+	if status == nil {
+		return nil
+	}
+	update["jobstatus"] = status
+
+	// Validation reports statuses that are not job statuses
+	to, ok := status.(string)
+	if !ok || !structure.JobStatus(to).Known() {
+		return nil
+	}
+	var job structure.Job
+	if err := getTarget(r, "job", id, &job); err != nil {
+		return err
+	}
+	if job.JobStatus == structure.JobStatus(to) {
+		return nil
+	}
+	moved, err := moveJob(r, job, structure.JobStatus(to), reason)
+	if err != nil {
+		return err
+	}
+	for key, value := range moved {
+		update[key] = value
+	}
+	return nil
+}
+
+// jobUpdated publishes the change of the job's status an update made, if it
+// made one.
+func jobUpdated(r *http.Request, id string, update bson.M) {
+	if _, moved := update["history"]; !moved {
+		return
+	}
+	var job structure.Job
+	if err := db().Get(r.Context(), "job", id, &job); err != nil {
+		log.Printf("Failed to get job %s to publish its status: %v", id, err)
+		return
+	}
+	publishJobMoved(r, job)
+}
+
+// updateJob applies an update to the job, recording the change of its status
+// the update makes, if it makes one.
+func updateJob(r *http.Request, id string, update bson.M) error {
+	history, _ := update["history"].([]structure.JobTransition)
+	if len(history) == 0 {
+		return db().Update(r.Context(), "job", id, update)
+	}
+	return db().MoveJob(r.Context(), id, history[len(history)-1], update)
+}
+
+// moveJob returns the update that moves job to status to and records the
+// move, by the requesting user, in the job's history. It answers 409 Conflict
+// if the job may not move to that status.
+func moveJob(r *http.Request, job structure.Job, to structure.JobStatus, reason string) (bson.M, error) {
+	transition, err := job.Transition(to, actorID(r), time.Now(), reason)
+	if err != nil {
+		return nil, &policyError{Status: http.StatusConflict, Code: "invalid_transition", Message: err.Error()}
+	}
+	return bson.M{"jobstatus": to, "history": append(job.History, transition)}, nil
+}
+
+// actorID returns the ID of the user making the request, if any.
+func actorID(r *http.Request) primitive.ObjectID {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		return user.ID
+	}
+	return primitive.NilObjectID
+}
+
+// writeJobUpdate applies update to the job, if any, and responds with the
+// updated job. moved reports that the job's status changed, which is
+// published to the job's followers.
+func writeJobUpdate(w http.ResponseWriter, r *http.Request, id string, update bson.M, moved bool) {
+	if update != nil {
+		if err := updateJob(r, id, update); err != nil {
+			http.Error(w, "Failed to update job", databaseErrorStatus(err))
+			return
+		}
+	}
+
+	var job structure.Job
+	if err := db().Get(r.Context(), "job", id, &job); err != nil {
+		http.Error(w, "Failed to get job", databaseErrorStatus(err))
+		return
+	}
+	if moved {
+		publishJobMoved(r, job)
+	}
+	responseBody, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// MyJobsHandler lists the jobs posted by the authenticated client.
+func MyJobsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	var jobs []structure.Job
+	listDocuments(w, r, "job", bson.M{"clientid": user.ID}, &jobs)
+}
+
+// JobBidsHandler lists the bids on a job.
+func JobBidsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var bids []structure.Bid
+	listDocuments(w, r, "bid", bson.M{"jobid": jobID}, &bids)
+}
+
+// JobReviewsHandler lists the reviews of a job.
+func JobReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var reviews []structure.Review
+	listDocuments(w, r, "review", bson.M{"jobid": jobID}, &reviews)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// JobLedgerHandler lists the escrow journal entries of a job, oldest first
+// unless ?sort= says otherwise.
+func JobLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var entries []structure.JournalEntry
+	listDocuments(w, r, database.JournalCollection, bson.M{"jobid": jobID}, &entries)
+}
+
+// SPBalanceHandler responds with the balance of a service provider, the
+// money released to them from the escrow of their completed jobs.
+func SPBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	spID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	amount, err := database.AccountBalance(r.Context(), db(), structure.ProviderAccount(spID))
+	if err != nil {
+		http.Error(w, "Failed to get balance", databaseErrorStatus(err))
+		return
+	}
+	writeJSON(w, structure.Balance{Amount: amount})
+}
+
+// prepareMoney returns an update hook that stores field, sent in taka as a
+// number or string under any capitalisation, as structure.Money. jsonField
+// names the field in validation errors.
+func prepareMoney(field, jsonField string) func(r *http.Request, id string, update bson.M) error {
+	return func(r *http.Request, id string, update bson.M) error {
+		for key, value := range update {
+			if strings.ToLower(key) != field {
+				continue
+			}
+			delete(update, key)
+			amount, err := parseMoney(value)
+			if err != nil {
+				return &structure.ValidationError{Errors: []structure.FieldError{
+					{Field: jsonField, Code: structure.CodeInvalidFormat, Message: err.Error()},
+				}}
+			}
+			update[field] = amount
+		}
+		return nil
+	}
+}
+
+// parseMoney reads an amount of taka decoded from a JSON body.
+func parseMoney(value interface{}) (structure.Money, error) {
+	switch value := value.(type) {
+	case float64:
+		return structure.ParseMoney(strconv.FormatFloat(value, 'f', -1, 64))
+	case string:
+		return structure.ParseMoney(value)
+	}
+	return 0, structure.ErrInvalidMoney
+}
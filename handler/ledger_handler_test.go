@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+func TestEscrowLedger(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	database.ClearCollection(database.JournalCollection)
+	jobPath := "/job/" + f.openJob.ID.Hex()
+	balancePath := "/serviceProvider/" + f.provider.ID.Hex() + "/balance"
+
+	// Act: the owner accepts the bid and the job is completed
+	rr := serveAs(f.owner, httptest.NewRequest("POST", jobPath+"/bids/"+f.bid.ID.Hex()+"/accept", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Accepting the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+	for _, status := range []structure.JobStatus{structure.JobStatusJobStarted, structure.JobStatusCompleted} {
+		rr := serveAs(f.owner, httptest.NewRequest("PATCH", jobPath, strings.NewReader(`{"jobStatus":"`+string(status)+`"}`)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Moving the job to %s returned %v: %s", status, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Assert
+	for _, tt := range []struct {
+		name string
+		user *structure.User
+		want int
+	}{
+		{"client", f.owner, http.StatusOK},
+		{"service provider", f.provider, http.StatusOK},
+		{"admin", f.admin, http.StatusOK},
+		{"other client", f.otherClient, http.StatusForbidden},
+		{"other service provider", f.otherProvider, http.StatusForbidden},
+	} {
+		rr := serveAs(tt.user, httptest.NewRequest("GET", jobPath+"/ledger", nil))
+		if rr.Code != tt.want {
+			t.Errorf("Expected the %s to get %d for the ledger, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+	rr = serveAs(f.owner, httptest.NewRequest("GET", jobPath+"/ledger", nil))
+	var entries []structure.JournalEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode ledger: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Kind != structure.EntryHold || entries[1].Kind != structure.EntryRelease {
+		t.Errorf("Expected a hold and a release, got %+v", entries)
+	}
+
+	rr = serveAs(f.provider, httptest.NewRequest("GET", balancePath, nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"amount":500.00}` {
+		t.Errorf("Expected the released amount as the balance, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serveAs(f.otherProvider, httptest.NewRequest("GET", balancePath, nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another service provider's balance to be forbidden, got %d", rr.Code)
+	}
+	rr = serveAs(f.admin, httptest.NewRequest("PATCH", "/serviceProvider/"+f.provider.ID.Hex(), strings.NewReader(`{"spbalance":{"amount":1000}}`)))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected the stored balance to be read-only, got %d", rr.Code)
+	}
+}
+
+func TestBidAmountUpdates(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	bidPath := "/bid/" + f.bid.ID.Hex()
+
+	// Act
+	valid := serveAs(f.provider, httptest.NewRequest("PATCH", bidPath, strings.NewReader(`{"bidAmount":120.5}`)))
+	invalid := serveAs(f.provider, httptest.NewRequest("PATCH", bidPath, strings.NewReader(`{"bidAmount":1.005}`)))
+
+	// Assert
+	if valid.Code != http.StatusOK {
+		t.Fatalf("Updating the amount returned %v: %s", valid.Code, valid.Body.String())
+	}
+	if invalid.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an amount with fractions of a paisa to be rejected, got %d: %s", invalid.Code, invalid.Body.String())
+	}
+	var bid structure.Bid
+	if err := database.Get("bid", &bid, f.bid.ID.Hex()); err != nil {
+		t.Fatalf("Failed to get bid document: %v", err)
+	}
+	if want, _ := structure.ParseMoney("120.50"); bid.BidAmount != want {
+		t.Errorf("Expected the amount to be stored as %s, got %s", want, bid.BidAmount)
+	}
+}
+
+func TestJobWithEscrowCannotBeDeleted(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	database.ClearCollection(database.JournalCollection)
+	jobPath := "/job/" + f.openJob.ID.Hex()
+	if rr := serveAs(f.owner, httptest.NewRequest("POST", jobPath+"/bids/"+f.bid.ID.Hex()+"/accept", nil)); rr.Code != http.StatusOK {
+		t.Fatalf("Accepting the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	// Act
+	held := serveAs(f.admin, httptest.NewRequest("DELETE", jobPath, nil))
+	if rr := serveAs(f.owner, httptest.NewRequest("PATCH", jobPath, strings.NewReader(`{"jobStatus":"job_cancelled"}`))); rr.Code != http.StatusOK {
+		t.Fatalf("Cancelling the job returned %v: %s", rr.Code, rr.Body.String())
+	}
+	refunded := serveAs(f.owner, httptest.NewRequest("DELETE", jobPath, nil))
+
+	// Assert
+	if held.Code != http.StatusConflict || !strings.Contains(held.Body.String(), `"code":"escrow_held"`) {
+		t.Errorf("Expected deleting a job holding escrow to conflict, got %v: %s", held.Code, held.Body.String())
+	}
+	if refunded.Code != http.StatusOK {
+		t.Errorf("Expected the refunded job to be deleted, got %v: %s", refunded.Code, refunded.Body.String())
+	}
+}
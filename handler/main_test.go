@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"Go-sumon/database"
+)
+
+// TestMain runs the suite against the in-memory backend unless MONGO_TEST_URI
+// points at a MongoDB server to test against instead.
+func TestMain(m *testing.M) {
+	opts := database.DefaultOptions()
+	opts.Backend = database.BackendMemory
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		opts.Backend = database.BackendMongo
+		opts.URI = uri
+	}
+
+	store, err := database.OpenDatabase(context.Background(), opts)
+	if err != nil {
+		panic(err)
+	}
+	database.SetDefault(store)
+
+	code := m.Run()
+	database.Close(context.Background())
+	os.Exit(code)
+}
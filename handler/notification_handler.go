@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"Go-sumon/database"
+	"Go-sumon/notify"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UserNotificationsHandler lists the user's inbox, or only the unread
+// notifications with ?unread=true.
+func UserNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	filter := bson.M{"userid": userID}
+	if r.URL.Query().Get("unread") == "true" {
+		filter["read"] = false
+	}
+	var notifications []structure.Notification
+	listDocuments(w, r, "notification", filter, &notifications)
+}
+
+// ReadNotificationHandler marks one of the user's notifications as read.
+func ReadNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	markNotification(w, r, true)
+}
+
+// UnreadNotificationHandler marks one of the user's notifications as unread.
+func UnreadNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	markNotification(w, r, false)
+}
+
+// markNotification sets whether the notification in the path was read, and
+// responds with it.
+func markNotification(w http.ResponseWriter, r *http.Request, read bool) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var notification structure.Notification
+	err := db().Get(r.Context(), "notification", r.PathValue("notificationId"), &notification)
+	if err == nil && notification.UserID != userID {
+		err = database.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "No such notification", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get notification", databaseErrorStatus(err))
+		return
+	}
+
+	if notification.Read != read {
+		if err := db().Update(r.Context(), "notification", notification.ID.Hex(), bson.M{"read": read}); err != nil {
+			http.Error(w, "Failed to update notification", databaseErrorStatus(err))
+			return
+		}
+		notification.Read = read
+	}
+	writeJSON(w, notification)
+}
+
+// ReadAllNotificationsHandler marks every unread notification of the user as
+// read, and responds with how many there were.
+func ReadAllNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var unread []structure.Notification
+	opts := database.FindOptions{Fields: []string{"_id"}}
+	if _, err := db().FindPage(r.Context(), "notification", bson.M{"userid": userID, "read": false}, opts, &unread); err != nil {
+		http.Error(w, "Failed to retrieve notifications", databaseErrorStatus(err))
+		return
+	}
+	marked := 0
+	for _, notification := range unread {
+		err := db().Update(r.Context(), "notification", notification.ID.Hex(), bson.M{"read": true})
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Failed to update notifications", databaseErrorStatus(err))
+			return
+		}
+		if err == nil {
+			marked++
+		}
+	}
+	writeJSON(w, map[string]int{"marked": marked})
+}
+
+// GetNotificationPreferencesHandler responds with the user's notification
+// preferences, or the defaults if they have not chosen any.
+func GetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	prefs, err := notify.Preferences(r.Context(), db(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get notification preferences", databaseErrorStatus(err))
+		return
+	}
+	writeJSON(w, prefs)
+}
+
+// PutNotificationPreferencesHandler replaces the user's notification
+// preferences with the request body.
+func PutNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var prefs structure.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	prefs.ID = userID
+	if prefs.Channels == nil {
+		prefs.Channels = []structure.Channel{}
+	}
+	if err := prefs.Validate(); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	// Preferences are stored under the user's ID once they are first chosen
+	var existing structure.NotificationPreferences
+	err := db().Get(r.Context(), "notificationPreferences", userID.Hex(), &existing)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		err = db().Create(r.Context(), "notificationPreferences", &prefs)
+	case err == nil:
+		err = db().Update(r.Context(), "notificationPreferences", userID.Hex(), bson.M{"language": prefs.Language, "channels": prefs.Channels})
+		if errors.Is(err, database.ErrNotFound) {
+			// The preferences did not change
+			err = nil
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to save notification preferences", databaseErrorStatus(err))
+		return
+	}
+	writeJSON(w, prefs)
+}
+
+// writeJSON responds 200 OK with value as JSON.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	responseBody, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+// newNotifications puts notifications with the given titles in the user's
+// inbox, unread.
+func newNotifications(t *testing.T, user *structure.User, titles ...string) []structure.Notification {
+	t.Helper()
+	var notifications []structure.Notification
+	for _, title := range titles {
+		notification := structure.Notification{UserID: user.ID, Kind: structure.NotificationWelcome, Title: title, Body: title, CreatedAt: time.Now().UTC().Truncate(time.Millisecond)}
+		if err := database.Create("notification", &notification); err != nil {
+			t.Fatalf("Failed to insert notification document: %v", err)
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
+
+// inbox lists the user's notifications through the API.
+func inbox(t *testing.T, user *structure.User, query string) []structure.Notification {
+	t.Helper()
+	rr := serveAs(user, httptest.NewRequest("GET", "/user/"+user.ID.Hex()+"/notifications"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to list notifications: %d: %s", rr.Code, rr.Body.String())
+	}
+	var notifications []structure.Notification
+	if err := json.Unmarshal(rr.Body.Bytes(), &notifications); err != nil {
+		t.Fatalf("Failed to decode notifications: %v", err)
+	}
+	return notifications
+}
+
+func TestNotificationInbox(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	database.ClearCollection("notification")
+	mine := newNotifications(t, f.owner, "First", "Second", "Third")
+	newNotifications(t, f.otherClient, "Not mine")
+	base := "/user/" + f.owner.ID.Hex() + "/notifications/"
+
+	// Act
+	read := serveAs(f.owner, httptest.NewRequest("POST", base+mine[0].ID.Hex()+"/read", nil))
+	readAgain := serveAs(f.owner, httptest.NewRequest("POST", base+mine[0].ID.Hex()+"/read", nil))
+	unread := inbox(t, f.owner, "?unread=true")
+
+	// Assert
+	if read.Code != http.StatusOK || readAgain.Code != http.StatusOK || !strings.Contains(read.Body.String(), `"read":true`) {
+		t.Errorf("Expected the notification to be marked read, got %d and %d: %s", read.Code, readAgain.Code, read.Body.String())
+	}
+	if len(unread) != 2 || unread[0].ID != mine[1].ID || unread[1].ID != mine[2].ID {
+		t.Errorf("Expected the other two notifications to be unread, got %+v", unread)
+	}
+	if all := inbox(t, f.owner, ""); len(all) != 3 || !all[0].Read {
+		t.Errorf("Expected all three notifications in the inbox, got %+v", all)
+	}
+
+	// Users see and mark only their own notifications
+	if rr := serveAs(f.otherClient, httptest.NewRequest("GET", "/user/"+f.owner.ID.Hex()+"/notifications", nil)); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another user's inbox to be forbidden, got %d", rr.Code)
+	}
+	otherBase := "/user/" + f.otherClient.ID.Hex() + "/notifications/"
+	if rr := serveAs(f.otherClient, httptest.NewRequest("POST", otherBase+mine[1].ID.Hex()+"/read", nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected marking another user's notification to fail, got %d", rr.Code)
+	}
+
+	// Marking one unread, then all read
+	rr := serveAs(f.owner, httptest.NewRequest("POST", base+mine[0].ID.Hex()+"/unread", nil))
+	if rr.Code != http.StatusOK || len(inbox(t, f.owner, "?unread=true")) != 3 {
+		t.Errorf("Expected the notification to be marked unread, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = serveAs(f.owner, httptest.NewRequest("POST", "/user/"+f.owner.ID.Hex()+"/notifications/read", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"marked":3}` || len(inbox(t, f.owner, "?unread=true")) != 0 {
+		t.Errorf("Expected every notification to be marked read, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if others := inbox(t, f.otherClient, "?unread=true"); len(others) != 1 {
+		t.Errorf("Expected another user's notifications to stay unread, got %+v", others)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	database.ClearCollection("notificationPreferences")
+	path := "/user/" + f.provider.ID.Hex() + "/notificationPreferences"
+
+	// Act
+	defaults := serveAs(f.provider, httptest.NewRequest("GET", path, nil))
+	invalid := serveAs(f.provider, httptest.NewRequest("PUT", path, strings.NewReader(`{"language":"fr","channels":["pigeon"]}`)))
+	saved := serveAs(f.provider, httptest.NewRequest("PUT", path, strings.NewReader(`{"language":"bn","channels":["email","in_app"]}`)))
+	unchanged := serveAs(f.provider, httptest.NewRequest("PUT", path, strings.NewReader(`{"language":"bn","channels":["email","in_app"]}`)))
+	forbidden := serveAs(f.owner, httptest.NewRequest("PUT", path, strings.NewReader(`{"language":"en"}`)))
+	got := serveAs(f.provider, httptest.NewRequest("GET", path, nil))
+
+	// Assert
+	if defaults.Code != http.StatusOK || !strings.Contains(defaults.Body.String(), `"language":"en","channels":["sms","in_app"]`) {
+		t.Errorf("Expected the default preferences, got %d: %s", defaults.Code, defaults.Body.String())
+	}
+	if invalid.Code != http.StatusUnprocessableEntity || !strings.Contains(invalid.Body.String(), "channels[0]") {
+		t.Errorf("Expected invalid preferences to be rejected, got %d: %s", invalid.Code, invalid.Body.String())
+	}
+	if saved.Code != http.StatusOK || unchanged.Code != http.StatusOK || forbidden.Code != http.StatusForbidden {
+		t.Errorf("Expected only the user to save their preferences, got %d, %d and %d", saved.Code, unchanged.Code, forbidden.Code)
+	}
+	var prefs structure.NotificationPreferences
+	if err := json.Unmarshal(got.Body.Bytes(), &prefs); err != nil {
+		t.Fatalf("Failed to decode preferences: %v", err)
+	}
+	if prefs.ID != f.provider.ID || prefs.Language != structure.LanguageBangla || !prefs.Wants(structure.ChannelEmail) || prefs.Wants(structure.ChannelSMS) {
+		t.Errorf("Expected the saved preferences, got %+v", prefs)
+	}
+}
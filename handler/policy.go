@@ -0,0 +1,420 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"Go-sumon/auth"
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Policy decides whether the authenticated user may make a request. Routes
+// declare their policy in the route table, and RegisterRoutes checks it
+// before the route's handler runs.
+type Policy struct {
+	Rule  string `json:"rule"` // human-readable summary, listed by /_routes
+	check func(r *http.Request, user *structure.User) error
+}
+
+// policyError is a denied request. It is written as JSON with its status.
+type policyError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *policyError) Error() string {
+	return e.Message
+}
+
+// forbidden denies a request with 403 Forbidden.
+func forbidden(format string, args ...interface{}) error {
+	return &policyError{Status: http.StatusForbidden, Code: "forbidden", Message: fmt.Sprintf(format, args...)}
+}
+
+// authorize runs next only if policy allows the request.
+func authorize(policy *Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := auth.UserFromContext(r.Context())
+		if err := policy.check(r, user); err != nil {
+			writePolicyError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writePolicyError responds to a request that a policy rejected or could not
+// decide on.
+func writePolicyError(w http.ResponseWriter, err error) {
+	if writeValidationError(w, err) {
+		return
+	}
+	var denied *policyError
+	if !errors.As(err, &denied) {
+		log.Printf("Failed to check policy: %v", err)
+		http.Error(w, "Failed to authorize request", databaseErrorStatus(err))
+		return
+	}
+
+	responseBody, err := json.Marshal(denied)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(denied.Status)
+	w.Write(responseBody)
+}
+
+// allOf allows a request that every policy allows.
+func allOf(policies ...*Policy) *Policy {
+	rules := make([]string, len(policies))
+	for i, policy := range policies {
+		rules[i] = policy.Rule
+	}
+	return &Policy{
+		Rule: strings.Join(rules, " and "),
+		check: func(r *http.Request, user *structure.User) error {
+			for _, policy := range policies {
+				if err := policy.check(r, user); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// anyOf allows a request that any of the policies allows, reporting the
+// last policy's denial otherwise.
+func anyOf(policies ...*Policy) *Policy {
+	rules := make([]string, len(policies))
+	for i, policy := range policies {
+		rules[i] = "(" + policy.Rule + ")"
+	}
+	return &Policy{
+		Rule: strings.Join(rules, " or "),
+		check: func(r *http.Request, user *structure.User) (err error) {
+			for _, policy := range policies {
+				if err = policy.check(r, user); err == nil {
+					return nil
+				}
+			}
+			return err
+		},
+	}
+}
+
+// role allows users of the given type.
+func role(userType structure.UserType) *Policy {
+	return &Policy{
+		Rule: "role " + string(userType),
+		check: func(r *http.Request, user *structure.User) error {
+			if user == nil || user.UserType != userType {
+				return forbidden("Only %s users may do this", userType)
+			}
+			return nil
+		},
+	}
+}
+
+// self allows users acting on their own user, client or service provider
+// document, which all share the user's ID.
+var self = &Policy{
+	Rule: "is the user",
+	check: func(r *http.Request, user *structure.User) error {
+		if user == nil || pathID(r) != user.ID.Hex() {
+			return forbidden("You may only change your own account")
+		}
+		return nil
+	},
+}
+
+// ownsJob allows the client who posted the job in the path, while it is not
+// banned.
+var ownsJob = &Policy{
+	Rule: "owns the job",
+	check: func(r *http.Request, user *structure.User) error {
+		var job structure.Job
+		if err := getTarget(r, "job", pathID(r), &job); err != nil {
+			return err
+		}
+		if user == nil || job.ClientID != user.ID {
+			return forbidden("Only the client who posted this job may do this")
+		}
+		if job.JobStatus == structure.JobStatusBan {
+			return forbidden("This job has been banned")
+		}
+		return nil
+	},
+}
+
+// partyToJob allows the client who posted the job in the path and the
+// service provider it is assigned to.
+var partyToJob = &Policy{
+	Rule: "client or service provider of the job",
+	check: func(r *http.Request, user *structure.User) error {
+		var job structure.Job
+		if err := getTarget(r, "job", pathID(r), &job); err != nil {
+			return err
+		}
+		if user == nil || (job.ClientID != user.ID && job.ServiceProviderID != user.ID) {
+			return forbidden("Only the client and service provider of this job may do this")
+		}
+		return nil
+	},
+}
+
+// ownsBid allows the service provider who made the bid in the path.
+var ownsBid = &Policy{
+	Rule: "made the bid",
+	check: func(r *http.Request, user *structure.User) error {
+		var bid structure.Bid
+		if err := getTarget(r, "bid", pathID(r), &bid); err != nil {
+			return err
+		}
+		if user == nil || bid.SPID != user.ID {
+			return forbidden("Only the service provider who made this bid may do this")
+		}
+		return nil
+	},
+}
+
+// bidIsOpen allows changes to the bid in the path until it is accepted or
+// rejected, while its job is open for bids and before the job's bidding
+// deadline.
+var bidIsOpen = &Policy{
+	Rule: "bid is undecided on an open job",
+	check: func(r *http.Request, user *structure.User) error {
+		var bid structure.Bid
+		if err := getTarget(r, "bid", pathID(r), &bid); err != nil {
+			return err
+		}
+		if bid.Decided() {
+			return &policyError{Status: http.StatusConflict, Code: "bid_decided", Message: "This bid has already been decided"}
+		}
+		if bid.JobID.IsZero() {
+			return nil
+		}
+		var job structure.Job
+		if err := getTarget(r, "job", bid.JobID.Hex(), &job); err != nil {
+			return err
+		}
+		if job.JobStatus != structure.JobStatusJobPosted || job.BiddingOver(time.Now()) {
+			return &policyError{Status: http.StatusConflict, Code: "bidding_closed", Message: "Bidding on this job has closed"}
+		}
+		return nil
+	},
+}
+
+// holdsNoEscrow allows changes to the job in the path while its escrow is
+// empty, so that money held for it is released or refunded first.
+var holdsNoEscrow = &Policy{
+	Rule: "job holds no escrow",
+	check: func(r *http.Request, user *structure.User) error {
+		jobID, err := primitive.ObjectIDFromHex(pathID(r))
+		if err != nil {
+			return nil
+		}
+		held, err := database.AccountBalance(r.Context(), db(), structure.EscrowAccount(jobID))
+		if err != nil {
+			return err
+		}
+		if held != 0 {
+			return &policyError{Status: http.StatusConflict, Code: "escrow_held", Message: fmt.Sprintf("This job holds %s in escrow; cancel it to refund the client first", held)}
+		}
+		return nil
+	},
+}
+
+// ownsReview allows the client who wrote the review in the path.
+var ownsReview = &Policy{
+	Rule: "wrote the review",
+	check: func(r *http.Request, user *structure.User) error {
+		var review structure.Review
+		if err := getTarget(r, "review", pathID(r), &review); err != nil {
+			return err
+		}
+		if user == nil || review.ReviewerID != user.ID {
+			return forbidden("Only the client who wrote this review may do this")
+		}
+		return nil
+	},
+}
+
+// bidsOnOthersJob allows bids on a job that the bidder did not post and that
+// is not banned. The job is the one in the path, or else the one named by
+// jobId in the body.
+var bidsOnOthersJob = &Policy{
+	Rule: "job is not their own",
+	check: func(r *http.Request, user *structure.User) error {
+		job, err := bidJob(r)
+		if err != nil {
+			return err
+		}
+		if user != nil && job.ClientID == user.ID {
+			return forbidden("You cannot bid on your own job")
+		}
+		if job.JobStatus == structure.JobStatusBan {
+			return forbidden("This job has been banned")
+		}
+		return nil
+	},
+}
+
+// reviewsCompletedJob allows the client of a completed job, named by jobId
+// in the body, to review it.
+var reviewsCompletedJob = &Policy{
+	Rule: "client of the completed job",
+	check: func(r *http.Request, user *structure.User) error {
+		job, err := bodyJob(r)
+		if err != nil {
+			return err
+		}
+		if user == nil || job.ClientID != user.ID {
+			return forbidden("Only the client of a job may review it")
+		}
+		if job.JobStatus != structure.JobStatusCompleted {
+			return forbidden("Only completed jobs can be reviewed")
+		}
+		return nil
+	},
+}
+
+// keeps allows requests whose body does not set any of the fields, given as
+// dotted paths. Setting a parent document, such as user for user.usertype,
+// counts as setting the field.
+func keeps(fields ...string) *Policy {
+	return &Policy{
+		Rule: "does not change " + strings.Join(fields, ", "),
+		check: func(r *http.Request, user *structure.User) error {
+			body, err := requestBody(r)
+			if err != nil {
+				return err
+			}
+			for _, field := range fields {
+				for key := range body {
+					key = strings.ToLower(key)
+					if key == field || strings.HasPrefix(field, key+".") || strings.HasPrefix(key, field+".") {
+						return forbidden("You may not change %s", field)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// notSetTo allows requests whose body does not set the field, given as a
+// dotted path, to value.
+func notSetTo(field string, value string) *Policy {
+	return &Policy{
+		Rule: fmt.Sprintf("does not set %s to %s", field, value),
+		check: func(r *http.Request, user *structure.User) error {
+			body, err := requestBody(r)
+			if err != nil {
+				return err
+			}
+			if bodyValue(body, field) == value {
+				return forbidden("You may not set %s to %s", field, value)
+			}
+			return nil
+		},
+	}
+}
+
+// getTarget loads the document a request acts on, answering 404 when it
+// does not exist.
+func getTarget(r *http.Request, collectionName string, id string, result interface{}) error {
+	err := db().Get(r.Context(), collectionName, id, result)
+	if errors.Is(err, database.ErrNotFound) {
+		return &policyError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf("No %s with ID %s", collectionName, id)}
+	}
+	return err
+}
+
+// bidJob loads the job a bid is placed on: the job in the path, or else the
+// one named by jobId in the request body.
+func bidJob(r *http.Request) (structure.Job, error) {
+	id := r.PathValue("id")
+	if id == "" {
+		return bodyJob(r)
+	}
+	var job structure.Job
+	err := getTarget(r, "job", id, &job)
+	return job, err
+}
+
+// bodyJob loads the job named by jobId in the request body.
+func bodyJob(r *http.Request) (structure.Job, error) {
+	body, err := requestBody(r)
+	if err != nil {
+		return structure.Job{}, err
+	}
+	id, _ := bodyValue(body, "jobid").(string)
+	if id == "" {
+		return structure.Job{}, &structure.ValidationError{Errors: []structure.FieldError{
+			{Field: "jobId", Code: structure.CodeRequired, Message: "cannot be empty"},
+		}}
+	}
+
+	var job structure.Job
+	err = db().Get(r.Context(), "job", id, &job)
+	if err != nil && !database.IsTimeout(err) {
+		return structure.Job{}, &structure.ValidationError{Errors: []structure.FieldError{
+			{Field: "jobId", Code: structure.CodeInvalidValue, Message: "no job has this ID"},
+		}}
+	}
+	return job, err
+}
+
+// requestBody decodes the JSON request body without consuming it, so the
+// handler can still read it.
+func requestBody(r *http.Request) (map[string]interface{}, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var body map[string]interface{}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return body, nil
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, &policyError{Status: http.StatusBadRequest, Code: "invalid_body", Message: "Failed to decode request body"}
+	}
+	return body, nil
+}
+
+// bodyValue returns the value at a dotted path in body, matching keys
+// case-insensitively as encoding/json does. The path may be one dotted key,
+// as in an update, or nested objects, as in a created document.
+func bodyValue(body map[string]interface{}, path string) interface{} {
+	for key, value := range body {
+		key = strings.ToLower(key)
+		if key == path {
+			return value
+		}
+		if rest, ok := strings.CutPrefix(path, key+"."); ok {
+			if child, ok := value.(map[string]interface{}); ok {
+				return bodyValue(child, rest)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Go-sumon/auth"
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// policyFixtures are the users and documents the policy tests act on.
+type policyFixtures struct {
+	owner, otherClient, provider, otherProvider, admin *structure.User
+
+	openJob, completedJob, bannedJob, providerJob structure.Job
+	bid                                           structure.Bid
+	review                                        structure.Review
+}
+
+// newPolicyFixtures clears the collections and inserts the fixtures.
+func newPolicyFixtures(t *testing.T) *policyFixtures {
+	t.Helper()
+	for _, collection := range []string{"user", "client", "serviceProvider", "job", "bid", "review", database.OutboxCollection} {
+		database.ClearCollection(collection)
+	}
+
+	newUser := func(name string, n int, userType structure.UserType) *structure.User {
+		user := &structure.User{
+			ID:          primitive.NewObjectID(),
+			Name:        name,
+			PhoneNumber: "0170000000" + string(rune('0'+n)),
+			NID:         "100000000000" + string(rune('0'+n)),
+			UserType:    userType,
+		}
+		if err := database.Create("user", user); err != nil {
+			t.Fatalf("Failed to insert user document: %v", err)
+		}
+		return user
+	}
+	f := &policyFixtures{
+		owner:         newUser("Owner", 1, structure.UserTypeClient),
+		otherClient:   newUser("Other client", 2, structure.UserTypeClient),
+		provider:      newUser("Provider", 3, structure.UserTypeServiceProvider),
+		otherProvider: newUser("Other provider", 4, structure.UserTypeServiceProvider),
+		admin:         newUser("Admin", 5, structure.UserTypeAdmin),
+	}
+
+	newJob := func(title string, owner *structure.User, status structure.JobStatus) structure.Job {
+		job := structure.Job{Title: title, Clients: structure.Client{ID: owner.ID, User: *owner}, ClientID: owner.ID, JobStatus: status}
+		if err := database.Create("job", &job); err != nil {
+			t.Fatalf("Failed to insert job document: %v", err)
+		}
+		return job
+	}
+	f.openJob = newJob("Open job", f.owner, structure.JobStatusJobPosted)
+	f.completedJob = newJob("Completed job", f.owner, structure.JobStatusCompleted)
+	if err := database.Update("job", f.completedJob.ID.Hex(), map[string]interface{}{"serviceproviderid": f.provider.ID}); err != nil {
+		t.Fatalf("Failed to update job document: %v", err)
+	}
+	f.completedJob.ServiceProviderID = f.provider.ID
+	f.bannedJob = newJob("Banned job", f.owner, structure.JobStatusBan)
+	f.providerJob = newJob("Provider's own job", f.provider, structure.JobStatusJobPosted)
+
+	f.bid = structure.Bid{Description: "I can do it", BidAmount: structure.Taka(500), JobID: f.openJob.ID, SPID: f.provider.ID}
+	if err := database.Create("bid", &f.bid); err != nil {
+		t.Fatalf("Failed to insert bid document: %v", err)
+	}
+	f.review = structure.Review{Review: "Great work", Quality: 5, JobID: f.completedJob.ID, ReviewerID: f.owner.ID, RevieweeID: f.provider.ID}
+	if err := database.Create("review", &f.review); err != nil {
+		t.Fatalf("Failed to insert review document: %v", err)
+	}
+	return f
+}
+
+// serveAs serves the request through the route table's policies as user,
+// who is already authenticated.
+func serveAs(user *structure.User, req *http.Request) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	for _, route := range Routes() {
+		handler := route.handler
+		if route.Policy != nil {
+			handler = authorize(route.Policy, handler)
+		}
+		mux.HandleFunc(route.pattern(), handler)
+	}
+	if user != nil {
+		req = req.WithContext(auth.WithUser(req.Context(), user, auth.Claims{}))
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   func(f *policyFixtures) *structure.User
+		method string
+		path   func(f *policyFixtures) string
+		body   func(f *policyFixtures) string
+		want   int
+	}{
+		// Jobs
+		{"client creates job", owner, "POST", path("/job"), body(`{"title":"Paint the fence"}`), http.StatusCreated},
+		{"service provider creates job", provider, "POST", path("/job"), body(`{"title":"Paint the fence"}`), http.StatusForbidden},
+		{"client creates banned job", owner, "POST", path("/job"), body(`{"title":"Paint the fence","jobStatus":"ban"}`), http.StatusForbidden},
+		{"owner updates job", owner, "PATCH", jobPath("openJob", ""), body(`{"title":"Renamed"}`), http.StatusOK},
+		{"other client updates job", otherClient, "PATCH", jobPath("openJob", ""), body(`{"title":"Renamed"}`), http.StatusForbidden},
+		{"service provider updates job", provider, "PATCH", jobPath("openJob", ""), body(`{"title":"Renamed"}`), http.StatusForbidden},
+		{"owner bans job by update", owner, "PATCH", jobPath("openJob", ""), body(`{"jobstatus":"ban"}`), http.StatusForbidden},
+		{"owner cancels job", owner, "PATCH", jobPath("openJob", ""), body(`{"jobStatus":"job_cancelled","reason":"No longer needed"}`), http.StatusOK},
+		{"owner completes open job", owner, "PATCH", jobPath("openJob", ""), body(`{"jobstatus":"job_completed"}`), http.StatusConflict},
+		{"owner accepts by update", owner, "PATCH", jobPath("openJob", ""), body(`{"jobstatus":"bid_accepted"}`), http.StatusForbidden},
+		{"owner rewrites history", owner, "PATCH", jobPath("openJob", ""), body(`{"history":[]}`), http.StatusForbidden},
+		{"owner disputes completed job", owner, "PATCH", jobPath("completedJob", ""), body(`{"jobstatus":"job_disputed"}`), http.StatusOK},
+		{"owner reassigns job", owner, "PATCH", jobPath("openJob", ""), body(`{"clients._id":"0123456789abcdef01234567"}`), http.StatusForbidden},
+		{"owner updates banned job", owner, "PATCH", jobPath("bannedJob", ""), body(`{"title":"Renamed"}`), http.StatusForbidden},
+		{"owner updates missing job", owner, "PATCH", path("/job/0123456789abcdef01234567"), body(`{"title":"Renamed"}`), http.StatusNotFound},
+		{"owner deletes job", owner, "DELETE", jobPath("openJob", ""), body(""), http.StatusOK},
+		{"other client deletes job", otherClient, "DELETE", jobPath("openJob", ""), body(""), http.StatusForbidden},
+		{"admin deletes job", admin, "DELETE", jobPath("openJob", ""), body(""), http.StatusOK},
+
+		// Accepting bids
+		{"owner accepts bid", owner, "POST", acceptPath, body(""), http.StatusOK},
+		{"other client accepts bid", otherClient, "POST", acceptPath, body(""), http.StatusForbidden},
+		{"service provider accepts bid", provider, "POST", acceptPath, body(""), http.StatusForbidden},
+		{"owner rejects bid", owner, "POST", rejectPath, body(""), http.StatusOK},
+		{"other client rejects bid", otherClient, "POST", rejectPath, body(""), http.StatusForbidden},
+		{"bidder rejects own bid", provider, "POST", rejectPath, body(""), http.StatusForbidden},
+
+		// Banning jobs
+		{"admin bans job", admin, "POST", jobPath("openJob", "/ban"), body(""), http.StatusOK},
+		{"owner bans job", owner, "POST", jobPath("openJob", "/ban"), body(""), http.StatusForbidden},
+
+		// Bids
+		{"service provider bids", provider, "POST", path("/bid"), jobBody("openJob", `"description":"Hire me","bidAmount":100`), http.StatusCreated},
+		{"client bids", otherClient, "POST", path("/bid"), jobBody("openJob", `"description":"Hire me","bidAmount":100`), http.StatusForbidden},
+		{"service provider bids on own job", provider, "POST", path("/bid"), jobBody("providerJob", `"description":"Hire me","bidAmount":100`), http.StatusForbidden},
+		{"service provider bids on banned job", provider, "POST", path("/bid"), jobBody("bannedJob", `"description":"Hire me","bidAmount":100`), http.StatusForbidden},
+		{"service provider bids on job", provider, "POST", jobPath("openJob", "/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusCreated},
+		{"client bids on job", otherClient, "POST", jobPath("openJob", "/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusForbidden},
+		{"service provider bids on own job by path", provider, "POST", jobPath("providerJob", "/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusForbidden},
+		{"service provider bids on banned job by path", provider, "POST", jobPath("bannedJob", "/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusForbidden},
+		{"service provider bids on completed job", provider, "POST", jobPath("completedJob", "/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusConflict},
+		{"service provider bids on missing job", provider, "POST", path("/job/0123456789abcdef01234567/bids"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusNotFound},
+		{"bidder accepts own bid by update", provider, "PATCH", bidPath, body(`{"status":"accepted"}`), http.StatusForbidden},
+		{"bid without job", provider, "POST", path("/bid"), body(`{"description":"Hire me","bidAmount":100}`), http.StatusUnprocessableEntity},
+		{"bid on unknown job", provider, "POST", path("/bid"), body(`{"jobId":"0123456789abcdef01234567","description":"Hire me","bidAmount":100}`), http.StatusUnprocessableEntity},
+		{"bidder updates bid", provider, "PATCH", bidPath, body(`{"description":"Cheaper"}`), http.StatusOK},
+		{"other service provider updates bid", otherProvider, "PATCH", bidPath, body(`{"description":"Cheaper"}`), http.StatusForbidden},
+		{"bidder moves bid to another job", provider, "PATCH", bidPath, jobBody("completedJob", ""), http.StatusForbidden},
+		{"bidder deletes bid", provider, "DELETE", bidPath, body(""), http.StatusOK},
+		{"other service provider deletes bid", otherProvider, "DELETE", bidPath, body(""), http.StatusForbidden},
+		{"admin deletes bid", admin, "DELETE", bidPath, body(""), http.StatusOK},
+
+		// Reviews
+		{"client reviews completed job", owner, "POST", path("/review"), jobBody("completedJob", `"review":"Well done","quality":5`), http.StatusCreated},
+		{"client reviews open job", owner, "POST", path("/review"), jobBody("openJob", `"review":"Well done","quality":5`), http.StatusForbidden},
+		{"other client reviews job", otherClient, "POST", path("/review"), jobBody("completedJob", `"review":"Well done","quality":5`), http.StatusForbidden},
+		{"service provider reviews job", provider, "POST", path("/review"), jobBody("completedJob", `"review":"Well done","quality":5`), http.StatusForbidden},
+		{"reviewer updates review", owner, "PATCH", reviewPath, body(`{"review":"Excellent"}`), http.StatusOK},
+		{"other client updates review", otherClient, "PATCH", reviewPath, body(`{"review":"Terrible"}`), http.StatusForbidden},
+
+		// Accounts
+		{"signup as admin", nobody, "POST", path("/user"), body(`{"name":"Mallory","userType":"admin"}`), http.StatusForbidden},
+		{"client signup as admin", nobody, "POST", path("/client"), body(`{"user":{"name":"Mallory","userType":"admin"}}`), http.StatusForbidden},
+		{"user updates self", owner, "PATCH", userPath("owner"), body(`{"name":"New name"}`), http.StatusOK},
+		{"user changes own role", owner, "PATCH", userPath("owner"), body(`{"usertype":"admin"}`), http.StatusForbidden},
+		{"user updates another user", owner, "PATCH", userPath("otherClient"), body(`{"name":"New name"}`), http.StatusForbidden},
+		{"admin changes a role", admin, "PATCH", userPath("otherClient"), body(`{"usertype":"serviceProvider"}`), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			f := newPolicyFixtures(t)
+			req := httptest.NewRequest(tt.method, tt.path(f), strings.NewReader(tt.body(f)))
+
+			// Act
+			rr := serveAs(tt.user(f), req)
+
+			// Assert
+			if rr.Code != tt.want {
+				t.Errorf("%s %s returned %v, want %v: %s", tt.method, tt.path(f), rr.Code, tt.want, rr.Body.String())
+			}
+			if rr.Code == http.StatusForbidden && !strings.Contains(rr.Body.String(), `"code":"forbidden"`) {
+				t.Errorf("Expected a JSON forbidden error, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAcceptBidAssignsJob(t *testing.T) {
+	// Arrange: a second bid placed through the bidding route
+	f := newPolicyFixtures(t)
+	rr := serveAs(f.otherProvider, httptest.NewRequest("POST", "/job/"+f.openJob.ID.Hex()+"/bids", strings.NewReader(`{"description":"Cheaper","bidAmount":400}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Placing the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var placed structure.Bid
+	if err := json.NewDecoder(rr.Body).Decode(&placed); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if placed.Status != structure.StatusPending || placed.JobID != f.openJob.ID || placed.SPID != f.otherProvider.ID {
+		t.Errorf("Expected a pending bid by the bidder on the job, got %+v", placed)
+	}
+
+	// Act
+	rr = serveAs(f.owner, httptest.NewRequest("POST", acceptPath(f), nil))
+
+	// Assert
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Accepting the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var job structure.Job
+	if err := database.Get("job", &job, f.openJob.ID.Hex()); err != nil {
+		t.Fatalf("Failed to get job document: %v", err)
+	}
+	if job.JobStatus != structure.JobStatusBidAccepted || job.AcceptedBid != f.bid.ID || job.ServiceProviders.ID != f.provider.ID {
+		t.Errorf("Expected the job to record the accepted bid, got %+v", job)
+	}
+	var rejected structure.Bid
+	if err := database.Get("bid", &rejected, placed.ID.Hex()); err != nil {
+		t.Fatalf("Failed to get bid document: %v", err)
+	}
+	if rejected.Status != structure.StatusRejected {
+		t.Errorf("Expected the other bid to be rejected, got %s", rejected.Status)
+	}
+
+	// A job accepts only one bid, and takes no more bids
+	rr = serveAs(f.owner, httptest.NewRequest("POST", acceptPath(f), nil))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %v accepting a second time, got %v", http.StatusConflict, rr.Code)
+	}
+	rr = serveAs(f.otherProvider, httptest.NewRequest("POST", "/job/"+f.openJob.ID.Hex()+"/bids", strings.NewReader(`{"description":"Too late","bidAmount":300}`)))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %v bidding on an assigned job, got %v", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestJobHistory(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	rr := serveAs(f.owner, httptest.NewRequest("POST", "/job", strings.NewReader(`{"title":"Paint the fence"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Creating the job returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var job structure.Job
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	jobPath := "/job/" + job.ID.Hex()
+
+	// Act
+	rr = serveAs(f.owner, httptest.NewRequest("PATCH", jobPath, strings.NewReader(`{"jobstatus":"job_cancelled","reason":"Did it myself"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Cancelling the job returned %v: %s", rr.Code, rr.Body.String())
+	}
+	rr = serveAs(nil, httptest.NewRequest("GET", jobPath+"/history", nil))
+
+	// Assert
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s/history returned %v: %s", jobPath, rr.Code, rr.Body.String())
+	}
+	var history []structure.JobTransition
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected the posting and the cancellation, got %+v", history)
+	}
+	if history[0].To != structure.JobStatusJobPosted || history[0].Actor != f.owner.ID || history[0].At.IsZero() {
+		t.Errorf("Expected the owner to have posted the job, got %+v", history[0])
+	}
+	want := structure.JobTransition{From: structure.JobStatusJobPosted, To: structure.JobStatusCancelled, Actor: f.owner.ID, At: history[1].At, Reason: "Did it myself"}
+	if history[1] != want || history[1].At.Before(history[0].At) {
+		t.Errorf("Expected the cancellation %+v, got %+v", want, history[1])
+	}
+	var entries []database.OutboxEntry
+	if err := database.Find(database.OutboxCollection, bson.M{"type": structure.EventJobStatusChanged}, &entries); err != nil {
+		t.Fatalf("Failed to find outbox entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected the cancellation in the outbox, got %+v", entries)
+	}
+	if event, err := entries[0].Event(); err != nil || event != (structure.JobStatusChanged{JobID: job.ID, JobTransition: want}) {
+		t.Errorf("Expected the cancellation in the outbox, got %+v (%v)", event, err)
+	}
+
+	// A cancelled job cannot be reopened
+	rr = serveAs(f.owner, httptest.NewRequest("PATCH", jobPath, strings.NewReader(`{"jobstatus":"job_posted"}`)))
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), `"code":"invalid_transition"`) {
+		t.Errorf("Expected a %v invalid_transition error reopening the job, got %v: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreatedDocumentsRecordTheirAuthor(t *testing.T) {
+	f := newPolicyFixtures(t)
+
+	rr := serveAs(f.otherProvider, httptest.NewRequest("POST", "/bid", strings.NewReader(jobBody("openJob", `"description":"Hire me","bidAmount":100,"serviceProviderId":"`+f.provider.ID.Hex()+`"`)(f))))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Creating the bid returned %v: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), f.otherProvider.ID.Hex()) {
+		t.Errorf("Expected the bid to be made by the requesting service provider, got %s", rr.Body.String())
+	}
+
+	rr = serveAs(f.owner, httptest.NewRequest("POST", "/review", strings.NewReader(jobBody("completedJob", `"review":"Well done"`)(f))))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Creating the review returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var review structure.Review
+	if err := json.NewDecoder(rr.Body).Decode(&review); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if review.ReviewerID != f.owner.ID || review.RevieweeID != f.provider.ID {
+		t.Errorf("Expected the review to be by the client about the service provider, got %+v", review)
+	}
+}
+
+func TestCreatedJobIgnoresBiddingFields(t *testing.T) {
+	f := newPolicyFixtures(t)
+	body := `{"title":"Paint the fence","status":"accepted","biddingClosed":true,` +
+		`"acceptedBid":"` + f.bid.ID.Hex() + `","serviceProviderId":"` + f.provider.ID.Hex() + `",` +
+		`"serviceProviders":{"skill":"Painting"},"bid":[{"description":"Hire me","bidAmount":100}]}`
+
+	rr := serveAs(f.owner, httptest.NewRequest("POST", "/job", strings.NewReader(body)))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Creating the job returned %v: %s", rr.Code, rr.Body.String())
+	}
+	var job structure.Job
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(job.Bid) != 0 || !job.AcceptedBid.IsZero() || !job.ServiceProviderID.IsZero() ||
+		job.ServiceProviders.Skill != "" || job.BiddingClosed || job.Status != "" {
+		t.Errorf("Expected the job to start without bids or a service provider, got %+v", job)
+	}
+	if job.JobStatus != structure.JobStatusJobPosted {
+		t.Errorf("Expected the job to be open for bids, got %s", job.JobStatus)
+	}
+}
+
+func TestBidChangesWhileBiddingIsOpen(t *testing.T) {
+	f := newPolicyFixtures(t)
+	decided := structure.Bid{Description: "I can do it", BidAmount: structure.Taka(500), JobID: f.openJob.ID, SPID: f.provider.ID, Status: structure.StatusRejected}
+	closed := structure.Bid{Description: "I can do it", BidAmount: structure.Taka(500), JobID: f.completedJob.ID, SPID: f.provider.ID, Status: structure.StatusPending}
+	for _, bid := range []*structure.Bid{&decided, &closed} {
+		if err := database.Create("bid", bid); err != nil {
+			t.Fatalf("Failed to insert bid document: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		bid  structure.Bid
+		code string
+	}{{"decided bid", decided, "bid_decided"}, {"bid on a closed job", closed, "bidding_closed"}} {
+		for _, method := range []string{"PATCH", "DELETE"} {
+			rr := serveAs(f.provider, httptest.NewRequest(method, "/bid/"+tc.bid.ID.Hex(), strings.NewReader(`{"description":"Cheaper"}`)))
+
+			if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), tc.code) {
+				t.Errorf("Expected a %v %s error for %s of a %s, got %v: %s", http.StatusConflict, tc.code, method, tc.name, rr.Code, rr.Body.String())
+			}
+		}
+	}
+}
+
+// Helpers that build the table's users, paths and bodies from the fixtures.
+
+func owner(f *policyFixtures) *structure.User       { return f.owner }
+func otherClient(f *policyFixtures) *structure.User { return f.otherClient }
+func provider(f *policyFixtures) *structure.User    { return f.provider }
+func otherProvider(f *policyFixtures) *structure.User {
+	return f.otherProvider
+}
+func admin(f *policyFixtures) *structure.User  { return f.admin }
+func nobody(f *policyFixtures) *structure.User { return nil }
+
+func path(p string) func(f *policyFixtures) string {
+	return func(f *policyFixtures) string { return p }
+}
+
+func body(b string) func(f *policyFixtures) string {
+	return func(f *policyFixtures) string { return b }
+}
+
+func (f *policyFixtures) job(name string) structure.Job {
+	return map[string]structure.Job{
+		"openJob":      f.openJob,
+		"completedJob": f.completedJob,
+		"bannedJob":    f.bannedJob,
+		"providerJob":  f.providerJob,
+	}[name]
+}
+
+func jobPath(name string, suffix string) func(f *policyFixtures) string {
+	return func(f *policyFixtures) string { return "/job/" + f.job(name).ID.Hex() + suffix }
+}
+
+// jobBody returns a JSON body naming the job, followed by fields.
+func jobBody(name string, fields string) func(f *policyFixtures) string {
+	return func(f *policyFixtures) string {
+		if fields != "" {
+			fields = "," + fields
+		}
+		return `{"jobId":"` + f.job(name).ID.Hex() + `"` + fields + `}`
+	}
+}
+
+func userPath(name string) func(f *policyFixtures) string {
+	return func(f *policyFixtures) string {
+		users := map[string]*structure.User{"owner": f.owner, "otherClient": f.otherClient}
+		return "/user/" + users[name].ID.Hex()
+	}
+}
+
+func acceptPath(f *policyFixtures) string {
+	return "/job/" + f.openJob.ID.Hex() + "/bids/" + f.bid.ID.Hex() + "/accept"
+}
+
+func rejectPath(f *policyFixtures) string {
+	return "/job/" + f.openJob.ID.Hex() + "/bids/" + f.bid.ID.Hex() + "/reject"
+}
+
+func bidPath(f *policyFixtures) string    { return "/bid/" + f.bid.ID.Hex() }
+func reviewPath(f *policyFixtures) string { return "/review/" + f.review.ID.Hex() }
@@ -1,8 +1,13 @@
 package handler
 
 import (
+    "errors"
     "net/http"
+    "Go-sumon/auth"
+    "Go-sumon/database"
 	"Go-sumon/structure"
+
+    "go.mongodb.org/mongo-driver/bson"
 )
 
 func GetAllReviewHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,7 +19,25 @@ func GetAllReviewHandler(w http.ResponseWriter, r *http.Request) {
 func CreateReviewHandler(w http.ResponseWriter, r *http.Request) {
    
     var review structure.Review
-    GenericCreateHandler(w, r, "review", &review)
+    createDocument(w, r, "review", &review, func(r *http.Request) error {
+        // The review is written by the client sending it, about the
+        // service provider who did the job
+        if user, ok := auth.UserFromContext(r.Context()); ok {
+            review.ReviewerID = user.ID
+        }
+        var job structure.Job
+        if err := db().Get(r.Context(), "job", review.JobID.Hex(), &job); err == nil {
+            review.RevieweeID = job.ServiceProviderID
+        } else if !errors.Is(err, database.ErrNotFound) {
+            return err
+        }
+        return nil
+    })
+}
+
+// createReview inserts a review, recording that it was written.
+func createReview(r *http.Request, document interface{}) error {
+    return db().CreateReview(r.Context(), document.(*structure.Review))
 }
 
 func GetReviewHandler(w http.ResponseWriter, r *http.Request) {
@@ -31,4 +54,11 @@ func DeleteReviewHandler(w http.ResponseWriter, r *http.Request) {
 
 func FindReviewHandler(w http.ResponseWriter, r *http.Request) {
     GenericFindHandler(w, r, "review")
-}
\ No newline at end of file
+}
+
+// MyReviewsHandler lists the reviews written by the authenticated client.
+func MyReviewsHandler(w http.ResponseWriter, r *http.Request) {
+    user, _ := auth.UserFromContext(r.Context())
+    var reviews []structure.Review
+    listDocuments(w, r, "review", bson.M{"reviewerid": user.ID}, &reviews)
+}
@@ -310,8 +310,8 @@ func TestFindReviewHandler(t *testing.T) {
 		{Review: "Second review", Timelines: 4.1, Quality: 4.3, Communication: 4.6, Behavior: 4.8},
 	}
 
-	for _, review := range testReviews {
-		if err := database.Create("review", &review); err != nil {
+	for i := range testReviews {
+		if err := database.Create("review", &testReviews[i]); err != nil {
 			t.Fatalf("Failed to insert test review: %v", err)
 		}
 	}
@@ -319,7 +319,7 @@ func TestFindReviewHandler(t *testing.T) {
 	// Create a mock HTTP request with query parameters
 	query := url.Values{}
 	query.Set("collection", "review")
-	query.Set("filter", `{"Review":"Second review"}`)
+	query.Set("filter", `{"review":"Second review"}`)
 	req, err := http.NewRequest("GET", "/find?"+query.Encode(), nil)
 	if err != nil {
 		t.Fatal("Failed to create request:", err)
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// legacyIDPlaceholder is the path segment that clients of the old literal
+// routes, such as /bid/{_id}/update, send in place of the document ID.
+const legacyIDPlaceholder = "{_id}"
+
+// resource holds the handlers behind one collection's routes.
+type resource struct {
+	path   string
+	list   http.HandlerFunc
+	create http.HandlerFunc
+	get    http.HandlerFunc
+	update http.HandlerFunc
+	delete http.HandlerFunc
+	find   http.HandlerFunc
+	legacy bool // also serve the deprecated routes
+	signup bool // anyone may create documents, to register an account
+
+	// Policies checked before reading, creating, updating and deleting
+	// documents. Reads are public without a readPolicy.
+	readPolicy   *Policy
+	createPolicy *Policy
+	updatePolicy *Policy
+	deletePolicy *Policy
+
+	actions []Route // further routes, such as accepting a bid on a job
+}
+
+// resources lists the collections served by RegisterRoutes.
+var resources = []resource{
+	{
+		path:   "/bid",
+		list:   GetAllBidHandler,
+		create: CreateBidHandler,
+		get:    GetBidHandler,
+		update: UpdateBidHandler,
+		delete: DeleteBidHandler,
+		find:   FindBidHandler,
+		legacy: true,
+
+		createPolicy: allOf(role(structure.UserTypeServiceProvider), bidsOnOthersJob),
+		updatePolicy: allOf(ownsBid, keeps("jobid", "serviceproviderid", "status"), bidIsOpen),
+		deletePolicy: anyOf(role(structure.UserTypeAdmin), allOf(ownsBid, bidIsOpen)),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/bid/mine", Protected: true, Policy: role(structure.UserTypeServiceProvider), handler: MyBidsHandler},
+		},
+	},
+	{
+		path:   "/review",
+		list:   GetAllReviewHandler,
+		create: CreateReviewHandler,
+		get:    GetReviewHandler,
+		update: UpdateReviewHandler,
+		delete: DeleteReviewHandler,
+		find:   FindReviewHandler,
+		legacy: true,
+
+		createPolicy: allOf(role(structure.UserTypeClient), reviewsCompletedJob),
+		updatePolicy: allOf(ownsReview, keeps("jobid", "reviewerid", "revieweeid")),
+		deletePolicy: anyOf(role(structure.UserTypeAdmin), ownsReview),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/review/mine", Protected: true, Policy: role(structure.UserTypeClient), handler: MyReviewsHandler},
+		},
+	},
+	{
+		path:   "/job",
+		list:   GetAllJobHandler,
+		create: CreateJobHandler,
+		get:    GetJobHandler,
+		update: UpdateJobHandler,
+		delete: DeleteJobHandler,
+		find:   FindJobHandler,
+
+		createPolicy: allOf(role(structure.UserTypeClient), notSetTo("jobstatus", string(structure.JobStatusBan))),
+		updatePolicy: allOf(role(structure.UserTypeClient), ownsJob, keeps("clients", "clientid", "serviceproviders", "serviceproviderid", "acceptedbid", "bid", "history", "biddingclosed", "auctionmode"), notSetTo("jobstatus", string(structure.JobStatusBan)), notSetTo("jobstatus", string(structure.JobStatusBidAccepted))),
+		deletePolicy: allOf(anyOf(role(structure.UserTypeAdmin), ownsJob), holdsNoEscrow),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/job/mine", Protected: true, Policy: role(structure.UserTypeClient), handler: MyJobsHandler},
+			{Method: http.MethodGet, Path: "/job/{id}/bids", handler: JobBidsHandler},
+			{Method: http.MethodGet, Path: "/job/{id}/reviews", handler: JobReviewsHandler},
+			{Method: http.MethodGet, Path: "/job/{id}/history", handler: JobHistoryHandler},
+			{Method: http.MethodGet, Path: "/job/{id}/events", handler: JobEventsHandler},
+			{Method: http.MethodGet, Path: "/job/{id}/ledger", Protected: true, Policy: anyOf(role(structure.UserTypeAdmin), partyToJob), handler: JobLedgerHandler},
+			{Method: http.MethodPost, Path: "/job/{id}/bids", Policy: allOf(role(structure.UserTypeServiceProvider), bidsOnOthersJob), handler: PlaceBidHandler},
+			{Method: http.MethodPost, Path: "/job/{id}/bids/{bidId}/accept", Policy: allOf(role(structure.UserTypeClient), ownsJob), handler: AcceptBidHandler},
+			{Method: http.MethodPost, Path: "/job/{id}/bids/{bidId}/reject", Policy: allOf(role(structure.UserTypeClient), ownsJob), handler: RejectBidHandler},
+			{Method: http.MethodPost, Path: "/job/{id}/ban", Policy: role(structure.UserTypeAdmin), handler: BanJobHandler},
+		},
+	},
+	{
+		path:   "/user",
+		list:   GetAllUserHandler,
+		create: CreateUserHandler,
+		get:    GetUserHandler,
+		update: UpdateUserHandler,
+		delete: DeleteUserHandler,
+		find:   FindUserHandler,
+		signup: true,
+
+		createPolicy: notSetTo("usertype", string(structure.UserTypeAdmin)),
+		updatePolicy: anyOf(role(structure.UserTypeAdmin), allOf(self, keeps("usertype"))),
+		deletePolicy: anyOf(role(structure.UserTypeAdmin), self),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/user/{id}/events", Protected: true, Policy: self, handler: UserEventsHandler},
+			{Method: http.MethodGet, Path: "/user/{id}/notifications", Protected: true, Policy: self, handler: UserNotificationsHandler},
+			{Method: http.MethodPost, Path: "/user/{id}/notifications/read", Policy: self, handler: ReadAllNotificationsHandler},
+			{Method: http.MethodPost, Path: "/user/{id}/notifications/{notificationId}/read", Policy: self, handler: ReadNotificationHandler},
+			{Method: http.MethodPost, Path: "/user/{id}/notifications/{notificationId}/unread", Policy: self, handler: UnreadNotificationHandler},
+			{Method: http.MethodGet, Path: "/user/{id}/notificationPreferences", Protected: true, Policy: self, handler: GetNotificationPreferencesHandler},
+			{Method: http.MethodPut, Path: "/user/{id}/notificationPreferences", Policy: self, handler: PutNotificationPreferencesHandler},
+		},
+	},
+	{
+		path:   "/client",
+		list:   GetAllClientHandler,
+		create: CreateClientHandler,
+		get:    GetClientHandler,
+		update: UpdateClientHandler,
+		delete: DeleteClientHandler,
+		find:   FindClientHandler,
+		signup: true,
+
+		createPolicy: notSetTo("user.usertype", string(structure.UserTypeAdmin)),
+		updatePolicy: anyOf(role(structure.UserTypeAdmin), allOf(self, keeps("user.usertype"))),
+		deletePolicy: anyOf(role(structure.UserTypeAdmin), self),
+	},
+	{
+		path:   "/serviceProvider",
+		list:   GetAllSPHandler,
+		create: CreateSPHandler,
+		get:    GetSPHandler,
+		update: UpdateSPHandler,
+		delete: DeleteSPHandler,
+		find:   FindSPHandler,
+		signup: true,
+
+		createPolicy: notSetTo("user.usertype", string(structure.UserTypeAdmin)),
+		updatePolicy: allOf(anyOf(role(structure.UserTypeAdmin), allOf(self, keeps("user.usertype"))), keeps("spbalance")),
+		deletePolicy: anyOf(role(structure.UserTypeAdmin), self),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/serviceProvider/{id}/reviews", handler: SPReviewsHandler},
+			{Method: http.MethodGet, Path: "/serviceProvider/{id}/balance", Protected: true, Policy: anyOf(role(structure.UserTypeAdmin), self), handler: SPBalanceHandler},
+		},
+	},
+	{
+		path:   "/webhook",
+		list:   GetAllWebhookHandler,
+		create: CreateWebhookHandler,
+		get:    GetWebhookHandler,
+		update: UpdateWebhookHandler,
+		delete: DeleteWebhookHandler,
+		find:   FindWebhookHandler,
+
+		readPolicy:   role(structure.UserTypeAdmin),
+		createPolicy: role(structure.UserTypeAdmin),
+		updatePolicy: allOf(role(structure.UserTypeAdmin), keeps("failures", "createdby")),
+		deletePolicy: role(structure.UserTypeAdmin),
+		actions: []Route{
+			{Method: http.MethodGet, Path: "/webhook/{id}/deliveries", Protected: true, Policy: role(structure.UserTypeAdmin), handler: WebhookDeliveriesHandler},
+			{Method: http.MethodPost, Path: "/webhook/{id}/deliveries/{deliveryId}/replay", Policy: role(structure.UserTypeAdmin), handler: ReplayDeliveryHandler},
+		},
+	},
+}
+
+// Route is one entry of the route table.
+type Route struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Deprecated bool    `json:"deprecated,omitempty"`
+	Protected  bool    `json:"protected,omitempty"` // requires a bearer access token
+	Policy     *Policy `json:"policy,omitempty"`    // who may make the request
+
+	handler http.HandlerFunc
+}
+
+// pattern returns the ServeMux pattern of the route.
+func (route Route) pattern() string {
+	return route.Method + " " + route.Path
+}
+
+// routes returns the routes of the resource:
+//
+//	GET    /bid          list bids
+//	POST   /bid          create a bid
+//	GET    /bid/find     find bids matching ?filter=
+//	GET    /bid/{id}     get a bid
+//	PATCH  /bid/{id}     update a bid
+//	DELETE /bid/{id}     delete a bid
+//
+// Legacy resources also keep the deprecated routes that pass the ID as ?id=,
+// or live under the old /create, /update, /delete and /find paths. Every
+// route that writes is protected, except creating a document of a signup
+// resource, and so are the reads of a resource with a readPolicy.
+func (res resource) routes() []Route {
+	routes := []Route{
+		{Method: http.MethodGet, Path: res.path, Protected: res.readPolicy != nil, Policy: res.readPolicy, handler: res.list},
+		{Method: http.MethodPost, Path: res.path, Policy: res.createPolicy, handler: res.create},
+		{Method: http.MethodGet, Path: res.path + "/find", Protected: res.readPolicy != nil, Policy: res.readPolicy, handler: res.find},
+		{Method: http.MethodGet, Path: res.path + "/{id}", Protected: res.readPolicy != nil, Policy: res.readPolicy, handler: res.get},
+		{Method: http.MethodPatch, Path: res.path + "/{id}", Policy: res.updatePolicy, handler: res.update},
+		{Method: http.MethodDelete, Path: res.path + "/{id}", Policy: res.deletePolicy, handler: res.delete},
+	}
+	if res.legacy {
+		routes[0].handler = res.listOrGet
+		routes = append(routes,
+			Route{Method: http.MethodPut, Path: res.path, Deprecated: true, Policy: res.updatePolicy, handler: res.update},
+			Route{Method: http.MethodDelete, Path: res.path, Deprecated: true, Policy: res.deletePolicy, handler: res.delete},
+			Route{Method: http.MethodPost, Path: res.path + "/create", Deprecated: true, Policy: res.createPolicy, handler: deprecated(res.create)},
+			Route{Method: http.MethodPut, Path: res.path + "/{id}/update", Deprecated: true, Policy: res.updatePolicy, handler: deprecated(res.update)},
+			Route{Method: http.MethodPatch, Path: res.path + "/{id}/update", Deprecated: true, Policy: res.updatePolicy, handler: deprecated(res.update)},
+			Route{Method: http.MethodPost, Path: res.path + "/{id}/update", Deprecated: true, Policy: res.updatePolicy, handler: deprecated(res.update)},
+			Route{Method: http.MethodDelete, Path: res.path + "/{id}/delete", Deprecated: true, Policy: res.deletePolicy, handler: deprecated(res.delete)},
+			Route{Method: http.MethodGet, Path: res.path + "/{id}/find", Deprecated: true, handler: deprecated(res.find)},
+		)
+	}
+	routes = append(routes, res.actions...)
+
+	for i := range routes {
+		signup := res.signup && routes[i].Method == http.MethodPost && routes[i].Path == res.path
+		routes[i].Protected = routes[i].Protected || (routes[i].Method != http.MethodGet && !signup)
+	}
+	return routes
+}
+
+// Routes returns the route table registered by RegisterRoutes.
+func Routes() []Route {
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/_routes", handler: RoutesHandler},
+		{Method: http.MethodPost, Path: "/auth/otp", handler: authConfigured(RequestCodeHandler)},
+		{Method: http.MethodPost, Path: "/auth/verify", handler: authConfigured(VerifyCodeHandler)},
+		{Method: http.MethodPost, Path: "/auth/refresh", handler: authConfigured(RefreshHandler)},
+		{Method: http.MethodPost, Path: "/auth/logout", Protected: true, handler: LogoutHandler},
+		{Method: http.MethodGet, Path: "/auth/me", Protected: true, handler: MeHandler},
+	}
+	for _, res := range resources {
+		routes = append(routes, res.routes()...)
+	}
+	return routes
+}
+
+// RegisterRoutes registers every route of the route table on mux. The mux
+// answers other methods on these paths with 405 and an Allow header.
+// Protected routes authenticate the request before checking its policy;
+// public routes identify the user of a valid access token, if one is sent.
+func RegisterRoutes(mux *http.ServeMux) {
+	for _, route := range Routes() {
+		handler := route.handler
+		if route.Policy != nil {
+			handler = authorize(route.Policy, handler)
+		}
+		if route.Protected {
+			handler = requireUser(handler)
+		} else {
+			handler = identifyUser(handler)
+		}
+		mux.HandleFunc(route.pattern(), handler)
+	}
+}
+
+// RoutesHandler lists the route table as JSON.
+func RoutesHandler(w http.ResponseWriter, r *http.Request) {
+	responseBody, err := json.Marshal(Routes())
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// listOrGet lists the collection, or gets one document when the deprecated
+// ?id= query parameter is present.
+func (res resource) listOrGet(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("id") {
+		res.get(w, r)
+		return
+	}
+	res.list(w, r)
+}
+
+// deprecated marks the responses of a deprecated route.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next(w, r)
+	}
+}
+
+// requestID returns the document ID from the {id} path segment. During the
+// deprecation period it falls back to the ?id= query parameter, marking the
+// response as deprecated.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := pathID(r)
+	if id != "" && id != r.PathValue("id") {
+		w.Header().Set("Deprecation", "true")
+	}
+	return id
+}
+
+// pathObjectID returns the {id} path segment as an ObjectID, responding 400
+// if it is not one.
+func pathObjectID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// pathID returns the document ID of the request like requestID, without
+// marking the response.
+func pathID(r *http.Request) string {
+	if id := r.PathValue("id"); id != "" && id != legacyIDPlaceholder {
+		return id
+	}
+	return r.URL.Query().Get("id")
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+func TestRoutesPathParameter(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	bidder, tokens := loginForTest(t, mux, structure.UserTypeServiceProvider)
+	database.ClearCollection("bid")
+	bid := structure.Bid{Description: "Routed bid", BidAmount: structure.Taka(100), SPID: bidder.ID}
+	if err := database.Create("bid", &bid); err != nil {
+		t.Fatalf("Failed to insert bid document: %v", err)
+	}
+
+	// Act & Assert: get, update and delete the bid by its path ID
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/bid/"+bid.ID.Hex(), nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Routed bid") {
+		t.Errorf("GET /bid/{id} returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, authorized(httptest.NewRequest("PATCH", "/bid/"+bid.ID.Hex(), strings.NewReader(`{"description":"Patched bid"}`)), tokens))
+	if rr.Code != http.StatusOK {
+		t.Errorf("PATCH /bid/{id} returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, authorized(httptest.NewRequest("DELETE", "/bid/"+bid.ID.Hex(), nil), tokens))
+	if rr.Code != http.StatusOK {
+		t.Errorf("DELETE /bid/{id} returned %v: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "" {
+		t.Error("Expected the path route not to be marked deprecated")
+	}
+}
+
+func TestRoutesMethodNotAllowed(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/bid/0123456789abcdef01234567", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status %v, got %v", http.StatusMethodNotAllowed, rr.Code)
+	}
+	allow := rr.Header().Get("Allow")
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		if !strings.Contains(allow, method) {
+			t.Errorf("Expected Allow header %q to include %s", allow, method)
+		}
+	}
+}
+
+func TestRoutesLegacyQueryID(t *testing.T) {
+	// Arrange
+	database.ClearCollection("review")
+	review := structure.Review{Review: "Legacy review", Quality: 4}
+	if err := database.Create("review", &review); err != nil {
+		t.Fatalf("Failed to insert review document: %v", err)
+	}
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+
+	// Act: the old literal route with the ID in the query string
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/review/{_id}?id="+review.ID.Hex(), nil))
+
+	// Assert
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Legacy review") {
+		t.Errorf("Legacy GET returned %v: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "true" {
+		t.Error("Expected the legacy route to be marked deprecated")
+	}
+}
+
+func TestRoutesTable(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/_routes", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /_routes returned %v", rr.Code)
+	}
+	var routes []Route
+	if err := json.NewDecoder(rr.Body).Decode(&routes); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	listed := map[string]bool{}
+	for _, route := range routes {
+		listed[route.pattern()] = true
+	}
+	for _, pattern := range []string{"GET /job", "POST /user", "PATCH /client/{id}", "DELETE /serviceProvider/{id}"} {
+		if !listed[pattern] {
+			t.Errorf("Expected route %s to be listed", pattern)
+		}
+	}
+}
+
+func TestRoutesListJobs(t *testing.T) {
+	// Arrange
+	database.ClearCollection("job")
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted}
+	if err := database.Create("job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+
+	// Act
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/job", nil))
+
+	// Assert
+	var jobs []structure.Job
+	if err := json.NewDecoder(rr.Body).Decode(&jobs); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Title != "Fix the roof" {
+		t.Errorf("Unexpected jobs: %+v", jobs)
+	}
+}
+
+func TestRoutesOwnerListings(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  func(f *policyFixtures) *structure.User
+		path  func(f *policyFixtures) string
+		count int
+	}{
+		{"my bids", provider, path("/bid/mine"), 1},
+		{"another provider's bids", otherProvider, path("/bid/mine"), 0},
+		{"my jobs", owner, path("/job/mine"), 3},
+		{"my reviews", owner, path("/review/mine"), 1},
+		{"bids on a job", nobody, jobPath("openJob", "/bids"), 1},
+		{"reviews of a job", nobody, jobPath("completedJob", "/reviews"), 1},
+		{"reviews about a service provider", nobody, func(f *policyFixtures) string { return "/serviceProvider/" + f.provider.ID.Hex() + "/reviews" }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			f := newPolicyFixtures(t)
+
+			// Act
+			rr := serveAs(tt.user(f), httptest.NewRequest("GET", tt.path(f), nil))
+
+			// Assert
+			if rr.Code != http.StatusOK {
+				t.Fatalf("GET %s returned %v: %s", tt.path(f), rr.Code, rr.Body.String())
+			}
+			var items []map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&items); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if len(items) != tt.count {
+				t.Errorf("Expected %d items, got %d: %v", tt.count, len(items), items)
+			}
+		})
+	}
+}
+
+func TestRoutesListingRejectsInvalidID(t *testing.T) {
+	rr := serveAs(nil, httptest.NewRequest("GET", "/job/not-an-id/bids", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
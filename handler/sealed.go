@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Bids on a sealed job keep their amount and description from everyone but
+// their bidder until bidding on the job closes. The handlers that respond
+// with bids and jobs hide them with sealResult, and keep queries on them
+// from matching with sealFilter. Once the auction scheduler marks bidding
+// closed, or the job leaves job_posted, the bids are shown again, so they
+// are revealed without a write. Passing the deadline alone reveals nothing:
+// bids placed before it may still be in flight until bidding is closed.
+
+// sealedFields reports whether a query key of the collection refers to the
+// sealed fields of bids.
+var sealedFields = map[string]func(key string) bool{
+	"bid": func(key string) bool { return key == "bidamount" || key == "description" },
+	"job": func(key string) bool { return key == "bid" || strings.HasPrefix(key, "bid.") },
+}
+
+// sealedJobs returns the IDs of the sealed jobs whose bidding has not been
+// closed.
+func sealedJobs(r *http.Request) ([]primitive.ObjectID, error) {
+	filter := bson.M{
+		"auctionmode":   structure.AuctionModeSealed,
+		"jobstatus":     structure.JobStatusJobPosted,
+		"biddingclosed": bson.M{"$ne": true},
+	}
+	var jobs []structure.Job
+	if _, err := db().FindPage(r.Context(), "job", filter, database.FindOptions{Fields: []string{"_id"}}, &jobs); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids, nil
+}
+
+// sealFilter keeps a query that filters or sorts the collection by the
+// sealed fields of bids from matching the sealed bids, or jobs, the
+// requesting user may not see, so the query reveals nothing about them.
+func sealFilter(r *http.Request, collectionName string, filter interface{}, opts database.FindOptions) (interface{}, error) {
+	sealed, ok := sealedFields[collectionName]
+	if !ok || (!mentions(filter, sealed) && !mentions(opts.Sort, sealed)) {
+		return filter, nil
+	}
+	jobs, err := sealedJobs(r)
+	if err != nil || len(jobs) == 0 {
+		return filter, err
+	}
+
+	hidden := bson.M{"_id": bson.M{"$in": jobs}}
+	if collectionName == "bid" {
+		hidden = bson.M{"jobid": bson.M{"$in": jobs}, "serviceproviderid": bson.M{"$ne": actorID(r)}}
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, bson.M{"$nor": bson.A{hidden}}}}}, nil
+}
+
+// mentions reports whether any key of the query, at any depth, is one that
+// sealed reports.
+func mentions(query interface{}, sealed func(key string) bool) bool {
+	switch query := query.(type) {
+	case map[string]interface{}:
+		return mentions(bson.M(query), sealed)
+	case bson.M:
+		for key, value := range query {
+			if sealed(strings.ToLower(key)) || mentions(value, sealed) {
+				return true
+			}
+		}
+	case bson.D:
+		for _, e := range query {
+			if sealed(strings.ToLower(e.Key)) || mentions(e.Value, sealed) {
+				return true
+			}
+		}
+	case []interface{}:
+		return mentions(bson.A(query), sealed)
+	case bson.A:
+		for _, value := range query {
+			if mentions(value, sealed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sealResult hides the amount and description of the sealed bids in result
+// that the requesting user did not make. result holds the documents of the
+// collection a handler is about to respond with: a pointer to a slice of
+// bids, jobs or bson.M, or to a single decoded document.
+func sealResult(r *http.Request, collectionName string, result interface{}) error {
+	if _, ok := sealedFields[collectionName]; !ok {
+		return nil
+	}
+	jobs, err := sealedJobs(r)
+	if err != nil || len(jobs) == 0 {
+		return err
+	}
+	viewer := actorID(r)
+
+	if collectionName == "job" {
+		sealed := make(map[primitive.ObjectID]bool, len(jobs))
+		for _, id := range jobs {
+			sealed[id] = true
+		}
+		eachDocument(result, func(doc interface{}) interface{} {
+			if !sealed[documentID(doc)] {
+				return doc
+			}
+			return sealJobBids(doc, viewer)
+		})
+		return nil
+	}
+
+	// Look the bids up again: a projection may have left out their job and
+	// bidder
+	var ids []primitive.ObjectID
+	eachDocument(result, func(doc interface{}) interface{} {
+		ids = append(ids, documentID(doc))
+		return doc
+	})
+	if len(ids) == 0 {
+		return nil
+	}
+	filter := bson.M{"_id": bson.M{"$in": ids}, "jobid": bson.M{"$in": jobs}, "serviceproviderid": bson.M{"$ne": viewer}}
+	var bids []structure.Bid
+	if _, err := db().FindPage(r.Context(), "bid", filter, database.FindOptions{Fields: []string{"_id"}}, &bids); err != nil {
+		return err
+	}
+	hidden := make(map[primitive.ObjectID]bool, len(bids))
+	for _, bid := range bids {
+		hidden[bid.ID] = true
+	}
+	eachDocument(result, func(doc interface{}) interface{} {
+		if !hidden[documentID(doc)] {
+			return doc
+		}
+		return sealBid(doc)
+	})
+	return nil
+}
+
+// eachDocument replaces each document in result with what seal returns for
+// it. seal is given a *structure.Bid, a *structure.Job, a bson.M or a
+// bson.D.
+func eachDocument(result interface{}, seal func(doc interface{}) interface{}) {
+	switch result := result.(type) {
+	case *[]structure.Bid:
+		for i := range *result {
+			seal(&(*result)[i])
+		}
+	case *[]structure.Job:
+		for i := range *result {
+			seal(&(*result)[i])
+		}
+	case *[]bson.M:
+		for i := range *result {
+			(*result)[i] = seal((*result)[i]).(bson.M)
+		}
+	case *interface{}:
+		switch (*result).(type) {
+		case bson.M, bson.D:
+			*result = seal(*result)
+		}
+	}
+}
+
+// documentID returns the ID of a document given to an eachDocument callback.
+func documentID(doc interface{}) primitive.ObjectID {
+	switch doc := doc.(type) {
+	case *structure.Bid:
+		return doc.ID
+	case *structure.Job:
+		return doc.ID
+	}
+	id, _ := field(doc, "_id").(primitive.ObjectID)
+	return id
+}
+
+// sealBid hides the amount and description of a bid and marks it sealed.
+func sealBid(doc interface{}) interface{} {
+	if bid, ok := doc.(*structure.Bid); ok {
+		bid.BidAmount = 0
+		bid.Description = ""
+		bid.Sealed = true
+		return bid
+	}
+	if field(doc, "bidamount") != nil {
+		doc = setField(doc, "bidamount", int64(0))
+	}
+	if field(doc, "description") != nil {
+		doc = setField(doc, "description", "")
+	}
+	return setField(doc, "sealed", true)
+}
+
+// sealJobBids seals the copies of the bids a job holds, except those viewer
+// made.
+func sealJobBids(doc interface{}, viewer primitive.ObjectID) interface{} {
+	if job, ok := doc.(*structure.Job); ok {
+		for i := range job.Bid {
+			if job.Bid[i].SPID != viewer || viewer.IsZero() {
+				sealBid(&job.Bid[i])
+			}
+		}
+		return job
+	}
+
+	bids, _ := field(doc, "bid").(bson.A)
+	for i, bid := range bids {
+		if bidder, _ := field(bid, "serviceproviderid").(primitive.ObjectID); bidder != viewer || viewer.IsZero() {
+			bids[i] = sealBid(bid)
+		}
+	}
+	return doc
+}
+
+// field returns the value of key in a bson.M or bson.D document, or nil.
+func field(doc interface{}, key string) interface{} {
+	switch doc := doc.(type) {
+	case bson.M:
+		return doc[key]
+	case bson.D:
+		for _, e := range doc {
+			if e.Key == key {
+				return e.Value
+			}
+		}
+	}
+	return nil
+}
+
+// setField sets key in a bson.M or bson.D document and returns the document.
+func setField(doc interface{}, key string, value interface{}) interface{} {
+	switch d := doc.(type) {
+	case bson.M:
+		d[key] = value
+	case bson.D:
+		for i := range d {
+			if d[i].Key == key {
+				d[i].Value = value
+				return d
+			}
+		}
+		return append(d, bson.E{Key: key, Value: value})
+	}
+	return doc
+}
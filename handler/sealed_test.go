@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+)
+
+// newSealedJob inserts a sealed job of the fixtures' owner, with bids by the
+// provider and the other provider.
+func newSealedJob(t *testing.T, f *policyFixtures) (structure.Job, structure.Bid, structure.Bid) {
+	t.Helper()
+	job := structure.Job{Title: "Sealed job", ClientID: f.owner.ID, JobStatus: structure.JobStatusJobPosted, AuctionMode: structure.AuctionModeSealed, BiddingDeadline: time.Now().Add(time.Hour)}
+	if err := database.Create("job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	mine := structure.Bid{Description: "Mine", BidAmount: structure.Taka(300), SPID: f.provider.ID}
+	theirs := structure.Bid{Description: "Theirs", BidAmount: structure.Taka(200), SPID: f.otherProvider.ID}
+	for _, bid := range []*structure.Bid{&mine, &theirs} {
+		if err := database.PlaceBid(job.ID.Hex(), bid); err != nil {
+			t.Fatalf("Failed to place bid: %v", err)
+		}
+	}
+	return job, mine, theirs
+}
+
+// getBids serves a GET request as user and decodes the bids it returns.
+func getBids(t *testing.T, user *structure.User, target string) []structure.Bid {
+	t.Helper()
+	rr := serveAs(user, httptest.NewRequest("GET", target, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %v: %s", target, rr.Code, rr.Body.String())
+	}
+	var bids []structure.Bid
+	if err := json.NewDecoder(rr.Body).Decode(&bids); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return bids
+}
+
+func TestSealedBidsHiddenUntilBiddingCloses(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	job, mine, theirs := newSealedJob(t, f)
+	bidsPath := "/job/" + job.ID.Hex() + "/bids"
+
+	// Act
+	bids := getBids(t, nil, bidsPath)
+
+	// Assert
+	if len(bids) != 2 {
+		t.Fatalf("Expected 2 bids, got %+v", bids)
+	}
+	for _, bid := range bids {
+		if !bid.Sealed || bid.BidAmount != 0 || bid.Description != "" {
+			t.Errorf("Expected the bid to be sealed, got %+v", bid)
+		}
+	}
+
+	// The bidder sees their own bid only
+	for _, bid := range getBids(t, f.provider, bidsPath) {
+		if bid.Sealed != (bid.ID != mine.ID) {
+			t.Errorf("Expected the bidder to see only their own bid, got %+v", bid)
+		}
+	}
+	rr := serveAs(nil, httptest.NewRequest("GET", "/job/"+job.ID.Hex(), nil))
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), theirs.Description) {
+		t.Errorf("Expected the job's copies of the bids to be sealed, got %v: %s", rr.Code, rr.Body.String())
+	}
+	rr = serveAs(nil, httptest.NewRequest("GET", "/bid/"+theirs.ID.Hex(), nil))
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), theirs.Description) {
+		t.Errorf("Expected the bid to be sealed, got %v: %s", rr.Code, rr.Body.String())
+	}
+
+	// Closing the bidding reveals the bids
+	if err := database.Update("job", job.ID.Hex(), map[string]interface{}{"biddingclosed": true}); err != nil {
+		t.Fatalf("Failed to update job document: %v", err)
+	}
+	for _, bid := range getBids(t, nil, bidsPath) {
+		if bid.Sealed || bid.BidAmount == 0 || bid.Description == "" {
+			t.Errorf("Expected the bid to be revealed, got %+v", bid)
+		}
+	}
+}
+
+func TestSealedBidsHiddenPastDeadlineUntilClosed(t *testing.T) {
+	// Arrange: the deadline has passed but the scheduler has not closed
+	// bidding yet
+	f := newPolicyFixtures(t)
+	job, _, _ := newSealedJob(t, f)
+	if err := database.Update("job", job.ID.Hex(), map[string]interface{}{"biddingdeadline": time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("Failed to update job document: %v", err)
+	}
+
+	// Act
+	bids := getBids(t, nil, "/job/"+job.ID.Hex()+"/bids")
+
+	// Assert
+	for _, bid := range bids {
+		if !bid.Sealed || bid.BidAmount != 0 {
+			t.Errorf("Expected the bid to stay sealed until bidding is closed, got %+v", bid)
+		}
+	}
+}
+
+func TestSealedBidsDoNotMatchQueries(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	_, mine, _ := newSealedJob(t, f)
+	query := url.Values{"filter": {`{"bidamount":{"$lt":100000}}`}} // stored in paisa
+
+	// Act
+	bids := getBids(t, f.provider, "/bid/find?"+query.Encode())
+
+	// Assert: the fixtures' open bid and the provider's own sealed bid
+	ids := map[string]bool{}
+	for _, bid := range bids {
+		ids[bid.ID.Hex()] = true
+	}
+	if len(bids) != 2 || !ids[f.bid.ID.Hex()] || !ids[mine.ID.Hex()] {
+		t.Errorf("Expected the query to skip the other sealed bid, got %+v", bids)
+	}
+	bids = getBids(t, nil, "/job/"+mine.JobID.Hex()+"/bids?sort=bidAmount")
+	if len(bids) != 0 {
+		t.Errorf("Expected sorting by amount to skip the sealed bids, got %+v", bids)
+	}
+}
+
+func TestSealedJobRequiresDeadline(t *testing.T) {
+	f := newPolicyFixtures(t)
+
+	rr := serveAs(f.owner, httptest.NewRequest("POST", "/job", strings.NewReader(`{"title":"Paint the fence","auctionMode":"sealed"}`)))
+
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "biddingDeadline") {
+		t.Errorf("Expected a biddingDeadline validation error, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
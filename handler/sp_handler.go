@@ -3,15 +3,17 @@ package handler
 import (
     "net/http"
 	"Go-sumon/structure"
+
+    "go.mongodb.org/mongo-driver/bson"
 )
 
 func GetAllSPHandler(w http.ResponseWriter, r *http.Request) {
-    var users []structure.Client
-    GenericGetAllHandler(w, r, "serviceProvider", &users)
+    var serviceProviders []structure.ServiceProvider
+    GenericGetAllHandler(w, r, "serviceProvider", &serviceProviders)
 }
 
 func CreateSPHandler(w http.ResponseWriter, r *http.Request) {
-    SPCreateHandler(w, r, "user", "serviceProvider")
+    SPCreateHandler(w, r, "serviceProvider")
 }
 
 func GetSPHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,4 +30,14 @@ func DeleteSPHandler(w http.ResponseWriter, r *http.Request) {
 
 func FindSPHandler(w http.ResponseWriter, r *http.Request) {
     GenericFindHandler(w, r, "serviceProvider")
-}
\ No newline at end of file
+}
+
+// SPReviewsHandler lists the reviews about a service provider.
+func SPReviewsHandler(w http.ResponseWriter, r *http.Request) {
+    spID, ok := pathObjectID(w, r)
+    if !ok {
+        return
+    }
+    var reviews []structure.Review
+    listDocuments(w, r, "review", bson.M{"revieweeid": spID}, &reviews)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// errInvalidUpdate is returned by validateUpdate when the updated document no
+// longer decodes into its collection's type.
+var errInvalidUpdate = errors.New("update does not match the document type")
+
+// documentTypes returns an empty document for each collection whose writes
+// are validated.
+var documentTypes = map[string]func() structure.Validator{
+	"user":            func() structure.Validator { return &structure.User{} },
+	"client":          func() structure.Validator { return &structure.Client{} },
+	"serviceProvider": func() structure.Validator { return &structure.ServiceProvider{} },
+	"bid":             func() structure.Validator { return &structure.Bid{} },
+	"review":          func() structure.Validator { return &structure.Review{} },
+	"job":             func() structure.Validator { return &structure.Job{} },
+	"point":           func() structure.Validator { return &structure.Point{} },
+	"payment":         func() structure.Validator { return &structure.Payment{} },
+}
+
+// writeValidationError responds with 422 Unprocessable Entity and the
+// failing fields if err is a *structure.ValidationError. It reports whether
+// a response was written.
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	var validationErr *structure.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	responseBody, err := json.Marshal(validationErr)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(responseBody)
+	return true
+}
+
+// validateUpdate applies updateData to a copy of the stored document and
+// validates the result, so that partial updates are checked against the
+// whole document they produce.
+func validateUpdate(ctx context.Context, collectionName string, id string, updateData bson.M) error {
+	newDocument, ok := documentTypes[collectionName]
+	if !ok {
+		return nil
+	}
+
+	var stored bson.M
+	if err := db().Get(ctx, collectionName, id, &stored); err != nil {
+		return err
+	}
+	for key, value := range updateData {
+		setPath(stored, key, value)
+	}
+
+	// Round-trip through BSON to decode the stored field names
+	raw, err := bson.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidUpdate, err)
+	}
+	document := newDocument()
+	if err := bson.Unmarshal(raw, document); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidUpdate, err)
+	}
+	return document.Validate()
+}
+
+// setPath sets the dotted path in doc to value, as $set does, creating
+// intermediate documents where needed.
+func setPath(doc bson.M, path string, value interface{}) {
+	key, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[key] = value
+		return
+	}
+
+	switch child := doc[key].(type) {
+	case bson.M:
+		setPath(child, rest, value)
+	case map[string]interface{}:
+		setPath(child, rest, value)
+	default:
+		created := bson.M{}
+		doc[key] = created
+		setPath(created, rest, value)
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func GetAllWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var webhooks []structure.Webhook
+	GenericGetAllHandler(w, r, "webhook", &webhooks)
+}
+
+// CreateWebhookHandler subscribes a partner endpoint to events. The webhook
+// starts enabled, and its secret is never returned.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var webhook structure.Webhook
+	createDocument(w, r, "webhook", &webhook, func(r *http.Request) error {
+		webhook.Disabled = false
+		webhook.Failures = 0
+		webhook.CreatedBy = actorID(r)
+		return nil
+	})
+}
+
+func GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	GenericGetHandler(w, r, "webhook")
+}
+
+func UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	GenericUpdateHandler(w, r, "webhook")
+}
+
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	GenericDeleteHandler(w, r, "webhook")
+}
+
+func FindWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	GenericFindHandler(w, r, "webhook")
+}
+
+// prepareWebhookUpdate gives a webhook that is enabled again a clean record
+// of failures, so that it is not disabled by its next failed delivery.
+func prepareWebhookUpdate(r *http.Request, id string, update bson.M) error {
+	for key, value := range update {
+		if strings.ToLower(key) == "disabled" && value == false {
+			update["failures"] = 0
+		}
+	}
+	return nil
+}
+
+// WebhookDeliveriesHandler lists the delivery log of a webhook.
+func WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var deliveries []structure.WebhookDelivery
+	listDocuments(w, r, "webhookDelivery", bson.M{"webhookid": webhookID}, &deliveries)
+}
+
+// ReplayDeliveryHandler sends a delivery of a webhook again, as a new
+// pending delivery of the same event that is sent on the next look for
+// deliveries, and responds with it. Replaying to a disabled webhook answers
+// 409 Conflict.
+func ReplayDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	var webhook structure.Webhook
+	if err := getTarget(r, "webhook", r.PathValue("id"), &webhook); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	var original structure.WebhookDelivery
+	err := db().Get(r.Context(), "webhookDelivery", r.PathValue("deliveryId"), &original)
+	if err == nil && original.WebhookID != webhook.ID {
+		err = database.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "No such delivery", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get delivery", databaseErrorStatus(err))
+		return
+	}
+	if webhook.Disabled {
+		writePolicyError(w, &policyError{Status: http.StatusConflict, Code: "webhook_disabled", Message: "the webhook is disabled; enable it before replaying"})
+		return
+	}
+
+	now := time.Now().UTC()
+	replay := structure.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Body:          original.Body,
+		Status:        structure.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		ReplayOf:      original.ID,
+	}
+	if err := db().Create(r.Context(), "webhookDelivery", &replay); err != nil {
+		http.Error(w, "Failed to replay delivery", databaseErrorStatus(err))
+		return
+	}
+	responseBody, err := json.Marshal(replay)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const webhookBody = `{"url":"https://partner.example/hooks","events":["bid_placed","review_created"],"secret":"0123456789abcdef","failures":3}`
+
+func TestWebhookHandlers(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	database.ClearCollection("webhook")
+
+	// Act: only admins manage webhooks
+	denied := serveAs(f.owner, httptest.NewRequest("POST", "/webhook", strings.NewReader(webhookBody)))
+	invalid := serveAs(f.admin, httptest.NewRequest("POST", "/webhook", strings.NewReader(`{"url":"ftp://partner.example","events":["bid_withdrawn"],"secret":"short"}`)))
+	rr := serveAs(f.admin, httptest.NewRequest("POST", "/webhook", strings.NewReader(webhookBody)))
+
+	// Assert
+	if denied.Code != http.StatusForbidden {
+		t.Errorf("Expected a client to be forbidden from creating webhooks, got %d", denied.Code)
+	}
+	if invalid.Code != http.StatusUnprocessableEntity || !strings.Contains(invalid.Body.String(), "secret") {
+		t.Errorf("Expected an invalid webhook to be rejected, got %d: %s", invalid.Code, invalid.Body.String())
+	}
+	if rr.Code != http.StatusCreated || strings.Contains(rr.Body.String(), "secret") {
+		t.Fatalf("Expected the webhook to be created without returning its secret, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created structure.Webhook
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode webhook: %v", err)
+	}
+	var stored structure.Webhook
+	if err := database.Get("webhook", &stored, created.ID.Hex()); err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if stored.Secret != "0123456789abcdef" || stored.Failures != 0 || stored.CreatedBy != f.admin.ID {
+		t.Errorf("Expected the secret to be stored with a clean record, got %+v", stored)
+	}
+
+	// The secret is never read back, and only admins read webhooks
+	for _, path := range []string{"/webhook", "/webhook/" + created.ID.Hex(), "/webhook/find?filter=" + url.QueryEscape(`{"url":"https://partner.example/hooks"}`)} {
+		rr := serveAs(f.admin, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "secret") || !strings.Contains(rr.Body.String(), created.ID.Hex()) {
+			t.Errorf("Expected GET %s to return the webhook without its secret, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+		if rr := serveAs(f.provider, httptest.NewRequest("GET", path, nil)); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected GET %s to be forbidden to a service provider, got %d", path, rr.Code)
+		}
+	}
+
+	// Enabling a disabled webhook clears its failures
+	database.Update("webhook", created.ID.Hex(), map[string]interface{}{"disabled": true, "failures": 20})
+	rr = serveAs(f.admin, httptest.NewRequest("PATCH", "/webhook/"+created.ID.Hex(), strings.NewReader(`{"disabled":false}`)))
+	database.Get("webhook", &stored, created.ID.Hex())
+	if rr.Code != http.StatusOK || stored.Disabled || stored.Failures != 0 {
+		t.Errorf("Expected the webhook to be enabled with no failures, got %d and %+v", rr.Code, stored)
+	}
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	// Arrange
+	f := newPolicyFixtures(t)
+	for _, collection := range []string{"webhook", "webhookDelivery"} {
+		database.ClearCollection(collection)
+	}
+	webhook := structure.Webhook{URL: "https://partner.example/hooks", Events: []structure.EventType{structure.EventBidPlaced}, Secret: "0123456789abcdef"}
+	other := structure.Webhook{URL: "https://other.example/hooks", Events: []structure.EventType{structure.EventBidPlaced}, Secret: "0123456789abcdef", Disabled: true}
+	for _, w := range []*structure.Webhook{&webhook, &other} {
+		if err := database.Create("webhook", w); err != nil {
+			t.Fatalf("Failed to insert webhook document: %v", err)
+		}
+	}
+	delivery := structure.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   primitive.NewObjectID(),
+		EventType: structure.EventBidPlaced,
+		Body:      `{"type":"bid_placed"}`,
+		Status:    structure.DeliveryFailed,
+		Attempts:  8,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if err := database.Create("webhookDelivery", &delivery); err != nil {
+		t.Fatalf("Failed to insert delivery document: %v", err)
+	}
+	replayPath := func(webhookID primitive.ObjectID) string {
+		return "/webhook/" + webhookID.Hex() + "/deliveries/" + delivery.ID.Hex() + "/replay"
+	}
+
+	// Act
+	denied := serveAs(f.owner, httptest.NewRequest("POST", replayPath(webhook.ID), nil))
+	elsewhere := serveAs(f.admin, httptest.NewRequest("POST", replayPath(other.ID), nil))
+	rr := serveAs(f.admin, httptest.NewRequest("POST", replayPath(webhook.ID), nil))
+	log := serveAs(f.admin, httptest.NewRequest("GET", "/webhook/"+webhook.ID.Hex()+"/deliveries", nil))
+
+	// Assert
+	if denied.Code != http.StatusForbidden || elsewhere.Code != http.StatusNotFound {
+		t.Errorf("Expected replays by a client and through another webhook to fail, got %d and %d", denied.Code, elsewhere.Code)
+	}
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected the delivery to be replayed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var replay structure.WebhookDelivery
+	if err := json.Unmarshal(rr.Body.Bytes(), &replay); err != nil {
+		t.Fatalf("Failed to decode delivery: %v", err)
+	}
+	if replay.ReplayOf != delivery.ID || replay.EventID != delivery.EventID || replay.Body != delivery.Body || replay.Status != structure.DeliveryPending || replay.Attempts != 0 {
+		t.Errorf("Expected a pending copy of the delivery, got %+v", replay)
+	}
+	var deliveries []structure.WebhookDelivery
+	if err := json.Unmarshal(log.Body.Bytes(), &deliveries); err != nil || len(deliveries) != 2 {
+		t.Errorf("Expected both deliveries in the log, got %d: %s", log.Code, log.Body.String())
+	}
+
+	// A disabled webhook is enabled before replaying to it
+	database.Update("webhookDelivery", delivery.ID.Hex(), map[string]interface{}{"webhookid": other.ID})
+	if rr := serveAs(f.admin, httptest.NewRequest("POST", replayPath(other.ID), nil)); rr.Code != http.StatusConflict {
+		t.Errorf("Expected replaying to a disabled webhook to conflict, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
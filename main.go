@@ -1,54 +1,309 @@
 package main
 
 import (
+	"Go-sumon/auction"
+	"Go-sumon/auth"
+	"Go-sumon/config"
+	"Go-sumon/database"
+	"Go-sumon/feed"
 	"Go-sumon/fileuploader"
 	"Go-sumon/handler"
+	"Go-sumon/notify"
+	"Go-sumon/outbox"
+	"Go-sumon/structure"
+	"Go-sumon/webhook"
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
-	// Register HTTP handlers for bid routes
-	http.HandleFunc("/bid", enableCors(handler.GetAllBidHandler))
-	http.HandleFunc("/bid/create", enableCors(handler.CreateBidHandler))
-	http.HandleFunc("/bid/{_id}", enableCors(handler.GetBidHandler))
-	http.HandleFunc("/bid/{_id}/update", enableCors(handler.UpdateBidHandler))
-	http.HandleFunc("/bid/{_id}/delete", enableCors(handler.DeleteBidHandler))
-	http.HandleFunc("/bid/{_id}/find", enableCors(handler.FindBidHandler))
-
-	// Register HTTP handlers for review routes
-	http.HandleFunc("/review", enableCors(handler.GetAllReviewHandler))
-	http.HandleFunc("/review/create", enableCors(handler.CreateReviewHandler))
-	http.HandleFunc("/review/{_id}", enableCors(handler.GetReviewHandler))
-	http.HandleFunc("/review/{_id}/update", enableCors(handler.UpdateReviewHandler))
-	http.HandleFunc("/review/{_id}/delete", enableCors(handler.DeleteReviewHandler))
-	http.HandleFunc("/review/{_id}/find", enableCors(handler.FindReviewHandler))
-
-	// Start the HTTP server
-	go func() {
-		log.Println("Server listening on port 5000...")
-		if err := http.ListenAndServe(":5000", nil); err != nil {
-			log.Fatalf("Error starting server on port 5000: %v", err)
+	cfg, invocation, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if invocation.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Error printing configuration: %v", err)
+		}
+		return
+	}
+
+	fileuploader.Configure(fileuploader.Options{
+		Dir:              cfg.Upload.Dir,
+		MaxFileSize:      cfg.Upload.MaxFileSize,
+		AllowedFileTypes: cfg.Upload.AllowedFileTypes,
+	})
+
+	// "migrate" manages the database schema instead of starting the server
+	if len(invocation.Args) > 0 && invocation.Args[0] == "migrate" {
+		if err := runMigrate(cfg, invocation.Args[1:]); err != nil {
+			log.Fatalf("Error running migrations: %v", err)
+		}
+		return
+	}
+	if len(invocation.Args) > 0 {
+		log.Fatalf("Unknown command %q", invocation.Args[0])
+	}
+
+	if err := run(cfg); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the API until SIGINT or SIGTERM, then drains in-flight
+// requests and closes the database.
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Open a single database backend shared by all handlers
+	store, err := database.OpenDatabase(ctx, databaseOptions(cfg.Database))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	database.SetDefault(store)
+	handler.SetDatabase(store)
+	service, err := authService(cfg.Auth, store)
+	if err != nil {
+		return err
+	}
+	handler.SetAuth(service)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := database.Close(ctx); err != nil {
+			log.Printf("Error closing database: %v", err)
 		}
 	}()
 
-	// Setup file upload routes
-	setupRoutes()
+	// Stream events until shutdown begins, ending the streams so that the
+	// servers can drain
+	hub := feed.NewMemoryHub(feed.Options{History: cfg.Feed.History, Buffer: cfg.Feed.Buffer})
+	handler.SetFeed(hub, time.Duration(cfg.Feed.Heartbeat))
+	go func() {
+		<-ctx.Done()
+		hub.Close()
+	}()
+
+	// Notify users of what happens to their jobs, bids and reviews
+	notifications := notifyService(cfg, store)
+
+	// Close the bidding on expired jobs until shutdown, finishing before the
+	// database is closed
+	if cfg.Auction.Interval > 0 {
+		notifier := auction.Notifiers{auction.LogNotifier{}, feed.AuctionNotifier{Hub: hub}, notify.AuctionNotifier{Service: notifications}}
+		scheduler := auction.NewScheduler(store, notifier, auctionOptions(cfg.Auction))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			scheduler.Run(ctx)
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+	}
+
+	// Send webhook deliveries until shutdown, finishing before the database
+	// is closed
+	var sender *webhook.Sender
+	if cfg.Webhook.Interval > 0 {
+		sender = webhook.NewSender(store, webhookOptions(cfg.Webhook))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sender.Run(ctx)
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+	}
+
+	// Deliver domain events from the outbox until shutdown, finishing before
+	// the database is closed
+	if cfg.Outbox.Interval > 0 {
+		dispatcher := outbox.NewDispatcher(store, outboxOptions(cfg.Outbox))
+		dispatcher.Subscribe("log", outbox.Log)
+		dispatcher.Subscribe("notify", notifications.Handle)
+		if sender != nil {
+			dispatcher.Subscribe("webhook", sender.Enqueue)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			dispatcher.Run(ctx)
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+	}
+
+	return serve(ctx, newServers(cfg.Server), time.Duration(cfg.Server.ShutdownTimeout))
 }
 
-func setupRoutes() {
-	http.HandleFunc("/upload", enableCors(fileuploader.UploadFile))
-	log.Println("File upload server listening on port 8080...")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("Error starting file upload server on port 8080: %v", err)
+// databaseOptions converts the database configuration into database.Options.
+func databaseOptions(cfg config.Database) database.Options {
+	return database.Options{
+		Backend:                cfg.Backend,
+		URI:                    cfg.URI,
+		DBName:                 cfg.Name,
+		MaxPoolSize:            cfg.MaxPoolSize,
+		MinPoolSize:            cfg.MinPoolSize,
+		MaxConnIdleTime:        time.Duration(cfg.MaxConnIdleTime),
+		ConnectTimeout:         time.Duration(cfg.ConnectTimeout),
+		ServerSelectionTimeout: time.Duration(cfg.ServerSelectionTimeout),
+		OperationTimeout:       time.Duration(cfg.OperationTimeout),
+		AutoMigrate:            cfg.AutoMigrate,
+	}
+}
+
+// authService builds the login service from the auth configuration. Without
+// a configured secret, tokens are signed with a random one and stop working
+// when the process restarts.
+func authService(cfg config.Auth, store database.Database) (*auth.Service, error) {
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		log.Println("Warning: JWT_SECRET is not set; sessions will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate JWT secret: %w", err)
+		}
+	}
+
+	return auth.NewService(store, smsSender(cfg), secret, auth.Options{
+		AccessTokenTTL:  time.Duration(cfg.AccessTokenTTL),
+		RefreshTokenTTL: time.Duration(cfg.RefreshTokenTTL),
+		CodeTTL:         time.Duration(cfg.CodeTTL),
+		CodeLength:      cfg.CodeLength,
+		MaxAttempts:     cfg.MaxAttempts,
+		MaxCodes:        cfg.MaxCodes,
+		RateWindow:      time.Duration(cfg.RateWindow),
+	}), nil
+}
+
+// smsSender returns the sender of login codes and notification texts.
+func smsSender(cfg config.Auth) auth.SMSSender {
+	if cfg.SMSSender == "file" {
+		return &auth.FileSender{Path: cfg.SMSFile}
+	}
+	return auth.LogSender{}
+}
+
+// notifyService builds the notification service, sending texts with the SMS
+// sender of cfg.Auth and emails as cfg.Notify says.
+func notifyService(cfg config.Config, store database.Database) *notify.Service {
+	var email notify.EmailSender = notify.LogEmailSender{}
+	if cfg.Notify.EmailSender == "file" {
+		email = &notify.FileEmailSender{Path: cfg.Notify.EmailFile}
+	}
+	return notify.NewService(store, map[structure.Channel]notify.Notifier{
+		structure.ChannelSMS:   notify.SMSNotifier{Sender: smsSender(cfg.Auth)},
+		structure.ChannelEmail: notify.EmailNotifier{Sender: email},
+		structure.ChannelInApp: notify.InAppNotifier{DB: store},
+	})
+}
+
+// auctionOptions converts the auction configuration into auction.Options.
+func auctionOptions(cfg config.Auction) auction.Options {
+	opts := auction.DefaultOptions()
+	opts.Rule = auction.Rule(cfg.Rule)
+	opts.Interval = time.Duration(cfg.Interval)
+	opts.LeaseTTL = time.Duration(cfg.LeaseTTL)
+	return opts
+}
+
+// outboxOptions converts the outbox configuration into outbox.Options.
+func outboxOptions(cfg config.Outbox) outbox.Options {
+	opts := outbox.DefaultOptions()
+	opts.Interval = time.Duration(cfg.Interval)
+	opts.MaxAttempts = cfg.MaxAttempts
+	opts.Backoff = time.Duration(cfg.Backoff)
+	opts.MaxBackoff = time.Duration(cfg.MaxBackoff)
+	opts.LeaseTTL = time.Duration(cfg.LeaseTTL)
+	return opts
+}
+
+// webhookOptions converts the webhook configuration into webhook.Options.
+func webhookOptions(cfg config.Webhook) webhook.Options {
+	opts := webhook.DefaultOptions()
+	opts.Interval = time.Duration(cfg.Interval)
+	opts.Timeout = time.Duration(cfg.Timeout)
+	opts.MaxAttempts = cfg.MaxAttempts
+	opts.Backoff = time.Duration(cfg.Backoff)
+	opts.MaxBackoff = time.Duration(cfg.MaxBackoff)
+	opts.DisableAfter = cfg.DisableAfter
+	opts.LeaseTTL = time.Duration(cfg.LeaseTTL)
+	return opts
+}
+
+// runMigrate handles "migrate [up | down <version> | status]". Without
+// arguments it applies every pending migration.
+func runMigrate(cfg config.Config, args []string) error {
+	opts := databaseOptions(cfg.Database)
+	if opts.Backend != database.BackendMongo {
+		return fmt.Errorf("migrations require the %s backend", database.BackendMongo)
+	}
+	opts.AutoMigrate = false
+
+	ctx := context.Background()
+	store, err := database.Open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer store.Close(ctx)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch {
+	case command == "up" && len(args) <= 1:
+		return store.Migrate(ctx)
+	case command == "down" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		return store.MigrateTo(ctx, version)
+	case command == "status" && len(args) == 1:
+		statuses, err := store.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if !status.AppliedAt.IsZero() {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%3d  %-50s  %s\n", status.Version, status.Description, applied)
+		}
+		return nil
+	default:
+		return errors.New("usage: migrate [up | down <version> | status]")
 	}
 }
 
 func enableCors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Last-Event-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
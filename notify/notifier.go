@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"Go-sumon/auth"
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNoAddress is returned by a Notifier when the recipient has no address
+// on its channel, such as a user without an email address. The Service
+// skips the channel.
+var ErrNoAddress = errors.New("recipient has no address on this channel")
+
+// Recipient is the user a Message is sent to.
+type Recipient struct {
+	UserID      primitive.ObjectID
+	Name        string
+	PhoneNumber string
+	Email       string
+}
+
+// Message is a notification written in the recipient's language.
+type Message struct {
+	Kind    structure.NotificationKind
+	Title   string
+	Body    string
+	JobID   primitive.ObjectID // the job it is about, if any
+	EventID primitive.ObjectID // the domain event it is sent for, if any
+}
+
+// Notifier sends messages on one channel.
+type Notifier interface {
+	Notify(ctx context.Context, recipient Recipient, message Message) error
+}
+
+// SMSNotifier texts the body of messages to the recipient's phone number,
+// with the same senders as login codes.
+type SMSNotifier struct {
+	Sender auth.SMSSender
+}
+
+func (n SMSNotifier) Notify(ctx context.Context, recipient Recipient, message Message) error {
+	if recipient.PhoneNumber == "" {
+		return ErrNoAddress
+	}
+	return n.Sender.Send(ctx, recipient.PhoneNumber, message.Body)
+}
+
+// EmailSender delivers an email.
+type EmailSender interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// EmailNotifier emails messages to the recipient's address, with the title
+// as the subject.
+type EmailNotifier struct {
+	Sender EmailSender
+}
+
+func (n EmailNotifier) Notify(ctx context.Context, recipient Recipient, message Message) error {
+	if recipient.Email == "" {
+		return ErrNoAddress
+	}
+	return n.Sender.Send(ctx, recipient.Email, message.Title, message.Body)
+}
+
+// LogEmailSender writes emails to the standard logger instead of sending
+// them. It is meant for local development.
+type LogEmailSender struct{}
+
+func (LogEmailSender) Send(ctx context.Context, to string, subject string, body string) error {
+	log.Printf("Email to %s: %s: %s", to, subject, body)
+	return nil
+}
+
+// FileEmailSender appends emails to a file instead of sending them. It is
+// meant for local development and tests.
+type FileEmailSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileEmailSender) Send(ctx context.Context, to string, subject string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open email file: %v", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), to, subject, body)
+	return err
+}
+
+// InAppNotifier puts messages in the recipient's inbox, unread. A message
+// sent again for the same domain event is put there once.
+type InAppNotifier struct {
+	DB database.Database
+}
+
+func (n InAppNotifier) Notify(ctx context.Context, recipient Recipient, message Message) error {
+	if !message.EventID.IsZero() {
+		count, err := n.DB.Count(ctx, "notification", bson.M{"userid": recipient.UserID, "eventid": message.EventID})
+		if err != nil || count > 0 {
+			return err
+		}
+	}
+	notification := structure.Notification{
+		UserID:    recipient.UserID,
+		Kind:      message.Kind,
+		Title:     message.Title,
+		Body:      message.Body,
+		JobID:     message.JobID,
+		EventID:   message.EventID,
+		CreatedAt: time.Now().UTC(),
+	}
+	return n.DB.Create(ctx, "notification", &notification)
+}
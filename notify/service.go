@@ -0,0 +1,184 @@
+// Package notify tells users what happens to their jobs, bids and reviews,
+// by SMS, email and in the app, in the language and on the channels they
+// prefer.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"Go-sumon/auction"
+	"Go-sumon/database"
+	"Go-sumon/outbox"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notice is one notification to send.
+type Notice struct {
+	UserID  primitive.ObjectID
+	Kind    structure.NotificationKind
+	Data    Data
+	JobID   primitive.ObjectID // the job it is about, if any
+	EventID primitive.ObjectID // the domain event it is sent for, if any
+}
+
+// Service sends notices to users on the channels of their preferences that
+// it has a Notifier for.
+type Service struct {
+	db        database.Database
+	notifiers map[structure.Channel]Notifier
+}
+
+// NewService returns a Service for the users in db, sending on the given
+// channels.
+func NewService(db database.Database, notifiers map[structure.Channel]Notifier) *Service {
+	return &Service{db: db, notifiers: notifiers}
+}
+
+// Preferences returns the notification preferences of the user, or the
+// defaults if they have not chosen any.
+func Preferences(ctx context.Context, db database.Database, userID primitive.ObjectID) (structure.NotificationPreferences, error) {
+	var prefs structure.NotificationPreferences
+	err := db.Get(ctx, "notificationPreferences", userID.Hex(), &prefs)
+	if errors.Is(err, database.ErrNotFound) {
+		return structure.DefaultNotificationPreferences(userID), nil
+	}
+	return prefs, err
+}
+
+// Send writes the notice in the user's language and sends it on each of
+// their channels. Channels the user has no address on are skipped, and
+// notices to users who no longer exist are dropped. It returns the errors of
+// the channels that failed.
+func (s *Service) Send(ctx context.Context, notice Notice) error {
+	if notice.UserID.IsZero() {
+		return nil
+	}
+	var user structure.User
+	if err := s.db.Get(ctx, "user", notice.UserID.Hex(), &user); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	prefs, err := Preferences(ctx, s.db, notice.UserID)
+	if err != nil {
+		return err
+	}
+
+	if notice.Data.Name == "" {
+		notice.Data.Name = user.Name
+	}
+	message, err := Render(notice.Kind, prefs.Language, notice.Data)
+	if err != nil {
+		return err
+	}
+	message.JobID = notice.JobID
+	message.EventID = notice.EventID
+
+	recipient := Recipient{UserID: user.ID, Name: user.Name, PhoneNumber: user.PhoneNumber, Email: user.Email}
+	var errs []error
+	for _, channel := range prefs.Channels {
+		notifier, ok := s.notifiers[channel]
+		if !ok {
+			continue
+		}
+		if err := notifier.Notify(ctx, recipient, message); err != nil && !errors.Is(err, ErrNoAddress) {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handle is an outbox.Subscriber that notifies the users a domain event
+// concerns. The outbox may deliver an event again after a channel failed,
+// so SMS and email may be sent twice; the inbox gets the notification once.
+func (s *Service) Handle(ctx context.Context, event outbox.Event) error {
+	var notices []Notice
+	switch payload := event.Payload.(type) {
+	case structure.UserRegistered:
+		notices = append(notices, Notice{UserID: payload.UserID, Kind: structure.NotificationWelcome})
+
+	case structure.BidPlaced:
+		job, err := s.job(ctx, payload.JobID)
+		if err != nil {
+			return err
+		}
+		data := Data{JobTitle: job.Title, BidAmount: payload.BidAmount}
+		if job.AuctionMode == structure.AuctionModeSealed {
+			// The client is not shown the amounts of sealed bids either
+			data.BidAmount = 0
+		}
+		notices = append(notices, Notice{UserID: job.ClientID, Kind: structure.NotificationBidPlaced, Data: data, JobID: job.ID})
+
+	case structure.BidAccepted:
+		job, err := s.job(ctx, payload.JobID)
+		if err != nil {
+			return err
+		}
+		notices = append(notices, Notice{UserID: payload.ServiceProviderID, Kind: structure.NotificationBidWon, Data: Data{JobTitle: job.Title}, JobID: job.ID})
+
+	case structure.JobStatusChanged:
+		job, err := s.job(ctx, payload.JobID)
+		if err != nil {
+			return err
+		}
+		// Tell the client and service provider of the job, but not the one
+		// who made the change. The winner of a bid is told by bid_won.
+		recipients := []primitive.ObjectID{job.ClientID}
+		if payload.To != structure.JobStatusBidAccepted {
+			recipients = append(recipients, job.ServiceProviderID)
+		}
+		for _, userID := range recipients {
+			if userID != payload.Actor {
+				notices = append(notices, Notice{UserID: userID, Kind: structure.NotificationJobStatusChanged, Data: Data{JobTitle: job.Title, Status: payload.To}, JobID: job.ID})
+			}
+		}
+
+	case structure.ReviewCreated:
+		job, err := s.job(ctx, payload.JobID)
+		if err != nil {
+			return err
+		}
+		notices = append(notices, Notice{UserID: payload.RevieweeID, Kind: structure.NotificationReviewReceived, Data: Data{JobTitle: job.Title}, JobID: job.ID})
+	}
+
+	var errs []error
+	for _, notice := range notices {
+		notice.EventID = event.ID
+		if err := s.Send(ctx, notice); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// job returns the job with the given ID, or a zero job if it was deleted.
+func (s *Service) job(ctx context.Context, jobID primitive.ObjectID) (structure.Job, error) {
+	var job structure.Job
+	if err := s.db.Get(ctx, "job", jobID.Hex(), &job); err != nil && !errors.Is(err, database.ErrNotFound) {
+		return job, err
+	}
+	return job, nil
+}
+
+// AuctionNotifier tells clients to choose the winning bid when bidding on
+// their manual job closes. The outcomes of the other rules reach users as
+// domain events.
+type AuctionNotifier struct {
+	Service *Service
+}
+
+func (n AuctionNotifier) Notify(ctx context.Context, event auction.Event) error {
+	if event.Type != auction.EventAwaitingChoice {
+		return nil
+	}
+	job, err := n.Service.job(ctx, event.JobID)
+	if err != nil {
+		return err
+	}
+	return n.Service.Send(ctx, Notice{UserID: event.ClientID, Kind: structure.NotificationChooseWinner, Data: Data{JobTitle: job.Title}, JobID: event.JobID})
+}
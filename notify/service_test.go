@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"Go-sumon/database"
+	"Go-sumon/outbox"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingNotifier keeps the messages it is asked to send.
+type recordingNotifier struct {
+	recipients []Recipient
+	messages   []Message
+	err        error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, recipient Recipient, message Message) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.recipients = append(n.recipients, recipient)
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+// notifyTest is a Service on a fresh memory store, sending texts to sms and
+// putting messages in the real inbox.
+type notifyTest struct {
+	t       *testing.T
+	store   *database.MemoryStore
+	service *Service
+	sms     *recordingNotifier
+	users   int
+}
+
+func newNotifyTest(t *testing.T) *notifyTest {
+	store := database.NewMemoryStore()
+	sms := &recordingNotifier{}
+	service := NewService(store, map[structure.Channel]Notifier{
+		structure.ChannelSMS:   sms,
+		structure.ChannelEmail: EmailNotifier{Sender: LogEmailSender{}},
+		structure.ChannelInApp: InAppNotifier{DB: store},
+	})
+	return &notifyTest{t: t, store: store, service: service, sms: sms}
+}
+
+// user creates a user with the given preferences, or the defaults if nil.
+func (n *notifyTest) user(name string, prefs *structure.NotificationPreferences) structure.User {
+	n.t.Helper()
+	n.users++
+	user := structure.User{Name: name, PhoneNumber: fmt.Sprintf("017000000%02d", n.users)}
+	if err := n.store.Create(context.Background(), "user", &user); err != nil {
+		n.t.Fatalf("Failed to insert user document: %v", err)
+	}
+	if prefs != nil {
+		prefs.ID = user.ID
+		if err := n.store.Create(context.Background(), "notificationPreferences", prefs); err != nil {
+			n.t.Fatalf("Failed to insert preferences document: %v", err)
+		}
+	}
+	return user
+}
+
+// inbox returns the in-app notifications of the user.
+func (n *notifyTest) inbox(userID primitive.ObjectID) []structure.Notification {
+	n.t.Helper()
+	var notifications []structure.Notification
+	if err := n.store.Find(context.Background(), "notification", bson.M{"userid": userID}, &notifications); err != nil {
+		n.t.Fatalf("Failed to find notifications: %v", err)
+	}
+	return notifications
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		kind     structure.NotificationKind
+		language structure.Language
+		data     Data
+		title    string
+		body     string
+	}{
+		{structure.NotificationBidPlaced, structure.LanguageEnglish, Data{JobTitle: "Fix the roof", BidAmount: structure.Taka(1500)}, "New bid on Fix the roof", `A service provider bid ৳1500.00 on your job "Fix the roof".`},
+		{structure.NotificationBidPlaced, structure.LanguageEnglish, Data{JobTitle: "Fix the roof"}, "New bid on Fix the roof", `A service provider bid on your job "Fix the roof".`},
+		{structure.NotificationBidPlaced, structure.LanguageBangla, Data{JobTitle: "Fix the roof", BidAmount: structure.Taka(1500)}, "Fix the roof কাজে নতুন বিড", `একজন সেবাদাতা আপনার "Fix the roof" কাজে ৳1500.00 বিড করেছেন।`},
+		{structure.NotificationJobStatusChanged, structure.LanguageBangla, Data{JobTitle: "Fix the roof", Status: structure.JobStatusCancelled}, "Fix the roof কাজের হালনাগাদ", `"Fix the roof" কাজটি এখন বাতিল।`},
+		{structure.NotificationWelcome, "fr", Data{Name: "Rahim"}, "Welcome to Sumon", "Hi Rahim, your account is ready. Post a job or bid on one to get started."},
+	}
+	for _, tt := range tests {
+		message, err := Render(tt.kind, tt.language, tt.data)
+		if err != nil {
+			t.Fatalf("Failed to render %s in %s: %v", tt.kind, tt.language, err)
+		}
+		if message.Title != tt.title || message.Body != tt.body {
+			t.Errorf("Expected %s in %s to be %q: %q, got %q: %q", tt.kind, tt.language, tt.title, tt.body, message.Title, message.Body)
+		}
+	}
+
+	// Every kind has a template in every language
+	for language, kinds := range texts {
+		if len(kinds) != len(texts[structure.LanguageEnglish]) {
+			t.Errorf("Expected every template in %s, got %d", language, len(kinds))
+		}
+	}
+	if _, err := Render("payment_received", structure.LanguageEnglish, Data{}); err == nil {
+		t.Error("Expected an unknown kind to be rejected")
+	}
+}
+
+func TestSendFollowsPreferences(t *testing.T) {
+	// Arrange
+	n := newNotifyTest(t)
+	bangla := n.user("Rahim", &structure.NotificationPreferences{Language: structure.LanguageBangla, Channels: []structure.Channel{structure.ChannelSMS, structure.ChannelEmail}})
+	defaults := n.user("Karim", nil)
+	muted := n.user("Salma", &structure.NotificationPreferences{Language: structure.LanguageEnglish, Channels: []structure.Channel{}})
+
+	// Act
+	for _, user := range []structure.User{bangla, defaults, muted} {
+		if err := n.service.Send(context.Background(), Notice{UserID: user.ID, Kind: structure.NotificationWelcome}); err != nil {
+			t.Fatalf("Failed to send notice: %v", err)
+		}
+	}
+	missing := n.service.Send(context.Background(), Notice{UserID: primitive.NewObjectID(), Kind: structure.NotificationWelcome})
+
+	// Assert: email is skipped for users without an address
+	if len(n.sms.messages) != 2 || n.sms.recipients[0].UserID != bangla.ID || n.sms.messages[1].Title != "Welcome to Sumon" {
+		t.Fatalf("Expected texts to Rahim and Karim, got %+v", n.sms.recipients)
+	}
+	if !strings.HasPrefix(n.sms.messages[0].Body, "Rahim, আপনার অ্যাকাউন্ট") {
+		t.Errorf("Expected the text in Bangla, got %q", n.sms.messages[0].Body)
+	}
+	if len(n.inbox(bangla.ID)) != 0 || len(n.inbox(defaults.ID)) != 1 || len(n.inbox(muted.ID)) != 0 {
+		t.Error("Expected only Karim to get the notification in the app")
+	}
+	if missing != nil {
+		t.Errorf("Expected a notice to a deleted user to be dropped, got %v", missing)
+	}
+
+	// A failed channel is reported, without keeping the others from sending
+	n.sms.err = errors.New("gateway down")
+	err := n.service.Send(context.Background(), Notice{UserID: defaults.ID, Kind: structure.NotificationWelcome})
+	if err == nil || !strings.Contains(err.Error(), "sms: gateway down") || len(n.inbox(defaults.ID)) != 2 {
+		t.Errorf("Expected the SMS failure to be reported after the in-app notification, got %v", err)
+	}
+}
+
+func TestHandleNotifiesJobParties(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	n := newNotifyTest(t)
+	client := n.user("Client", nil)
+	provider := n.user("Provider", nil)
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted, ClientID: client.ID}
+	if err := n.store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	bid := structure.Bid{Description: "I can do it", BidAmount: structure.Taka(1500), SPID: provider.ID}
+	if err := n.store.PlaceBid(ctx, job.ID.Hex(), &bid); err != nil {
+		t.Fatalf("Failed to place bid: %v", err)
+	}
+	if err := n.store.AcceptBid(ctx, job.ID.Hex(), bid.ID.Hex(), client.ID, ""); err != nil {
+		t.Fatalf("Failed to accept bid: %v", err)
+	}
+	dispatcher := outbox.NewDispatcher(n.store, outbox.DefaultOptions())
+	var events []outbox.Event
+	dispatcher.Subscribe("notify", func(ctx context.Context, event outbox.Event) error {
+		events = append(events, event)
+		return n.service.Handle(ctx, event)
+	})
+
+	// Act
+	if _, err := dispatcher.DispatchPending(ctx); err != nil {
+		t.Fatalf("Failed to dispatch events: %v", err)
+	}
+	again := n.service.Handle(ctx, events[0])
+
+	// Assert: the client accepted the bid, so is only told about it
+	clientInbox, providerInbox := n.inbox(client.ID), n.inbox(provider.ID)
+	if len(clientInbox) != 1 || clientInbox[0].Kind != structure.NotificationBidPlaced || clientInbox[0].Body != `A service provider bid ৳1500.00 on your job "Fix the roof".` {
+		t.Errorf("Expected the client to be told of the bid, got %+v", clientInbox)
+	}
+	if len(providerInbox) != 1 || providerInbox[0].Kind != structure.NotificationBidWon || providerInbox[0].JobID != job.ID || providerInbox[0].Read {
+		t.Errorf("Expected the provider to be told they won, got %+v", providerInbox)
+	}
+	if again != nil || len(n.inbox(client.ID)) != 1 || len(n.sms.messages) != 3 {
+		t.Errorf("Expected an event handled again to reach the inbox once, got %v and %d texts", again, len(n.sms.messages))
+	}
+}
+
+func TestHandleHidesSealedBidAmounts(t *testing.T) {
+	// Arrange
+	n := newNotifyTest(t)
+	client := n.user("Client", nil)
+	job := structure.Job{Title: "Fix the roof", ClientID: client.ID, AuctionMode: structure.AuctionModeSealed}
+	if err := n.store.Create(context.Background(), "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	event := outbox.Event{ID: primitive.NewObjectID(), Payload: structure.BidPlaced{JobID: job.ID, BidAmount: structure.Taka(1500)}}
+
+	// Act
+	err := n.service.Handle(context.Background(), event)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to handle event: %v", err)
+	}
+	if len(n.sms.messages) != 1 || strings.Contains(n.sms.messages[0].Body, "1500") {
+		t.Errorf("Expected the bid amount to be left out, got %+v", n.sms.messages)
+	}
+}
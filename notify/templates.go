@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"Go-sumon/structure"
+)
+
+// Data fills in the templates of a notification.
+type Data struct {
+	Name      string              // the recipient's, filled in by the Service
+	JobTitle  string              // the job the notification is about
+	BidAmount structure.Money     // left out of the message when zero
+	Status    structure.JobStatus // the job's new status
+}
+
+// text is the template of a notification's title and body.
+type text struct {
+	Title, Body string
+}
+
+// texts holds the templates of every kind of notification in each language.
+var texts = map[structure.Language]map[structure.NotificationKind]text{
+	structure.LanguageEnglish: {
+		structure.NotificationWelcome: {
+			"Welcome to Sumon",
+			"Hi {{.Name}}, your account is ready. Post a job or bid on one to get started.",
+		},
+		structure.NotificationBidPlaced: {
+			"New bid on {{.JobTitle}}",
+			`A service provider bid{{if .BidAmount}} {{amount .BidAmount}}{{end}} on your job "{{.JobTitle}}".`,
+		},
+		structure.NotificationBidWon: {
+			"You won {{.JobTitle}}",
+			`Your bid on "{{.JobTitle}}" was accepted. Contact the client to get started.`,
+		},
+		structure.NotificationChooseWinner: {
+			"Choose a bid for {{.JobTitle}}",
+			`Bidding on "{{.JobTitle}}" has closed. Choose the bid you want to accept.`,
+		},
+		structure.NotificationJobStatusChanged: {
+			"Update on {{.JobTitle}}",
+			`The job "{{.JobTitle}}" is now {{status .Status}}.`,
+		},
+		structure.NotificationReviewReceived: {
+			"New review on {{.JobTitle}}",
+			`Your client reviewed your work on "{{.JobTitle}}".`,
+		},
+	},
+	structure.LanguageBangla: {
+		structure.NotificationWelcome: {
+			"সুমনে স্বাগতম",
+			"{{.Name}}, আপনার অ্যাকাউন্ট তৈরি হয়েছে। কাজ পোস্ট করুন অথবা কোনো কাজে বিড করে শুরু করুন।",
+		},
+		structure.NotificationBidPlaced: {
+			"{{.JobTitle}} কাজে নতুন বিড",
+			`একজন সেবাদাতা আপনার "{{.JobTitle}}" কাজে{{if .BidAmount}} {{amount .BidAmount}}{{end}} বিড করেছেন।`,
+		},
+		structure.NotificationBidWon: {
+			"আপনি {{.JobTitle}} কাজটি পেয়েছেন",
+			`"{{.JobTitle}}" কাজে আপনার বিড গ্রহণ করা হয়েছে। কাজ শুরু করতে ক্লায়েন্টের সাথে যোগাযোগ করুন।`,
+		},
+		structure.NotificationChooseWinner: {
+			"{{.JobTitle}} কাজের জন্য বিড বেছে নিন",
+			`"{{.JobTitle}}" কাজে বিড নেওয়া শেষ হয়েছে। যে বিডটি গ্রহণ করতে চান সেটি বেছে নিন।`,
+		},
+		structure.NotificationJobStatusChanged: {
+			"{{.JobTitle}} কাজের হালনাগাদ",
+			`"{{.JobTitle}}" কাজটি এখন {{status .Status}}।`,
+		},
+		structure.NotificationReviewReceived: {
+			"{{.JobTitle}} কাজে নতুন রিভিউ",
+			`ক্লায়েন্ট "{{.JobTitle}}" কাজে আপনার কাজের রিভিউ দিয়েছেন।`,
+		},
+	},
+}
+
+// statuses names the job statuses in each language.
+var statuses = map[structure.Language]map[structure.JobStatus]string{
+	structure.LanguageEnglish: {
+		structure.JobStatusJobPosted:   "open for bids",
+		structure.JobStatusBidAccepted: "assigned",
+		structure.JobStatusJobStarted:  "in progress",
+		structure.JobStatusCompleted:   "completed",
+		structure.JobStatusCancelled:   "cancelled",
+		structure.JobStatusDisputed:    "disputed",
+		structure.JobStatusBan:         "banned",
+	},
+	structure.LanguageBangla: {
+		structure.JobStatusJobPosted:   "বিডের জন্য খোলা",
+		structure.JobStatusBidAccepted: "বরাদ্দ করা হয়েছে",
+		structure.JobStatusJobStarted:  "চলমান",
+		structure.JobStatusCompleted:   "সম্পন্ন",
+		structure.JobStatusCancelled:   "বাতিল",
+		structure.JobStatusDisputed:    "বিরোধে আছে",
+		structure.JobStatusBan:         "নিষিদ্ধ",
+	},
+}
+
+// templates holds texts parsed, named "<kind>.title" and "<kind>.body".
+var templates = parseTexts()
+
+func parseTexts() map[structure.Language]*template.Template {
+	parsed := make(map[structure.Language]*template.Template)
+	for language, kinds := range texts {
+		funcs := template.FuncMap{
+			"amount": func(amount structure.Money) string { return "৳" + amount.String() },
+			"status": func(status structure.JobStatus) string {
+				if name, ok := statuses[language][status]; ok {
+					return name
+				}
+				return string(status)
+			},
+		}
+		t := template.New(string(language)).Funcs(funcs)
+		for kind, text := range kinds {
+			template.Must(t.New(string(kind) + ".title").Parse(text.Title))
+			template.Must(t.New(string(kind) + ".body").Parse(text.Body))
+		}
+		parsed[language] = t
+	}
+	return parsed
+}
+
+// Render writes a notification of the given kind in language, or in English
+// if there are no templates in language.
+func Render(kind structure.NotificationKind, language structure.Language, data Data) (Message, error) {
+	t, ok := templates[language]
+	if !ok {
+		t = templates[structure.LanguageEnglish]
+	}
+	var title, body strings.Builder
+	if t.Lookup(string(kind)+".title") == nil {
+		return Message{}, fmt.Errorf("no template for %s notifications", kind)
+	}
+	if err := t.ExecuteTemplate(&title, string(kind)+".title", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.ExecuteTemplate(&body, string(kind)+".body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{Kind: kind, Title: title.String(), Body: body.String()}, nil
+}
@@ -0,0 +1,267 @@
+// Package outbox delivers the domain events that the database records in its
+// outbox to the subscribers registered in this process.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// leaseName is the lease a Dispatcher holds while it delivers events, so that
+// only one replica delivers them at a time.
+const leaseName = "outbox"
+
+// Event is a domain event delivered from the outbox.
+type Event struct {
+	ID         primitive.ObjectID // the same on every delivery of the event
+	OccurredAt time.Time
+	Attempt    int // 1 on the first delivery
+	Payload    structure.DomainEvent
+}
+
+// Subscriber handles the events it subscribed to. Events are delivered at
+// least once, so a subscriber must tolerate being given the same event again,
+// e.g. by remembering the IDs it has handled.
+type Subscriber func(ctx context.Context, event Event) error
+
+// Options configures the Dispatcher.
+type Options struct {
+	Interval    time.Duration // time between looks for pending events
+	BatchSize   int64         // events delivered per look
+	MaxAttempts int           // failed deliveries before an event is dead-lettered
+	Backoff     time.Duration // wait after the first failed delivery, doubled after each further one
+	MaxBackoff  time.Duration // longest wait between deliveries
+	LeaseTTL    time.Duration // how long one replica may deliver events before another may take over
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		Interval:    time.Second,
+		BatchSize:   100,
+		MaxAttempts: 10,
+		Backoff:     time.Second,
+		MaxBackoff:  10 * time.Minute,
+		LeaseTTL:    time.Minute,
+	}
+}
+
+// subscription is one Subscribe call of a Dispatcher.
+type subscription struct {
+	name   string
+	types  []structure.EventType // every type when empty
+	handle Subscriber
+}
+
+// wants reports whether the subscription is for events of type t.
+func (s subscription) wants(t structure.EventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, want := range s.types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher delivers pending outbox events to its subscribers, retrying
+// failed deliveries with exponential backoff and dead-lettering events that
+// still fail after MaxAttempts. An event that one subscriber failed to handle
+// is retried only for that subscriber. Replicas share the work through a
+// lease in the database.
+type Dispatcher struct {
+	db     database.Database
+	opts   Options
+	holder string // identifies this Dispatcher in the lease
+	now    func() time.Time
+
+	mu            sync.RWMutex
+	subscriptions []subscription
+}
+
+// NewDispatcher returns a Dispatcher without subscribers that delivers the
+// events in db's outbox.
+func NewDispatcher(db database.Database, opts Options) *Dispatcher {
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d-%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+	return &Dispatcher{db: db, opts: opts, holder: holder, now: time.Now}
+}
+
+// Subscribe registers handle, under a name unique to the Dispatcher, for the
+// events of the given types, or of every type when none are given. The name
+// records which subscribers have handled an event, so it must stay the same
+// across restarts.
+func (d *Dispatcher) Subscribe(name string, handle Subscriber, types ...structure.EventType) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, subscription{name: name, types: types, handle: handle})
+}
+
+// Run delivers pending events every Interval until ctx is done, then gives
+// up the lease so another replica can take over at once.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchPending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error dispatching outbox events: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := d.db.ReleaseLease(releaseCtx, leaseName, d.holder); err != nil {
+				log.Printf("Error releasing outbox lease: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending delivers up to BatchSize events that are due, oldest first,
+// if no other replica holds the lease. It returns the number of events that
+// every subscriber has now handled.
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	now := d.now()
+	held, err := d.db.AcquireLease(ctx, leaseName, d.holder, now, d.opts.LeaseTTL)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	var entries []database.OutboxEntry
+	filter := bson.M{"status": database.OutboxPending, "nextattemptat": bson.M{"$lte": now}}
+	opts := database.FindOptions{Limit: d.opts.BatchSize, Sort: bson.D{{Key: "occurredat", Value: 1}, {Key: "_id", Value: 1}}}
+	if _, err := d.db.FindPage(ctx, database.OutboxCollection, filter, opts, &entries); err != nil {
+		return 0, fmt.Errorf("failed to find pending events: %w", err)
+	}
+
+	delivered := 0
+	var errs []error
+	for _, entry := range entries {
+		ok, err := d.dispatch(ctx, entry, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to dispatch event %s: %w", entry.ID.Hex(), err))
+			continue
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// dispatch delivers entry to the subscribers that have not handled it yet and
+// records the outcome. It reports whether every subscriber has now handled
+// the event. Only failing to record the outcome is returned as an error.
+func (d *Dispatcher) dispatch(ctx context.Context, entry database.OutboxEntry, now time.Time) (bool, error) {
+	attempt := entry.Attempts + 1
+	update := bson.M{"attempts": attempt}
+
+	payload, err := entry.Event()
+	if err != nil {
+		// Retrying cannot decode the event
+		update["status"] = database.OutboxDead
+		update["lasterror"] = err.Error()
+		log.Printf("Dead-lettered outbox event %s: %v", entry.ID.Hex(), err)
+		return false, d.db.Update(ctx, database.OutboxCollection, entry.ID.Hex(), update)
+	}
+
+	event := Event{ID: entry.ID, OccurredAt: entry.OccurredAt, Attempt: attempt, Payload: payload}
+	deliveredTo := entry.DeliveredTo
+	var errs []error
+	for _, sub := range d.pending(entry) {
+		if err := deliver(ctx, sub, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		deliveredTo = append(deliveredTo, sub.name)
+	}
+	update["deliveredto"] = deliveredTo
+
+	switch {
+	case len(errs) == 0:
+		update["status"] = database.OutboxDelivered
+	case attempt >= d.opts.MaxAttempts:
+		update["status"] = database.OutboxDead
+		update["lasterror"] = errors.Join(errs...).Error()
+		log.Printf("Dead-lettered outbox event %s after %d attempts: %v", entry.ID.Hex(), attempt, errors.Join(errs...))
+	default:
+		update["nextattemptat"] = now.Add(d.backoff(attempt))
+		update["lasterror"] = errors.Join(errs...).Error()
+	}
+	if err := d.db.Update(ctx, database.OutboxCollection, entry.ID.Hex(), update); err != nil {
+		return false, err
+	}
+	return len(errs) == 0, nil
+}
+
+// pending returns the subscriptions for entry's type that have not handled it.
+func (d *Dispatcher) pending(entry database.OutboxEntry) []subscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var pending []subscription
+	for _, sub := range d.subscriptions {
+		if sub.wants(entry.Type) && !contains(entry.DeliveredTo, sub.name) {
+			pending = append(pending, sub)
+		}
+	}
+	return pending
+}
+
+// backoff returns the wait after the given failed attempt.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.Backoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+// deliver hands event to sub, turning a panic into an error so that one
+// faulty subscriber does not stop the delivery of other events.
+func deliver(ctx context.Context, sub subscription, event Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return sub.handle(ctx, event)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Log is a Subscriber that writes events to the standard logger. It is meant
+// for local development.
+func Log(ctx context.Context, event Event) error {
+	encoded, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+	log.Printf("Domain event %s %s: %s", event.ID.Hex(), event.Payload.EventType(), encoded)
+	return nil
+}
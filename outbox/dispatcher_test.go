@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// outboxTest is a Dispatcher on a fresh memory store with a fake clock.
+type outboxTest struct {
+	t          *testing.T
+	store      *database.MemoryStore
+	dispatcher *Dispatcher
+	now        *time.Time
+}
+
+func newOutboxTest(t *testing.T, opts Options) *outboxTest {
+	t.Helper()
+	store := database.NewMemoryStore()
+
+	// Start at the real time: the outbox records events at it
+	now := time.Now().UTC().Add(time.Second).Truncate(time.Millisecond)
+	dispatcher := NewDispatcher(store, opts)
+	dispatcher.now = func() time.Time { return now }
+	return &outboxTest{t: t, store: store, dispatcher: dispatcher, now: &now}
+}
+
+// review creates a review, recording ReviewCreated in the outbox.
+func (o *outboxTest) review() structure.Review {
+	o.t.Helper()
+	review := structure.Review{JobID: primitive.NewObjectID(), Review: "Great work", Quality: 5}
+	if err := o.store.CreateReview(context.Background(), &review); err != nil {
+		o.t.Fatalf("Failed to create review: %v", err)
+	}
+	return review
+}
+
+// dispatch delivers the pending events, returning how many were delivered.
+func (o *outboxTest) dispatch() int {
+	o.t.Helper()
+	delivered, err := o.dispatcher.DispatchPending(context.Background())
+	if err != nil {
+		o.t.Fatalf("Failed to dispatch events: %v", err)
+	}
+	return delivered
+}
+
+// entry returns the only entry in the outbox.
+func (o *outboxTest) entry() database.OutboxEntry {
+	o.t.Helper()
+	var entries []database.OutboxEntry
+	if err := o.store.Find(context.Background(), database.OutboxCollection, bson.M{}, &entries); err != nil {
+		o.t.Fatalf("Failed to find outbox entries: %v", err)
+	}
+	if len(entries) != 1 {
+		o.t.Fatalf("Expected one outbox entry, got %d", len(entries))
+	}
+	return entries[0]
+}
+
+func TestDispatcherDeliversToSubscribers(t *testing.T) {
+	// Arrange
+	o := newOutboxTest(t, DefaultOptions())
+	var all, reviews []Event
+	o.dispatcher.Subscribe("all", func(ctx context.Context, event Event) error {
+		all = append(all, event)
+		return nil
+	})
+	o.dispatcher.Subscribe("reviews", func(ctx context.Context, event Event) error {
+		reviews = append(reviews, event)
+		return nil
+	}, structure.EventReviewCreated)
+	review := o.review()
+	job := structure.Job{Title: "Fix the roof", JobStatus: structure.JobStatusJobPosted}
+	o.store.Create(context.Background(), "job", &job)
+	transition, _ := job.Transition(structure.JobStatusCancelled, primitive.NilObjectID, time.Now(), "")
+	if err := o.store.MoveJob(context.Background(), job.ID.Hex(), transition, bson.M{"jobstatus": transition.To}); err != nil {
+		t.Fatalf("Failed to move job: %v", err)
+	}
+
+	// Act
+	delivered := o.dispatch()
+	again := o.dispatch()
+
+	// Assert
+	if delivered != 2 || again != 0 {
+		t.Errorf("Expected 2 events delivered once, delivered %d then %d", delivered, again)
+	}
+	if len(all) != 2 || all[0].Attempt != 1 || all[1].Payload.EventType() != structure.EventJobStatusChanged {
+		t.Errorf("Expected both events in order, got %+v", all)
+	}
+	if len(reviews) != 1 || reviews[0].Payload.(structure.ReviewCreated).ReviewID != review.ID || reviews[0].ID != all[0].ID {
+		t.Errorf("Expected only the review event, got %+v", reviews)
+	}
+}
+
+func TestDispatcherRetriesFailedSubscriber(t *testing.T) {
+	// Arrange
+	o := newOutboxTest(t, DefaultOptions())
+	var handled, attempts []int
+	o.dispatcher.Subscribe("steady", func(ctx context.Context, event Event) error {
+		handled = append(handled, event.Attempt)
+		return nil
+	})
+	o.dispatcher.Subscribe("flaky", func(ctx context.Context, event Event) error {
+		attempts = append(attempts, event.Attempt)
+		if event.Attempt == 1 {
+			return errors.New("search index unavailable")
+		}
+		return nil
+	})
+	o.review()
+
+	// Act: the first attempt fails, and the retry waits for the backoff
+	retryAt := o.now.Add(time.Second)
+	first := o.dispatch()
+	failed := o.entry()
+	early := o.dispatch()
+	*o.now = retryAt
+	retried := o.dispatch()
+
+	// Assert
+	if first != 0 || early != 0 || retried != 1 {
+		t.Errorf("Expected the event to be delivered on the retry, delivered %d, %d then %d", first, early, retried)
+	}
+	if failed.Status != database.OutboxPending || failed.LastError == "" || !failed.NextAttemptAt.Equal(retryAt) {
+		t.Errorf("Expected the event to be retried after the backoff, got %+v", failed)
+	}
+	if len(handled) != 1 || len(attempts) != 2 || attempts[1] != 2 {
+		t.Errorf("Expected only the failed subscriber to be retried, got %v and %v", handled, attempts)
+	}
+	if entry := o.entry(); entry.Status != database.OutboxDelivered || len(entry.DeliveredTo) != 2 {
+		t.Errorf("Expected the event to be delivered to both subscribers, got %+v", entry)
+	}
+}
+
+func TestDispatcherDeadLettersEvent(t *testing.T) {
+	// Arrange
+	opts := DefaultOptions()
+	opts.MaxAttempts = 3
+	o := newOutboxTest(t, opts)
+	calls := 0
+	o.dispatcher.Subscribe("broken", func(ctx context.Context, event Event) error {
+		calls++
+		panic("nil map")
+	})
+	o.review()
+
+	// Act
+	for i := 0; i < 5; i++ {
+		o.dispatch()
+		*o.now = o.now.Add(opts.MaxBackoff)
+	}
+
+	// Assert
+	entry := o.entry()
+	if calls != 3 || entry.Status != database.OutboxDead || entry.Attempts != 3 {
+		t.Errorf("Expected the event to be dead-lettered after 3 attempts, got %d calls and %+v", calls, entry)
+	}
+	if entry.LastError != "broken: panic: nil map" {
+		t.Errorf("Expected the last error to be kept, got %q", entry.LastError)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(database.NewMemoryStore(), Options{Backoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("Expected a backoff of %v after attempt %d, got %v", want, attempt, got)
+		}
+	}
+}
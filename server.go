@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"Go-sumon/config"
+	"Go-sumon/fileuploader"
+	"Go-sumon/handler"
+)
+
+// newServers returns the API server and, if cfg.UploadAddr is set, a
+// separate upload server. Each server has its own mux, so no listener serves
+// another's routes.
+func newServers(cfg config.Server) []*http.Server {
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+	servers := []*http.Server{newServer(cfg, cfg.Addr, mux)}
+
+	uploadMux := mux
+	if cfg.UploadAddr != "" && cfg.UploadAddr != cfg.Addr {
+		uploadMux = http.NewServeMux()
+		servers = append(servers, newServer(cfg, cfg.UploadAddr, uploadMux))
+	}
+	uploadMux.HandleFunc("POST /upload", fileuploader.UploadFile)
+
+	return servers
+}
+
+// newServer returns an http.Server for mux on addr with the configured timeouts.
+func newServer(cfg config.Server, addr string, mux *http.ServeMux) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           enableCors(mux.ServeHTTP),
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+	}
+}
+
+// serve runs the servers until ctx is done or one of them fails, then shuts
+// all of them down, letting in-flight requests finish within shutdownTimeout.
+func serve(ctx context.Context, servers []*http.Server, shutdownTimeout time.Duration) error {
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			log.Printf("Server listening on %s...", server.Addr)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("server on %s failed: %w", server.Addr, err)
+			}
+		}(server)
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down, draining in-flight requests...")
+	case serveErr = <-errs:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			serveErr = errors.Join(serveErr, fmt.Errorf("failed to shut down server on %s: %w", server.Addr, err))
+		}
+	}
+	return serveErr
+}
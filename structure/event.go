@@ -0,0 +1,79 @@
+package structure
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventType names a kind of DomainEvent.
+type EventType string
+
+const (
+	EventBidPlaced        EventType = "bid_placed"
+	EventBidAccepted      EventType = "bid_accepted"
+	EventJobStatusChanged EventType = "job_status_changed"
+	EventReviewCreated    EventType = "review_created"
+	EventUserRegistered   EventType = "user_registered"
+)
+
+// EventTypes lists every EventType.
+var EventTypes = []EventType{EventBidPlaced, EventBidAccepted, EventJobStatusChanged, EventReviewCreated, EventUserRegistered}
+
+// Known reports whether t is one of the EventTypes.
+func (t EventType) Known() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainEvent is a change to the marketplace that other parts of the system
+// react to. The database records it in the same write as the change.
+type DomainEvent interface {
+	EventType() EventType
+}
+
+// BidPlaced is recorded when a service provider bids on an open job.
+type BidPlaced struct {
+	BidID             primitive.ObjectID `json:"bidId" bson:"bidid"`
+	JobID             primitive.ObjectID `json:"jobId" bson:"jobid"`
+	ServiceProviderID primitive.ObjectID `json:"serviceProviderId" bson:"serviceproviderid"`
+	BidAmount         Money              `json:"bidAmount" bson:"bidamount"`
+}
+
+// BidAccepted is recorded when a job's bid is accepted, assigning the job to
+// the service provider who made it.
+type BidAccepted struct {
+	BidID             primitive.ObjectID `json:"bidId" bson:"bidid"`
+	JobID             primitive.ObjectID `json:"jobId" bson:"jobid"`
+	ServiceProviderID primitive.ObjectID `json:"serviceProviderId" bson:"serviceproviderid"`
+	Actor             primitive.ObjectID `json:"actor,omitempty" bson:"actor,omitempty"` // zero when the auction scheduler accepted it
+}
+
+// JobStatusChanged is recorded when a job moves to another status.
+type JobStatusChanged struct {
+	JobID         primitive.ObjectID `json:"jobId" bson:"jobid"`
+	JobTransition `bson:",inline"`
+}
+
+// ReviewCreated is recorded when a client reviews the service provider who
+// did their job.
+type ReviewCreated struct {
+	ReviewID   primitive.ObjectID `json:"reviewId" bson:"reviewid"`
+	JobID      primitive.ObjectID `json:"jobId,omitempty" bson:"jobid,omitempty"`
+	ReviewerID primitive.ObjectID `json:"reviewerId,omitempty" bson:"reviewerid,omitempty"`
+	RevieweeID primitive.ObjectID `json:"revieweeId,omitempty" bson:"revieweeid,omitempty"`
+}
+
+// UserRegistered is recorded when a user signs up.
+type UserRegistered struct {
+	UserID   primitive.ObjectID `json:"userId" bson:"userid"`
+	UserType UserType           `json:"userType" bson:"usertype"`
+}
+
+func (BidPlaced) EventType() EventType        { return EventBidPlaced }
+func (BidAccepted) EventType() EventType      { return EventBidAccepted }
+func (JobStatusChanged) EventType() EventType { return EventJobStatusChanged }
+func (ReviewCreated) EventType() EventType    { return EventReviewCreated }
+func (UserRegistered) EventType() EventType   { return EventUserRegistered }
@@ -0,0 +1,77 @@
+package structure
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// jobTransitions lists the statuses each job status may move to. Cancelled
+// and banned jobs are final, except that an admin may still ban a cancelled
+// job.
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobStatusJobPosted:   {JobStatusBidAccepted, JobStatusCancelled, JobStatusBan},
+	JobStatusBidAccepted: {JobStatusJobStarted, JobStatusCancelled, JobStatusDisputed, JobStatusBan},
+	JobStatusJobStarted:  {JobStatusCompleted, JobStatusDisputed, JobStatusBan},
+	JobStatusCompleted:   {JobStatusDisputed, JobStatusBan},
+	JobStatusDisputed:    {JobStatusCompleted, JobStatusCancelled, JobStatusBan},
+	JobStatusCancelled:   {JobStatusBan},
+	JobStatusBan:         nil,
+}
+
+// Known reports whether s is one of the job statuses.
+func (s JobStatus) Known() bool {
+	_, ok := jobTransitions[s]
+	return ok
+}
+
+// Next returns the statuses a job in status s may move to. Jobs stored
+// before statuses were enforced have no status and move like posted jobs.
+func (s JobStatus) Next() []JobStatus {
+	if s == "" {
+		s = JobStatusJobPosted
+	}
+	return jobTransitions[s]
+}
+
+// CanMoveTo reports whether a job in status s may move to status to.
+func (s JobStatus) CanMoveTo(to JobStatus) bool {
+	for _, next := range s.Next() {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// JobTransition records one change of a job's status in its history.
+type JobTransition struct {
+	From   JobStatus          `json:"from,omitempty" bson:"from,omitempty"` // empty when the job was posted
+	To     JobStatus          `json:"to" bson:"to"`
+	Actor  primitive.ObjectID `json:"actor,omitempty" bson:"actor,omitempty"` // the user who made the change
+	At     time.Time          `json:"at" bson:"at"`
+	Reason string             `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// TransitionError is returned when a job may not move between two statuses.
+type TransitionError struct {
+	From, To JobStatus
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = JobStatusJobPosted
+	}
+	return fmt.Sprintf("a job in status %s cannot move to %s", from, e.To)
+}
+
+// Transition checks that the job may move to status to and returns the
+// history entry recording the move, made by actor at time at.
+func (j *Job) Transition(to JobStatus, actor primitive.ObjectID, at time.Time, reason string) (JobTransition, error) {
+	if !j.JobStatus.CanMoveTo(to) {
+		return JobTransition{}, &TransitionError{From: j.JobStatus, To: to}
+	}
+	return JobTransition{From: j.JobStatus, To: to, Actor: actor, At: at.UTC(), Reason: reason}, nil
+}
@@ -0,0 +1,93 @@
+package structure
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EntryKind names what a JournalEntry does with a job's escrow.
+type EntryKind string
+
+const (
+	// EntryHold moves the accepted bid's amount from the client into the
+	// job's escrow.
+	EntryHold EntryKind = "hold"
+	// EntryRelease pays the job's escrow to its service provider once the
+	// job is completed.
+	EntryRelease EntryKind = "release"
+	// EntryRefund returns the job's escrow to its client once the job is
+	// cancelled or banned.
+	EntryRefund EntryKind = "refund"
+)
+
+// ClientAccount is the ledger account of a client. Clients pay for a job
+// outside the ledger, which records no deposits, so the account starts at
+// zero and goes negative by the money held in escrow for the client's jobs
+// and not refunded: it is what the client owes, not money they hold.
+func ClientAccount(id primitive.ObjectID) string {
+	return "client:" + id.Hex()
+}
+
+// EscrowAccount is the ledger account holding a job's money until the job
+// is completed or cancelled.
+func EscrowAccount(jobID primitive.ObjectID) string {
+	return "escrow:" + jobID.Hex()
+}
+
+// ProviderAccount is the ledger account of a service provider, whose balance
+// is the money released to them.
+func ProviderAccount(id primitive.ObjectID) string {
+	return "provider:" + id.Hex()
+}
+
+// Posting moves Amount into Account, or out of it when Amount is negative.
+type Posting struct {
+	Account string `json:"account" bson:"account"`
+	Amount  Money  `json:"amount" bson:"amount"`
+}
+
+// JournalEntry is one movement of money in the escrow ledger. Entries are
+// never changed once written, and their postings always sum to zero. Key
+// makes each movement of a job happen at most once.
+type JournalEntry struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Key       string             `json:"key" bson:"key"` // the job's ID and the entry's kind
+	Kind      EntryKind          `json:"kind" bson:"kind"`
+	JobID     primitive.ObjectID `json:"jobId" bson:"jobid"`
+	Postings  []Posting          `json:"postings" bson:"postings"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdat"`
+}
+
+// JournalKey is the key of the entry of the given kind for the job with ID
+// jobID.
+func JournalKey(jobID primitive.ObjectID, kind EntryKind) string {
+	return jobID.Hex() + ":" + string(kind)
+}
+
+// Validate checks that the JournalEntry moves money between at least two
+// accounts and that its postings balance.
+func (e *JournalEntry) Validate() error {
+	var errs fieldErrors
+	if e.Key == "" {
+		errs.add("key", CodeRequired, "cannot be empty")
+	}
+	if len(e.Postings) < 2 {
+		errs.add("postings", CodeRequired, "must have at least two postings")
+	}
+	var sum Money
+	for i, posting := range e.Postings {
+		if posting.Account == "" {
+			errs.add(fmt.Sprintf("postings[%d].account", i), CodeRequired, "cannot be empty")
+		}
+		if posting.Amount == 0 {
+			errs.add(fmt.Sprintf("postings[%d].amount", i), CodeOutOfRange, "must not be zero")
+		}
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		errs.add("postings", CodeInvalidValue, "must sum to zero, not %s", sum)
+	}
+	return errs.err()
+}
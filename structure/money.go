@@ -0,0 +1,112 @@
+package structure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ErrInvalidMoney is returned when parsing an amount that is not a decimal
+// number of taka with at most two decimal places.
+var ErrInvalidMoney = errors.New("amount must be a number of taka with at most two decimal places")
+
+// Money is an amount of taka, held exactly as a whole number of paisa. It is
+// written to JSON as a decimal number of taka, such as 1500.50, and stored in
+// MongoDB as an int64 of paisa.
+type Money int64
+
+// Taka returns n whole taka.
+func Taka(n int64) Money {
+	return Money(n * 100)
+}
+
+// ParseMoney parses a decimal number of taka, such as "1500" or "-3.05".
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || len(fraction) > 2 || !isDigits(whole) || !isDigits(fraction) {
+		return 0, ErrInvalidMoney
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	taka, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || taka > (math.MaxInt64-99)/100 {
+		return 0, ErrInvalidMoney
+	}
+	paisa, _ := strconv.ParseInt(fraction, 10, 64)
+	m := Money(taka*100 + paisa)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns m in taka with two decimal places, such as "1500.50".
+func (m Money) String() string {
+	sign := ""
+	paisa := int64(m)
+	if paisa < 0 {
+		sign = "-"
+		paisa = -paisa
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, paisa/100, paisa%100)
+}
+
+// MarshalJSON writes m as a decimal number of taka.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a number of taka, or a string holding one.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	parsed, err := ParseMoney(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalBSONValue stores m as an int64 of paisa.
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(int64(m))
+}
+
+// UnmarshalBSONValue reads an integer of paisa. Amounts stored as doubles,
+// as they were before Money, are read as taka and rounded to the paisa.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Int64:
+		*m = Money(value.Int64())
+	case bsontype.Int32:
+		*m = Money(value.Int32())
+	case bsontype.Double:
+		*m = Money(math.Round(value.Double() * 100))
+	case bsontype.Null, bsontype.Undefined:
+		*m = 0
+	default:
+		return fmt.Errorf("cannot decode %v into an amount", t)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package structure
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Channel is a way of sending a Notification to a user.
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelEmail Channel = "email"
+	ChannelInApp Channel = "in_app" // the user's inbox in the app
+)
+
+// Language is the language notifications are written in.
+type Language string
+
+const (
+	LanguageBangla  Language = "bn"
+	LanguageEnglish Language = "en"
+)
+
+// NotificationKind names what a Notification tells the user, and the
+// template it is written from.
+type NotificationKind string
+
+const (
+	NotificationWelcome          NotificationKind = "welcome"            // to a user who signed up
+	NotificationBidPlaced        NotificationKind = "bid_placed"         // to the client, about a bid on their job
+	NotificationBidWon           NotificationKind = "bid_won"            // to the service provider whose bid was accepted
+	NotificationChooseWinner     NotificationKind = "choose_winner"      // to the client, when bidding on a manual job closes
+	NotificationJobStatusChanged NotificationKind = "job_status_changed" // to the client and service provider of a job
+	NotificationReviewReceived   NotificationKind = "review_received"    // to the service provider a review is about
+)
+
+// Notification is a message in a user's inbox.
+type Notification struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userid"`
+	Kind      NotificationKind   `json:"kind" bson:"kind"`
+	Title     string             `json:"title" bson:"title"`
+	Body      string             `json:"body" bson:"body"`
+	JobID     primitive.ObjectID `json:"jobId,omitempty" bson:"jobid,omitempty"`     // the job it is about, if any
+	EventID   primitive.ObjectID `json:"eventId,omitempty" bson:"eventid,omitempty"` // the domain event it was sent for, if any
+	Read      bool               `json:"read" bson:"read"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdat"`
+}
+
+// NotificationPreferences are how a user wants to be notified, stored with
+// the user's ID. A user without preferences is notified with
+// DefaultNotificationPreferences.
+type NotificationPreferences struct {
+	ID       primitive.ObjectID `json:"userId" bson:"_id"` // the user's
+	Language Language           `json:"language" bson:"language"`
+	Channels []Channel          `json:"channels" bson:"channels"` // empty to be sent nothing
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who has
+// not chosen any: English, by SMS and in the app.
+func DefaultNotificationPreferences(userID primitive.ObjectID) NotificationPreferences {
+	return NotificationPreferences{ID: userID, Language: LanguageEnglish, Channels: []Channel{ChannelSMS, ChannelInApp}}
+}
+
+// Wants reports whether the preferences include channel.
+func (p NotificationPreferences) Wants(channel Channel) bool {
+	for _, wanted := range p.Channels {
+		if wanted == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the language is Bangla or English and that every
+// channel is known.
+func (p *NotificationPreferences) Validate() error {
+	var errs fieldErrors
+	switch p.Language {
+	case LanguageBangla, LanguageEnglish:
+	case "":
+		errs.add("language", CodeRequired, "cannot be empty")
+	default:
+		errs.add("language", CodeInvalidValue, "must be %q or %q", LanguageBangla, LanguageEnglish)
+	}
+	for i, channel := range p.Channels {
+		switch channel {
+		case ChannelSMS, ChannelEmail, ChannelInApp:
+		default:
+			errs.add(fmt.Sprintf("channels[%d]", i), CodeInvalidValue, "must be %q, %q or %q", ChannelSMS, ChannelEmail, ChannelInApp)
+		}
+	}
+	return errs.err()
+}
@@ -1,7 +1,7 @@
 package structure
 
 import (
-	"errors"
+	"fmt"
 	"regexp"
 	"time"
 
@@ -13,6 +13,7 @@ type User struct {
 	UserID      int                `json:"userId"`
 	Name        string             `json:"name"`
 	PhoneNumber string             `json:"phoneNumber"`
+	Email       string             `json:"email,omitempty" bson:"email,omitempty"` // where email notifications are sent
 	NID         string             `json:"nid"`
 	Birthdate   string             `json:"birthdate"`
 	FatherName  string             `json:"fatherName"`
@@ -24,24 +25,34 @@ type User struct {
 var (
     phoneRegex = regexp.MustCompile(`^0[0-9]{10}$`) // Matches 11-digit phone numbers starting with 0
     nidRegex   = regexp.MustCompile(`^[0-9]{13}$`)  // Matches 13-digit NID numbers
+    emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`) // Matches addresses such as name@example.com
 )
 
-// userValidation validates the User struct fields.
-func (u *User) userValidation() error {
-    if u.UserID <= 0 {
-        return errors.New("UserID must be greater than zero")
+// Validate checks the User's fields. UserID is assigned by the server, so
+// only a negative UserID is rejected.
+func (u *User) Validate() error {
+    var errs fieldErrors
+    if u.UserID < 0 {
+        errs.add("userId", CodeOutOfRange, "must not be negative")
     }
     if u.Name == "" {
-        return errors.New("Name cannot be empty")
+        errs.add("name", CodeRequired, "cannot be empty")
     }
     if u.PhoneNumber != "" && !phoneRegex.MatchString(u.PhoneNumber) {
-        return errors.New("Invalid phone number format")
+        errs.add("phoneNumber", CodeInvalidFormat, "must be 11 digits starting with 0")
+    }
+    if u.Email != "" && !emailRegex.MatchString(u.Email) {
+        errs.add("email", CodeInvalidFormat, "must be an email address")
     }
     if u.NID != "" && !nidRegex.MatchString(u.NID) {
-        return errors.New("Invalid NID format")
+        errs.add("nid", CodeInvalidFormat, "must be 13 digits")
+    }
+    switch u.UserType {
+    case "", UserTypeClient, UserTypeServiceProvider, UserTypeAdmin:
+    default:
+        errs.add("userType", CodeInvalidValue, "must be %q, %q or %q", UserTypeClient, UserTypeServiceProvider, UserTypeAdmin)
     }
-    // You can add more validation rules as needed for other fields
-    return nil
+    return errs.err()
 }
 
 // func user_validation(){
@@ -49,12 +60,20 @@ func (u *User) userValidation() error {
 // }
 
 type Client struct {
-	
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"` // same as User.ID
 	User             User               `json:"user"`
 	Location           string             `json:"location"`
 }
 
+// Validate checks the Client's fields, including its user.
+func (c *Client) Validate() error {
+	var errs fieldErrors
+	errs.nested("user", c.User.Validate())
+	return errs.err()
+}
+
 type ServiceProvider struct {
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"` // same as User.ID
 	User               User               `json:"user"`
 	Skill              string             `json:"skill"`
 	Location           string             `json:"location"`
@@ -63,17 +82,32 @@ type ServiceProvider struct {
 	SPBalance          Balance            `json:"Balance"`
 }
 
+// Validate checks the ServiceProvider's fields, including its user.
+func (sp *ServiceProvider) Validate() error {
+	var errs fieldErrors
+	errs.nested("user", sp.User.Validate())
+	if sp.SPBalance.Amount < 0 {
+		errs.add("Balance.amount", CodeOutOfRange, "must not be negative")
+	}
+	return errs.err()
+}
+
 type Education struct {
 	Level     string `json:"level"`
 	Institute string `json:"institute"`
 }
 
+// Balance is a service provider's available money. It is derived from the
+// escrow ledger rather than written through the API.
 type Balance struct {
-	Amount float64 `json:"amount"`
+	Amount Money `json:"amount"`
 }
 
 type Review struct {
 	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	JobID         primitive.ObjectID `json:"jobId,omitempty" bson:"jobid,omitempty"`
+	ReviewerID    primitive.ObjectID `json:"reviewerId,omitempty" bson:"reviewerid,omitempty"` // the client, set from the reviewer's session
+	RevieweeID    primitive.ObjectID `json:"revieweeId,omitempty" bson:"revieweeid,omitempty"` // the service provider who did the job
 	Review        string             `json:"review" bson:"review"`
 	Timelines     float64            `json:"timelines" bson:"timelines"`
 	Quality       float64            `json:"quality" bson:"quality"`
@@ -81,25 +115,75 @@ type Review struct {
 	Behavior      float64            `json:"behavior" bson:"behavior"`
 }
 
+// Validate checks that every rating of the Review is between 0 and 5.
+func (r *Review) Validate() error {
+	var errs fieldErrors
+	errs.inRange("timelines", r.Timelines, 0, 5)
+	errs.inRange("quality", r.Quality, 0, 5)
+	errs.inRange("communication", r.Communication, 0, 5)
+	errs.inRange("behavior", r.Behavior, 0, 5)
+	return errs.err()
+}
+
 type Bid struct {
 	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	Description string             `json:"description"`
 	Time        string             `json:"t_time"`
-	BidAmount   float64            `json:"bidAmount"`
+	BidAmount   Money              `json:"bidAmount"`
 	PostedTime  time.Time          `json:"postedTime,omitempty" bson:"postedTime,omitempty"`
+	JobID       primitive.ObjectID `json:"jobId,omitempty" bson:"jobid,omitempty"`
+	SPID        primitive.ObjectID `json:"serviceProviderId,omitempty" bson:"serviceproviderid,omitempty"` // set from the bidder's session
+	Status      Status             `json:"status,omitempty" bson:"status,omitempty"`                        // pending until the job's client decides
+	Sealed      bool               `json:"sealed,omitempty" bson:"-"`                                       // set in responses that hide the amount and description
+}
+
+// Decided reports whether the job's client has accepted or rejected the Bid.
+func (b *Bid) Decided() bool {
+	return b.Status == StatusAccepted || b.Status == StatusRejected
+}
+
+// Validate checks the Bid's fields.
+func (b *Bid) Validate() error {
+	var errs fieldErrors
+	if b.Description == "" {
+		errs.add("description", CodeRequired, "cannot be empty")
+	}
+	if b.BidAmount <= 0 {
+		errs.add("bidAmount", CodeOutOfRange, "must be greater than zero")
+	}
+	switch b.Status {
+	case "", StatusPending, StatusAccepted, StatusRejected:
+	default:
+		errs.add("status", CodeInvalidValue, "unknown status %q", b.Status)
+	}
+	return errs.err()
 }
 
 type Payment struct {
 	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	SPID       string             `json:"sp_id"`
-	Balance    float64            `json:"balance"`
+	Balance    Money              `json:"balance"`
 	CashinDate time.Time          `json:"cashinDate,omitempty" bson:"cashinDate,omitempty"`
 	FirstJob   time.Time          `json:"firstJob,omitempty" bson:"firstJob,omitempty"`
 }
 
+// Validate checks the Payment's fields.
+func (p *Payment) Validate() error {
+	var errs fieldErrors
+	if p.SPID == "" {
+		errs.add("sp_id", CodeRequired, "cannot be empty")
+	}
+	if p.Balance < 0 {
+		errs.add("balance", CodeOutOfRange, "must not be negative")
+	}
+	return errs.err()
+}
+
+// GpsCoordinate keeps Longitude first so that MongoDB's 2dsphere index reads
+// the stored coordinate pair correctly.
 type GpsCoordinate struct {
-	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
 }
 
 type Point struct {
@@ -111,11 +195,28 @@ type Point struct {
 	ContactPersonPhoneNumber string             `json:"contactPersonPhoneNumber"`
 }
 
+// Validate checks the Point's fields and GPS coordinates.
+func (p *Point) Validate() error {
+	var errs fieldErrors
+	if p.Title == "" {
+		errs.add("title", CodeRequired, "cannot be empty")
+	}
+	for i, coordinate := range p.Gps {
+		errs.inRange(fmt.Sprintf("gps[%d].longitude", i), coordinate.Longitude, -180, 180)
+		errs.inRange(fmt.Sprintf("gps[%d].latitude", i), coordinate.Latitude, -90, 90)
+	}
+	if p.ContactPersonPhoneNumber != "" && !phoneRegex.MatchString(p.ContactPersonPhoneNumber) {
+		errs.add("contactPersonPhoneNumber", CodeInvalidFormat, "must be 11 digits starting with 0")
+	}
+	return errs.err()
+}
+
 type UserType string
 
 const (
 	UserTypeClient          UserType = "client"
 	UserTypeServiceProvider UserType = "serviceProvider"
+	UserTypeAdmin           UserType = "admin" // moderates jobs; cannot be chosen at signup
 )
 
 type Status string
@@ -139,6 +240,9 @@ const (
 	JobStatusJobPosted   JobStatus = "job_posted"
 	JobStatusBidAccepted JobStatus = "bid_accepted"
 	JobStatusJobStarted  JobStatus = "job_started"
+	JobStatusCompleted   JobStatus = "job_completed"
+	JobStatusCancelled   JobStatus = "job_cancelled"
+	JobStatusDisputed    JobStatus = "job_disputed"
 	JobStatusBan         JobStatus = "ban"
 )
 
@@ -149,7 +253,21 @@ const (
 	StatusChangeAcceptanceTime StatusChange = "acceptance_time"
 )
 
+// AuctionMode decides who sees the bids on a job while it takes bids.
+type AuctionMode string
 
+const (
+	// AuctionModeOpen shows every bid to everyone. It is the default.
+	AuctionModeOpen AuctionMode = "open"
+	// AuctionModeSealed shows the amount and description of a bid only to
+	// its bidder until bidding on the job closes.
+	AuctionModeSealed AuctionMode = "sealed"
+)
+
+
+// Job embeds copies of its client and service provider as they were when
+// the job was posted and accepted. Queries use ClientID and
+// ServiceProviderID, the users' IDs, instead.
 type Job struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title            string             `json:"title"`
@@ -160,11 +278,62 @@ type Job struct {
 	ServiceProviders ServiceProvider    `json:"serviceProviders"`
 	Status           Status             `json:"status"`
 	JobStatus        JobStatus          `json:"jobStatus"`
-	StatusChange    []StatusChange       `json:"statusChange"`
+	StatusChange    []StatusChange       `json:"statusChange"` // superseded by History
 	Point            []Point            `json:"point,omitempty"`
 	QuestionAnswer   []QuestionAnswer   `json:"questionAnswer,omitempty"`
 	Bid              []Bid              `json:"bid,omitempty"`
 	Review           Review           `json:"review,omitempty"`
+	AcceptedBid      primitive.ObjectID `json:"acceptedBid,omitempty" bson:"acceptedbid,omitempty"`
+	ClientID         primitive.ObjectID `json:"clientId,omitempty" bson:"clientid,omitempty"`
+	ServiceProviderID primitive.ObjectID `json:"serviceProviderId,omitempty" bson:"serviceproviderid,omitempty"`
+	History          []JobTransition    `json:"history,omitempty" bson:"history,omitempty"` // every change of JobStatus, oldest first
+	BiddingDeadline  time.Time          `json:"biddingDeadline,omitempty" bson:"biddingdeadline,omitempty"` // no bids are taken from then on
+	BiddingClosed    bool               `json:"biddingClosed,omitempty" bson:"biddingclosed,omitempty"`     // set once the deadline has been handled
+	AuctionMode      AuctionMode        `json:"auctionMode,omitempty" bson:"auctionmode,omitempty"`
+}
+
+// Validate checks the Job's fields and the points, bids and review it holds.
+func (j *Job) Validate() error {
+	var errs fieldErrors
+	if j.Title == "" {
+		errs.add("title", CodeRequired, "cannot be empty")
+	}
+	switch j.Status {
+	case "", StatusPending, StatusAccepted, StatusRejected:
+	default:
+		errs.add("status", CodeInvalidValue, "unknown status %q", j.Status)
+	}
+	if j.JobStatus != "" && !j.JobStatus.Known() {
+		errs.add("jobStatus", CodeInvalidValue, "unknown job status %q", j.JobStatus)
+	}
+	switch j.AuctionMode {
+	case "", AuctionModeOpen:
+	case AuctionModeSealed:
+		if j.BiddingDeadline.IsZero() {
+			errs.add("biddingDeadline", CodeRequired, "is required for sealed bidding")
+		}
+	default:
+		errs.add("auctionMode", CodeInvalidValue, "must be %q or %q", AuctionModeOpen, AuctionModeSealed)
+	}
+	for i, change := range j.StatusChange {
+		if change != StatusChangePostingTime && change != StatusChangeAcceptanceTime {
+			errs.add(fmt.Sprintf("statusChange[%d]", i), CodeInvalidValue, "unknown status change %q", change)
+		}
+	}
+	for i := range j.Point {
+		errs.nested(fmt.Sprintf("point[%d]", i), j.Point[i].Validate())
+	}
+	for i := range j.Bid {
+		errs.nested(fmt.Sprintf("bid[%d]", i), j.Bid[i].Validate())
+	}
+	errs.nested("review", j.Review.Validate())
+	return errs.err()
+}
+
+// BiddingOver reports whether bidding on the Job has been closed or its
+// bidding deadline has passed by now.
+func (j *Job) BiddingOver(now time.Time) bool {
+	return j.BiddingClosed || (!j.BiddingDeadline.IsZero() && !now.Before(j.BiddingDeadline))
 }
 
 var structureType = []string{"User", "Client", "ServiceProvider", "Review", "Bid", "Payment", "Point", "Job", "QuestionAnswer"}
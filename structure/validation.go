@@ -0,0 +1,73 @@
+package structure
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codes reported in FieldError.Code.
+const (
+	CodeRequired      = "required"
+	CodeInvalidFormat = "invalid_format"
+	CodeInvalidValue  = "invalid_value"
+	CodeOutOfRange    = "out_of_range"
+)
+
+// Validator is implemented by every document that can be written through
+// the API. Validate returns a *ValidationError listing every invalid field.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes one invalid field, named by its JSON path.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by Validate when one or more fields are invalid.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// fieldErrors collects the failures found while validating a document.
+type fieldErrors []FieldError
+
+// add records that field failed with the given code and message.
+func (f *fieldErrors) add(field, code, format string, args ...interface{}) {
+	*f = append(*f, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// nested records the failures of a nested document under prefix.
+func (f *fieldErrors) nested(prefix string, err error) {
+	if validationErr, ok := err.(*ValidationError); ok {
+		for _, fieldErr := range validationErr.Errors {
+			fieldErr.Field = prefix + "." + fieldErr.Field
+			*f = append(*f, fieldErr)
+		}
+	}
+}
+
+// inRange records an out_of_range failure unless min <= value <= max.
+func (f *fieldErrors) inRange(field string, value, min, max float64) {
+	if value < min || value > max {
+		f.add(field, CodeOutOfRange, "must be between %g and %g", min, max)
+	}
+}
+
+// err returns the collected failures as a *ValidationError, or nil.
+func (f fieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: f}
+}
@@ -0,0 +1,82 @@
+package structure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// minWebhookSecretLength is the shortest secret a webhook may sign its
+// deliveries with.
+const minWebhookSecretLength = 16
+
+// Webhook is a partner endpoint that is sent the events it subscribes to.
+type Webhook struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	URL       string             `json:"url" bson:"url"`
+	Events    []EventType        `json:"events" bson:"events"`
+	Secret    string             `json:"secret,omitempty" bson:"secret"` // signs deliveries; never returned
+	Disabled  bool               `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	Failures  int                `json:"failures,omitempty" bson:"failures,omitempty"` // failed deliveries since the last success
+	CreatedBy primitive.ObjectID `json:"createdBy,omitempty" bson:"createdby,omitempty"`
+}
+
+// MarshalJSON leaves out the secret, which is only ever written.
+func (w Webhook) MarshalJSON() ([]byte, error) {
+	type webhook Webhook
+	w.Secret = ""
+	return json.Marshal(webhook(w))
+}
+
+// Validate checks that the Webhook has an absolute http or https URL, at
+// least one known event type and a secret of at least 16 characters.
+func (w *Webhook) Validate() error {
+	var errs fieldErrors
+	if w.URL == "" {
+		errs.add("url", CodeRequired, "cannot be empty")
+	} else if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("url", CodeInvalidFormat, "must be an absolute http or https URL")
+	}
+	if len(w.Events) == 0 {
+		errs.add("events", CodeRequired, "must name at least one event type")
+	}
+	for i, event := range w.Events {
+		if !event.Known() {
+			errs.add(fmt.Sprintf("events[%d]", i), CodeInvalidValue, "unknown event type %q", event)
+		}
+	}
+	if len(w.Secret) < minWebhookSecretLength {
+		errs.add("secret", CodeOutOfRange, "must be at least %d characters", minWebhookSecretLength)
+	}
+	return errs.err()
+}
+
+// DeliveryStatus is the state of a WebhookDelivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // waiting for its first or next attempt
+	DeliverySucceeded DeliveryStatus = "succeeded" // answered with a 2xx status
+	DeliveryFailed    DeliveryStatus = "failed"    // given up on, or its webhook was disabled
+)
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook. The
+// deliveries of a webhook are its delivery log.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	WebhookID      primitive.ObjectID `json:"webhookId" bson:"webhookid"`
+	EventID        primitive.ObjectID `json:"eventId" bson:"eventid"` // the same for every delivery of an event
+	EventType      EventType          `json:"eventType" bson:"eventtype"`
+	Body           string             `json:"body" bson:"body"` // the JSON sent on every attempt
+	Status         DeliveryStatus     `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt,omitempty" bson:"nextattemptat,omitempty"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responsestatus,omitempty"` // of the last attempt
+	LastError      string             `json:"lastError,omitempty" bson:"lasterror,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdat"`
+	DeliveredAt    time.Time          `json:"deliveredAt,omitempty" bson:"deliveredat,omitempty"`
+	ReplayOf       primitive.ObjectID `json:"replayOf,omitempty" bson:"replayof,omitempty"` // the delivery this one replays
+}
@@ -0,0 +1,367 @@
+// Package webhook delivers domain events to the partner endpoints that
+// subscribe to them, signing each delivery and keeping a log of them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/outbox"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// leaseName is the lease a Sender holds while it sends deliveries, so that
+// only one replica sends them at a time.
+const leaseName = "webhook"
+
+// Headers of every delivery. The signature is "sha256=" and the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook's secret.
+const (
+	HeaderEvent     = "X-Sumon-Event"
+	HeaderEventID   = "X-Sumon-Event-Id"
+	HeaderDelivery  = "X-Sumon-Delivery"
+	HeaderTimestamp = "X-Sumon-Timestamp"
+	HeaderSignature = "X-Sumon-Signature"
+)
+
+// Options configures the Sender.
+type Options struct {
+	Interval     time.Duration // time between looks for deliveries that are due
+	BatchSize    int64         // deliveries sent per look
+	Timeout      time.Duration // how long an endpoint may take to answer
+	MaxAttempts  int           // failed attempts before a delivery is given up on
+	Backoff      time.Duration // wait after the first failed attempt, doubled after each further one
+	MaxBackoff   time.Duration // longest wait between attempts
+	DisableAfter int           // failed attempts in a row after which a webhook is disabled
+	LeaseTTL     time.Duration // how long one replica may send deliveries before another may take over
+}
+
+// DefaultOptions returns the options used when no explicit configuration is given.
+func DefaultOptions() Options {
+	return Options{
+		Interval:     5 * time.Second,
+		BatchSize:    50,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		Backoff:      30 * time.Second,
+		MaxBackoff:   time.Hour,
+		DisableAfter: 20,
+		LeaseTTL:     5 * time.Minute,
+	}
+}
+
+// body is the JSON sent to a webhook.
+type body struct {
+	ID         primitive.ObjectID    `json:"id"` // the event's, the same on every delivery of it
+	Type       structure.EventType   `json:"type"`
+	OccurredAt time.Time             `json:"occurredAt"`
+	Data       structure.DomainEvent `json:"data"`
+}
+
+// Sender records a delivery of each domain event to every enabled webhook
+// subscribed to its type, and sends the deliveries that are due, retrying
+// failed ones with exponential backoff. A webhook whose endpoint fails
+// DisableAfter times in a row is disabled. Replicas share the work through a
+// lease in the database.
+type Sender struct {
+	db     database.Database
+	client *http.Client
+	opts   Options
+	holder string // identifies this Sender in the lease
+	now    func() time.Time
+}
+
+// NewSender returns a Sender for the webhooks and deliveries in db.
+func NewSender(db database.Database, opts Options) *Sender {
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d-%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+	return &Sender{db: db, client: &http.Client{Timeout: opts.Timeout}, opts: opts, holder: holder, now: time.Now}
+}
+
+// Enqueue is an outbox.Subscriber that records a pending delivery of event
+// to every enabled webhook subscribed to its type. An event the outbox
+// delivers again is not recorded twice.
+func (s *Sender) Enqueue(ctx context.Context, event outbox.Event) error {
+	eventType := event.Payload.EventType()
+	var webhooks []structure.Webhook
+	filter := bson.M{"events": eventType, "disabled": bson.M{"$ne": true}}
+	if err := s.db.Find(ctx, "webhook", filter, &webhooks); err != nil {
+		return fmt.Errorf("failed to find webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := s.redact(ctx, event.Payload)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(body{ID: event.ID, Type: eventType, OccurredAt: event.OccurredAt, Data: payload})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	now := s.now().UTC()
+	for _, webhook := range webhooks {
+		count, err := s.db.Count(ctx, "webhookDelivery", bson.M{"webhookid": webhook.ID, "eventid": event.ID})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		delivery := structure.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     eventType,
+			Body:          string(encoded),
+			Status:        structure.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		if err := s.db.Create(ctx, "webhookDelivery", &delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redact hides the amount of a bid placed on a sealed job whose bidding has
+// not been closed, as the job's handlers do. A delivery keeps the body it
+// was recorded with, so the amount stays hidden once the bids are revealed.
+func (s *Sender) redact(ctx context.Context, payload structure.DomainEvent) (structure.DomainEvent, error) {
+	placed, ok := payload.(structure.BidPlaced)
+	if !ok {
+		return payload, nil
+	}
+	var job structure.Job
+	err := s.db.Get(ctx, "job", placed.JobID.Hex(), &job)
+	if errors.Is(err, database.ErrNotFound) {
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job.AuctionMode == structure.AuctionModeSealed && job.JobStatus == structure.JobStatusJobPosted && !job.BiddingClosed {
+		placed.BidAmount = 0
+	}
+	return placed, nil
+}
+
+// Run sends the deliveries that are due every Interval until ctx is done,
+// then gives up the lease so another replica can take over at once.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SendPending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error sending webhook deliveries: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.db.ReleaseLease(releaseCtx, leaseName, s.holder); err != nil {
+				log.Printf("Error releasing webhook lease: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendPending sends up to BatchSize deliveries that are due, if no other
+// replica holds the lease. The deliveries of each webhook are sent in the
+// order they were recorded, and the webhooks in parallel; once an endpoint
+// fails, its remaining deliveries wait for the next look. It returns the
+// number of deliveries that succeeded.
+func (s *Sender) SendPending(ctx context.Context) (int, error) {
+	now := s.now()
+	held, err := s.db.AcquireLease(ctx, leaseName, s.holder, now, s.opts.LeaseTTL)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	var deliveries []structure.WebhookDelivery
+	filter := bson.M{"status": structure.DeliveryPending, "nextattemptat": bson.M{"$lte": now}}
+	opts := database.FindOptions{Limit: s.opts.BatchSize, Sort: bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}}
+	if _, err := s.db.FindPage(ctx, "webhookDelivery", filter, opts, &deliveries); err != nil {
+		return 0, fmt.Errorf("failed to find pending deliveries: %w", err)
+	}
+	byWebhook := make(map[primitive.ObjectID][]structure.WebhookDelivery)
+	for _, delivery := range deliveries {
+		byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], delivery)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		errs      []error
+	)
+	for webhookID, deliveries := range byWebhook {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, err := s.sendAll(ctx, webhookID, deliveries, now)
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded += sent
+			if err != nil {
+				errs = append(errs, fmt.Errorf("webhook %s: %w", webhookID.Hex(), err))
+			}
+		}()
+	}
+	wg.Wait()
+	return succeeded, errors.Join(errs...)
+}
+
+// sendAll sends the deliveries of one webhook in order, stopping at the first
+// that fails. It returns the number that succeeded.
+func (s *Sender) sendAll(ctx context.Context, webhookID primitive.ObjectID, deliveries []structure.WebhookDelivery, now time.Time) (int, error) {
+	var webhook structure.Webhook
+	err := s.db.Get(ctx, "webhook", webhookID.Hex(), &webhook)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return 0, err
+	}
+	if errors.Is(err, database.ErrNotFound) || webhook.Disabled {
+		// Deliveries for deleted or disabled webhooks are given up on
+		reason := "webhook was deleted"
+		if webhook.Disabled {
+			reason = "webhook is disabled"
+		}
+		for _, delivery := range deliveries {
+			update := bson.M{"status": structure.DeliveryFailed, "lasterror": reason}
+			if err := s.db.Update(ctx, "webhookDelivery", delivery.ID.Hex(), update); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+
+	sent := 0
+	for _, delivery := range deliveries {
+		ok, err := s.send(ctx, &webhook, delivery, now)
+		if err != nil {
+			return sent, err
+		}
+		if !ok {
+			break
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// send makes one attempt at delivery and records the outcome on it and on
+// webhook. It reports whether the endpoint accepted the delivery. Only
+// failing to record the outcome is returned as an error.
+func (s *Sender) send(ctx context.Context, webhook *structure.Webhook, delivery structure.WebhookDelivery, now time.Time) (bool, error) {
+	attempt := delivery.Attempts + 1
+	status, err := s.post(ctx, webhook, delivery, now)
+	update := bson.M{"attempts": attempt, "responsestatus": status}
+	if err == nil {
+		update["status"] = structure.DeliverySucceeded
+		update["deliveredat"] = now.UTC()
+		update["lasterror"] = ""
+		if err := s.db.Update(ctx, "webhookDelivery", delivery.ID.Hex(), update); err != nil {
+			return false, err
+		}
+		if webhook.Failures > 0 {
+			webhook.Failures = 0
+			return true, s.db.Update(ctx, "webhook", webhook.ID.Hex(), bson.M{"failures": 0})
+		}
+		return true, nil
+	}
+
+	// Count the failure against the webhook, disabling it after too many
+	webhook.Failures++
+	webhookUpdate := bson.M{"failures": webhook.Failures}
+	if webhook.Failures >= s.opts.DisableAfter {
+		webhook.Disabled = true
+		webhookUpdate["disabled"] = true
+		log.Printf("Disabled webhook %s after %d failed deliveries: %v", webhook.ID.Hex(), webhook.Failures, err)
+	}
+	if err := s.db.Update(ctx, "webhook", webhook.ID.Hex(), webhookUpdate); err != nil {
+		return false, err
+	}
+
+	update["lasterror"] = err.Error()
+	switch {
+	case webhook.Disabled:
+		update["status"] = structure.DeliveryFailed
+	case attempt >= s.opts.MaxAttempts:
+		update["status"] = structure.DeliveryFailed
+		log.Printf("Gave up on webhook delivery %s after %d attempts: %v", delivery.ID.Hex(), attempt, err)
+	default:
+		update["nextattemptat"] = now.Add(s.backoff(attempt))
+	}
+	return false, s.db.Update(ctx, "webhookDelivery", delivery.ID.Hex(), update)
+}
+
+// post sends delivery to the webhook's endpoint, returning the status it
+// answered with, if any, and an error unless the status is 2xx.
+func (s *Sender) post(ctx context.Context, webhook *structure.Webhook, delivery structure.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Body)))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Go-sumon-Webhook/1")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderEventID, delivery.EventID.Hex())
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, []byte(delivery.Body)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given failed attempt.
+func (s *Sender) backoff(attempt int) time.Duration {
+	wait := s.opts.Backoff
+	for i := 1; i < attempt && wait < s.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.opts.MaxBackoff)
+}
+
+// Sign returns the signature header of a delivery of body made at the Unix
+// time timestamp: "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with secret. Receivers recompute it to check that the delivery came
+// from this server and was not changed, and should reject old timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,270 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"Go-sumon/database"
+	"Go-sumon/outbox"
+	"Go-sumon/structure"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver is an httptest endpoint that records the deliveries it gets and
+// answers with status.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T) *receiver {
+	rec := &receiver{status: http.StatusOK}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		w.WriteHeader(rec.status)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+// senderTest is a Sender on a fresh memory store with a fake clock.
+type senderTest struct {
+	t      *testing.T
+	store  *database.MemoryStore
+	sender *Sender
+	now    *time.Time
+}
+
+func newSenderTest(t *testing.T, opts Options) *senderTest {
+	t.Helper()
+	store := database.NewMemoryStore()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	sender := NewSender(store, opts)
+	sender.now = func() time.Time { return now }
+	return &senderTest{t: t, store: store, sender: sender, now: &now}
+}
+
+// webhook subscribes url to events.
+func (s *senderTest) webhook(url string, events ...structure.EventType) structure.Webhook {
+	s.t.Helper()
+	webhook := structure.Webhook{URL: url, Events: events, Secret: testSecret}
+	if err := s.store.Create(context.Background(), "webhook", &webhook); err != nil {
+		s.t.Fatalf("Failed to create webhook: %v", err)
+	}
+	return webhook
+}
+
+// enqueue records deliveries of a ReviewCreated event.
+func (s *senderTest) enqueue() outbox.Event {
+	s.t.Helper()
+	event := outbox.Event{ID: primitive.NewObjectID(), OccurredAt: *s.now, Attempt: 1, Payload: structure.ReviewCreated{ReviewID: primitive.NewObjectID()}}
+	if err := s.sender.Enqueue(context.Background(), event); err != nil {
+		s.t.Fatalf("Failed to enqueue event: %v", err)
+	}
+	return event
+}
+
+// send sends the due deliveries, returning how many succeeded.
+func (s *senderTest) send() int {
+	s.t.Helper()
+	sent, err := s.sender.SendPending(context.Background())
+	if err != nil {
+		s.t.Fatalf("Failed to send deliveries: %v", err)
+	}
+	return sent
+}
+
+// deliveries returns the deliveries of webhookID, oldest first.
+func (s *senderTest) deliveries(webhookID primitive.ObjectID) []structure.WebhookDelivery {
+	s.t.Helper()
+	var deliveries []structure.WebhookDelivery
+	opts := database.FindOptions{Sort: bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}}
+	if _, err := s.store.FindPage(context.Background(), "webhookDelivery", bson.M{"webhookid": webhookID}, opts, &deliveries); err != nil {
+		s.t.Fatalf("Failed to find deliveries: %v", err)
+	}
+	return deliveries
+}
+
+func TestSenderSignsDeliveries(t *testing.T) {
+	// Arrange
+	s := newSenderTest(t, DefaultOptions())
+	rec := newReceiver(t)
+	webhook := s.webhook(rec.URL, structure.EventReviewCreated)
+	other := s.webhook(rec.URL, structure.EventBidPlaced)
+	event := s.enqueue()
+
+	// Act
+	sent := s.send()
+
+	// Assert
+	if sent != 1 || len(rec.requests) != 1 {
+		t.Fatalf("Expected one delivery, sent %d and received %d", sent, len(rec.requests))
+	}
+	req, received := rec.requests[0], rec.bodies[0]
+	timestamp, _ := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if req.Header.Get(HeaderSignature) != Sign(testSecret, timestamp, received) || timestamp != s.now.Unix() {
+		t.Errorf("Expected a valid signature, got %q at %d", req.Header.Get(HeaderSignature), timestamp)
+	}
+	if req.Header.Get(HeaderEvent) != string(structure.EventReviewCreated) || req.Header.Get(HeaderEventID) != event.ID.Hex() {
+		t.Errorf("Expected the event headers, got %v", req.Header)
+	}
+	var decoded struct {
+		ID   primitive.ObjectID  `json:"id"`
+		Type structure.EventType `json:"type"`
+		Data map[string]any      `json:"data"`
+	}
+	if err := json.Unmarshal(received, &decoded); err != nil || decoded.ID != event.ID || decoded.Data["reviewId"] != event.Payload.(structure.ReviewCreated).ReviewID.Hex() {
+		t.Errorf("Expected the event in the body, got %s (%v)", received, err)
+	}
+	deliveries := s.deliveries(webhook.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != structure.DeliverySucceeded || deliveries[0].ResponseStatus != http.StatusOK || deliveries[0].Attempts != 1 {
+		t.Errorf("Expected the delivery to be logged as succeeded, got %+v", deliveries)
+	}
+	if len(s.deliveries(other.ID)) != 0 {
+		t.Error("Expected no delivery to a webhook not subscribed to the event")
+	}
+}
+
+func TestSenderEnqueuesEventOnce(t *testing.T) {
+	// Arrange
+	s := newSenderTest(t, DefaultOptions())
+	webhook := s.webhook("http://partner.example/hook", structure.EventReviewCreated)
+	event := s.enqueue()
+
+	// Act: the outbox delivers the event again after another subscriber failed
+	event.Attempt = 2
+	err := s.sender.Enqueue(context.Background(), event)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to enqueue event: %v", err)
+	}
+	if deliveries := s.deliveries(webhook.ID); len(deliveries) != 1 || deliveries[0].EventID != event.ID {
+		t.Errorf("Expected one delivery of the event, got %+v", deliveries)
+	}
+}
+
+func TestSenderHidesSealedBidAmounts(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := newSenderTest(t, DefaultOptions())
+	webhook := s.webhook("http://partner.example/hook", structure.EventBidPlaced)
+	job := structure.Job{Title: "Sealed job", JobStatus: structure.JobStatusJobPosted, AuctionMode: structure.AuctionModeSealed, BiddingDeadline: s.now.Add(time.Hour)}
+	if err := s.store.Create(ctx, "job", &job); err != nil {
+		t.Fatalf("Failed to insert job document: %v", err)
+	}
+	placed := structure.BidPlaced{BidID: primitive.NewObjectID(), JobID: job.ID, BidAmount: structure.Taka(750)}
+
+	// Act
+	err := s.sender.Enqueue(ctx, outbox.Event{ID: primitive.NewObjectID(), OccurredAt: *s.now, Attempt: 1, Payload: placed})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to enqueue event: %v", err)
+	}
+	deliveries := s.deliveries(webhook.ID)
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected one delivery, got %+v", deliveries)
+	}
+	var decoded struct {
+		Data structure.BidPlaced `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(deliveries[0].Body), &decoded); err != nil || decoded.Data.BidID != placed.BidID || decoded.Data.BidAmount != 0 {
+		t.Errorf("Expected the sealed bid's amount to be left out, got %s (%v)", deliveries[0].Body, err)
+	}
+}
+
+func TestSenderRetriesFailedDelivery(t *testing.T) {
+	// Arrange
+	s := newSenderTest(t, DefaultOptions())
+	rec := newReceiver(t)
+	rec.status = http.StatusServiceUnavailable
+	webhook := s.webhook(rec.URL, structure.EventReviewCreated)
+	s.enqueue()
+	s.enqueue()
+
+	// Act: the first attempt fails, and its retry waits for the backoff
+	// while the next look tries the later delivery
+	retryAt := s.now.Add(30 * time.Second)
+	first := s.send()
+	failed := s.deliveries(webhook.ID)
+	early := s.send()
+	rec.status = http.StatusNoContent
+	*s.now = retryAt
+	retried := s.send()
+
+	// Assert
+	if first != 0 || early != 0 || retried != 2 || len(rec.requests) != 4 {
+		t.Errorf("Expected both deliveries on the retry, sent %d, %d then %d in %d requests", first, early, retried, len(rec.requests))
+	}
+	if failed[0].Status != structure.DeliveryPending || failed[0].ResponseStatus != http.StatusServiceUnavailable || !failed[0].NextAttemptAt.Equal(retryAt) {
+		t.Errorf("Expected the delivery to be retried after the backoff, got %+v", failed[0])
+	}
+	if failed[1].Attempts != 0 {
+		t.Errorf("Expected the later delivery to wait for the next look, got %+v", failed[1])
+	}
+	for _, delivery := range s.deliveries(webhook.ID) {
+		if delivery.Status != structure.DeliverySucceeded {
+			t.Errorf("Expected the delivery to succeed, got %+v", delivery)
+		}
+	}
+}
+
+func TestSenderDisablesFailingWebhook(t *testing.T) {
+	// Arrange
+	opts := DefaultOptions()
+	opts.DisableAfter = 3
+	s := newSenderTest(t, opts)
+	rec := newReceiver(t)
+	rec.status = http.StatusInternalServerError
+	webhook := s.webhook(rec.URL, structure.EventReviewCreated)
+	s.enqueue()
+
+	// Act
+	for i := 0; i < 5; i++ {
+		s.send()
+		*s.now = s.now.Add(opts.MaxBackoff)
+	}
+	s.enqueue()
+
+	// Assert
+	var disabled structure.Webhook
+	if err := s.store.Get(context.Background(), "webhook", webhook.ID.Hex(), &disabled); err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if !disabled.Disabled || disabled.Failures != 3 || len(rec.requests) != 3 {
+		t.Errorf("Expected the webhook to be disabled after 3 failures, got %+v after %d requests", disabled, len(rec.requests))
+	}
+	deliveries := s.deliveries(webhook.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != structure.DeliveryFailed || deliveries[0].LastError == "" {
+		t.Errorf("Expected only the failed delivery, got %+v", deliveries)
+	}
+}
+
+func TestSenderBackoff(t *testing.T) {
+	s := NewSender(database.NewMemoryStore(), Options{Backoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := s.backoff(attempt); got != want {
+			t.Errorf("Expected a backoff of %v after attempt %d, got %v", want, attempt, got)
+		}
+	}
+}